@@ -0,0 +1,159 @@
+package cache
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+type Cache struct {
+	Dir string
+}
+
+type Entry struct {
+	Key     string
+	Path    string
+	Size    int64
+	Created time.Time
+}
+
+type Stats struct {
+	Dir     string    `json:"dir"`
+	Entries int       `json:"entries"`
+	Bytes   int64     `json:"bytes"`
+	Oldest  time.Time `json:"oldest,omitempty"`
+	Newest  time.Time `json:"newest,omitempty"`
+}
+
+func DefaultDir() (string, error) {
+	if dir := os.Getenv("AIP_CACHE_DIR"); dir != "" {
+		return dir, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".aip", "cache"), nil
+}
+
+func New(dir string) *Cache {
+	return &Cache{Dir: dir}
+}
+
+func (c *Cache) path(key string) string {
+	prefix := key
+	if len(prefix) > 2 {
+		prefix = prefix[:2]
+	}
+	return filepath.Join(c.Dir, prefix, key+".json")
+}
+
+func (c *Cache) Get(key string) ([]byte, bool) {
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+// Put writes through a temp file so concurrent readers never see a partial
+// entry.
+func (c *Cache) Put(key string, data []byte) error {
+	path := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (c *Cache) List() ([]Entry, error) {
+	var entries []Entry
+	err := filepath.WalkDir(c.Dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(d.Name(), ".json") {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		entries = append(entries, Entry{
+			Key:     strings.TrimSuffix(d.Name(), ".json"),
+			Path:    path,
+			Size:    info.Size(),
+			Created: info.ModTime(),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Created.After(entries[j].Created)
+	})
+	return entries, nil
+}
+
+func (c *Cache) Stats() (Stats, error) {
+	entries, err := c.List()
+	if err != nil {
+		return Stats{}, err
+	}
+	stats := Stats{Dir: c.Dir, Entries: len(entries)}
+	for _, e := range entries {
+		stats.Bytes += e.Size
+		if stats.Oldest.IsZero() || e.Created.Before(stats.Oldest) {
+			stats.Oldest = e.Created
+		}
+		if e.Created.After(stats.Newest) {
+			stats.Newest = e.Created
+		}
+	}
+	return stats, nil
+}
+
+func (c *Cache) Clear() (int, error) {
+	return c.Prune(0)
+}
+
+// Prune removes entries older than age; an age of zero removes everything.
+func (c *Cache) Prune(age time.Duration) (int, error) {
+	entries, err := c.List()
+	if err != nil {
+		return 0, err
+	}
+	cutoff := time.Now().Add(-age)
+	removed := 0
+	for _, e := range entries {
+		if age > 0 && e.Created.After(cutoff) {
+			continue
+		}
+		if err := os.Remove(e.Path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
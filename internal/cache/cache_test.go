@@ -0,0 +1,60 @@
+package cache
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestPutGet(t *testing.T) {
+	c := New(t.TempDir())
+	if _, ok := c.Get("abcdef"); ok {
+		t.Fatal("unexpected hit on empty cache")
+	}
+	if err := c.Put("abcdef", []byte(`{"ok":true}`)); err != nil {
+		t.Fatalf("Put error: %v", err)
+	}
+	data, ok := c.Get("abcdef")
+	if !ok || string(data) != `{"ok":true}` {
+		t.Fatalf("Get = %q, %v", data, ok)
+	}
+	stats, err := c.Stats()
+	if err != nil {
+		t.Fatalf("Stats error: %v", err)
+	}
+	if stats.Entries != 1 || stats.Bytes != int64(len(data)) {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestPruneAndClear(t *testing.T) {
+	c := New(t.TempDir())
+	for _, key := range []string{"aa01", "bb02"} {
+		if err := c.Put(key, []byte("{}")); err != nil {
+			t.Fatalf("Put error: %v", err)
+		}
+	}
+	old := time.Now().Add(-48 * time.Hour)
+	if err := os.Chtimes(c.path("aa01"), old, old); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+	removed, err := c.Prune(24 * time.Hour)
+	if err != nil || removed != 1 {
+		t.Fatalf("Prune = %d, %v", removed, err)
+	}
+	if _, ok := c.Get("bb02"); !ok {
+		t.Fatal("recent entry pruned")
+	}
+	removed, err = c.Clear()
+	if err != nil || removed != 1 {
+		t.Fatalf("Clear = %d, %v", removed, err)
+	}
+}
+
+func TestListMissingDir(t *testing.T) {
+	c := New(t.TempDir() + "/missing")
+	entries, err := c.List()
+	if err != nil || len(entries) != 0 {
+		t.Fatalf("List = %v, %v", entries, err)
+	}
+}
@@ -0,0 +1,161 @@
+package chunk
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/yjhatfdu/aip/internal/tokens"
+)
+
+type Options struct {
+	MaxChars  int
+	MaxTokens int
+	Overlap   int
+}
+
+type Chunk struct {
+	Index     int
+	StartLine int
+	EndLine   int
+	Offset    int64
+	Text      string
+}
+
+type line struct {
+	text   string
+	no     int
+	offset int64
+	size   int
+}
+
+type Splitter struct {
+	r       *bufio.Reader
+	opts    Options
+	carry   []line
+	pending *line
+	lineNo  int
+	offset  int64
+	index   int
+	eof     bool
+}
+
+func NewSplitter(r io.Reader, opts Options) *Splitter {
+	if opts.MaxChars <= 0 && opts.MaxTokens <= 0 {
+		opts.MaxChars = 8000
+	}
+	if opts.Overlap < 0 {
+		opts.Overlap = 0
+	}
+	return &Splitter{r: bufio.NewReaderSize(r, 64*1024), opts: opts}
+}
+
+// Next returns the next line-aligned chunk, or io.EOF once input is exhausted.
+// A single line larger than the limit becomes a chunk of its own.
+func (s *Splitter) Next() (Chunk, error) {
+	lines := append([]line(nil), s.carry...)
+	size := 0
+	for _, ln := range lines {
+		size += ln.size
+	}
+	limit := s.limit()
+	fresh := 0
+	for {
+		ln, err := s.readLine()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return Chunk{}, err
+		}
+		if fresh == 0 {
+			for len(lines) > 0 && size+ln.size > limit {
+				size -= lines[0].size
+				lines = lines[1:]
+			}
+		} else if size+ln.size > limit {
+			s.pending = &ln
+			break
+		}
+		lines = append(lines, ln)
+		size += ln.size
+		fresh++
+	}
+	if fresh == 0 {
+		return Chunk{}, io.EOF
+	}
+
+	keep := s.opts.Overlap
+	if keep > len(lines) {
+		keep = len(lines)
+	}
+	s.carry = append(s.carry[:0], lines[len(lines)-keep:]...)
+
+	var b strings.Builder
+	for _, ln := range lines {
+		b.WriteString(ln.text)
+	}
+	c := Chunk{
+		Index:     s.index,
+		StartLine: lines[0].no,
+		EndLine:   lines[len(lines)-1].no,
+		Offset:    lines[0].offset,
+		Text:      b.String(),
+	}
+	s.index++
+	return c, nil
+}
+
+func (s *Splitter) limit() int {
+	if s.opts.MaxTokens > 0 {
+		return s.opts.MaxTokens
+	}
+	return s.opts.MaxChars
+}
+
+func (s *Splitter) measure(text string) int {
+	if s.opts.MaxTokens > 0 {
+		return tokens.Estimate(text)
+	}
+	return len(text)
+}
+
+func (s *Splitter) readLine() (line, error) {
+	if s.pending != nil {
+		ln := *s.pending
+		s.pending = nil
+		return ln, nil
+	}
+	if s.eof {
+		return line{}, io.EOF
+	}
+	text, err := s.r.ReadString('\n')
+	if errors.Is(err, io.EOF) {
+		s.eof = true
+		if text == "" {
+			return line{}, io.EOF
+		}
+	} else if err != nil {
+		return line{}, err
+	}
+	s.lineNo++
+	ln := line{text: text, no: s.lineNo, offset: s.offset, size: s.measure(text)}
+	s.offset += int64(len(text))
+	return ln, nil
+}
+
+func SplitString(text string, opts Options) ([]Chunk, error) {
+	s := NewSplitter(strings.NewReader(text), opts)
+	var out []Chunk
+	for {
+		c, err := s.Next()
+		if errors.Is(err, io.EOF) {
+			return out, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+}
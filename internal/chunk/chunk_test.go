@@ -0,0 +1,66 @@
+package chunk
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSplitLineAligned(t *testing.T) {
+	input := "aaaa\nbbbb\ncccc\ndddd\n"
+	chunks, err := SplitString(input, Options{MaxChars: 10})
+	if err != nil {
+		t.Fatalf("split: %v", err)
+	}
+	if len(chunks) != 2 {
+		t.Fatalf("expected 2 chunks, got %d", len(chunks))
+	}
+	if chunks[0].Text != "aaaa\nbbbb\n" || chunks[1].Text != "cccc\ndddd\n" {
+		t.Fatalf("unexpected chunks: %#v", chunks)
+	}
+	if chunks[1].StartLine != 3 || chunks[1].EndLine != 4 || chunks[1].Offset != 10 {
+		t.Fatalf("unexpected position: %#v", chunks[1])
+	}
+}
+
+func TestSplitOverlap(t *testing.T) {
+	input := "a\nb\nc\nd\n"
+	chunks, err := SplitString(input, Options{MaxChars: 6, Overlap: 1})
+	if err != nil {
+		t.Fatalf("split: %v", err)
+	}
+	var got []string
+	for _, c := range chunks {
+		got = append(got, strings.ReplaceAll(c.Text, "\n", ""))
+	}
+	if strings.Join(got, ",") != "abc,cd" {
+		t.Fatalf("unexpected chunks: %v", got)
+	}
+	if chunks[1].StartLine != 3 || chunks[1].Offset != 4 {
+		t.Fatalf("unexpected overlap position: %#v", chunks[1])
+	}
+}
+
+func TestSplitOversizedLine(t *testing.T) {
+	input := "short\n" + strings.Repeat("x", 50) + "\nend"
+	chunks, err := SplitString(input, Options{MaxChars: 10})
+	if err != nil {
+		t.Fatalf("split: %v", err)
+	}
+	if len(chunks) != 3 {
+		t.Fatalf("expected 3 chunks, got %d", len(chunks))
+	}
+	if chunks[2].Text != "end" || chunks[2].StartLine != 3 {
+		t.Fatalf("unexpected tail chunk: %#v", chunks[2])
+	}
+}
+
+func TestSplitTokens(t *testing.T) {
+	input := strings.Repeat("abcdefgh\n", 10)
+	chunks, err := SplitString(input, Options{MaxTokens: 9})
+	if err != nil {
+		t.Fatalf("split: %v", err)
+	}
+	if len(chunks) != 4 {
+		t.Fatalf("expected 4 chunks, got %d", len(chunks))
+	}
+}
@@ -81,6 +81,7 @@ func ClusterSigs(items []SigInfo, params Params) ([]Cluster, error) {
 			clusters[root] = c
 		}
 		c.Count += item.Count
+		c.Members = append(c.Members, item.Sig)
 		if c.Repr == "" || item.Count > c.reprCount || (item.Count == c.reprCount && item.Sig < c.Repr) {
 			c.Repr = item.Sig
 			c.reprCount = item.Count
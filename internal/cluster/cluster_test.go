@@ -39,6 +39,9 @@ func TestClusterAllUnionWithHighThreshold(t *testing.T) {
 	if len(out[0].Samples) != 1 {
 		t.Fatalf("samples mismatch: %#v", out[0].Samples)
 	}
+	if len(out[0].Members) != 2 {
+		t.Fatalf("members mismatch: %#v", out[0].Members)
+	}
 }
 
 func TestHamming(t *testing.T) {
@@ -11,4 +11,5 @@ type Cluster struct {
 	FirstTS string   `json:"first_ts,omitempty"`
 	LastTS  string   `json:"last_ts,omitempty"`
 	Samples []Sample `json:"samples,omitempty"`
+	Members []string `json:"-"`
 }
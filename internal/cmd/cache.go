@@ -0,0 +1,159 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/yjhatfdu/aip/internal/cache"
+	"github.com/yjhatfdu/aip/internal/i18n"
+)
+
+func newCacheCommand(lang i18n.Lang) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "cache",
+		Short: i18n.T(lang, "cmd.cache.short"),
+	}
+
+	cmd.AddCommand(newCacheStatsCommand(lang))
+	cmd.AddCommand(newCacheLsCommand(lang))
+	cmd.AddCommand(newCacheClearCommand(lang))
+	cmd.AddCommand(newCachePruneCommand(lang))
+	return cmd
+}
+
+func openCache() (*cache.Cache, error) {
+	dir, err := cache.DefaultDir()
+	if err != nil {
+		return nil, err
+	}
+	return cache.New(dir), nil
+}
+
+func newCacheStatsCommand(lang i18n.Lang) *cobra.Command {
+	var format string
+	cmd := &cobra.Command{
+		Use:   "stats",
+		Short: i18n.T(lang, "cmd.cache.stats.short"),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := openCache()
+			if err != nil {
+				return err
+			}
+			stats, err := c.Stats()
+			if err != nil {
+				return err
+			}
+			out := cmd.OutOrStdout()
+			switch format {
+			case "json":
+				enc := json.NewEncoder(out)
+				enc.SetEscapeHTML(false)
+				return enc.Encode(stats)
+			case "", "text":
+				fmt.Fprintf(out, "dir: %s\n", stats.Dir)
+				fmt.Fprintf(out, "entries: %d\n", stats.Entries)
+				fmt.Fprintf(out, "bytes: %d\n", stats.Bytes)
+				if stats.Entries > 0 {
+					fmt.Fprintf(out, "oldest: %s\n", stats.Oldest.Format(time.RFC3339))
+					fmt.Fprintf(out, "newest: %s\n", stats.Newest.Format(time.RFC3339))
+				}
+				return nil
+			default:
+				return fmt.Errorf("unknown format: %s", format)
+			}
+		},
+	}
+	cmd.Flags().StringVar(&format, "format", "text", "format: text|json")
+	return cmd
+}
+
+func newCacheLsCommand(lang i18n.Lang) *cobra.Command {
+	return &cobra.Command{
+		Use:   "ls",
+		Short: i18n.T(lang, "cmd.cache.ls.short"),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := openCache()
+			if err != nil {
+				return err
+			}
+			entries, err := c.List()
+			if err != nil {
+				return err
+			}
+			for _, e := range entries {
+				if _, err := fmt.Fprintf(cmd.OutOrStdout(), "%s\t%d\t%s\n", e.Created.Format(time.RFC3339), e.Size, e.Key); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+}
+
+func newCacheClearCommand(lang i18n.Lang) *cobra.Command {
+	return &cobra.Command{
+		Use:   "clear",
+		Short: i18n.T(lang, "cmd.cache.clear.short"),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := openCache()
+			if err != nil {
+				return err
+			}
+			removed, err := c.Clear()
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.ErrOrStderr(), "%s: %d\n", i18n.T(lang, "msg.cache_removed"), removed)
+			return nil
+		},
+	}
+}
+
+func newCachePruneCommand(lang i18n.Lang) *cobra.Command {
+	var olderThan string
+	cmd := &cobra.Command{
+		Use:   "prune",
+		Short: i18n.T(lang, "cmd.cache.prune.short"),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			age, err := parseAge(olderThan)
+			if err != nil {
+				return err
+			}
+			if age <= 0 {
+				return fmt.Errorf("--older-than must be > 0")
+			}
+			c, err := openCache()
+			if err != nil {
+				return err
+			}
+			removed, err := c.Prune(age)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.ErrOrStderr(), "%s: %d\n", i18n.T(lang, "msg.cache_removed"), removed)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&olderThan, "older-than", "7d", "remove entries older than this age (e.g. 12h, 7d)")
+	return cmd
+}
+
+// parseAge extends time.ParseDuration with a plain day suffix.
+func parseAge(value string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid age: %s", value)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid age: %s", value)
+	}
+	return d, nil
+}
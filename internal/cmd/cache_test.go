@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yjhatfdu/aip/internal/llm"
+)
+
+func runSummaryJSON(t *testing.T, url string, extra ...string) {
+	t.Helper()
+	root := newRoot()
+	root.SetArgs(append([]string{
+		"summary", "summarize",
+		"--format", "json",
+		"--base-url", url,
+		"--api-key", "key",
+		"--model", "model",
+	}, extra...))
+	root.SetIn(strings.NewReader("hello\n"))
+	root.SetOut(&bytes.Buffer{})
+	root.SetErr(&bytes.Buffer{})
+	if err := root.Execute(); err != nil {
+		t.Fatalf("summary error: %v", err)
+	}
+}
+
+func TestSummaryUsesCacheAndCacheCommands(t *testing.T) {
+	t.Setenv("AIP_CACHE_DIR", t.TempDir())
+	t.Setenv("AIP_LANG", "en")
+	hits := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		resp := llm.ChatResponse{
+			Model: "model",
+			Choices: []struct {
+				Message llm.ChatMessage `json:"message"`
+			}{
+				{Message: llm.ChatMessage{Role: "assistant", Content: "ok"}},
+			},
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(server.Close)
+
+	runSummaryJSON(t, server.URL)
+	runSummaryJSON(t, server.URL)
+	if hits != 1 {
+		t.Fatalf("expected cached second call, got %d upstream hits", hits)
+	}
+	runSummaryJSON(t, server.URL, "--no-cache")
+	if hits != 2 {
+		t.Fatalf("--no-cache should bypass cache, got %d upstream hits", hits)
+	}
+
+	root := newRoot()
+	root.SetArgs([]string{"cache", "stats"})
+	out := &bytes.Buffer{}
+	root.SetOut(out)
+	root.SetErr(&bytes.Buffer{})
+	if err := root.Execute(); err != nil {
+		t.Fatalf("cache stats error: %v", err)
+	}
+	if !strings.Contains(out.String(), "entries: 1") {
+		t.Fatalf("unexpected stats: %q", out.String())
+	}
+
+	root = newRoot()
+	root.SetArgs([]string{"cache", "clear"})
+	errOut := &bytes.Buffer{}
+	root.SetOut(&bytes.Buffer{})
+	root.SetErr(errOut)
+	if err := root.Execute(); err != nil {
+		t.Fatalf("cache clear error: %v", err)
+	}
+	if !strings.Contains(errOut.String(), ": 1") {
+		t.Fatalf("unexpected clear output: %q", errOut.String())
+	}
+}
+
+func TestParseAge(t *testing.T) {
+	if d, err := parseAge("7d"); err != nil || d != 7*24*time.Hour {
+		t.Fatalf("parseAge(7d) = %v, %v", d, err)
+	}
+	if d, err := parseAge("90m"); err != nil || d != 90*time.Minute {
+		t.Fatalf("parseAge(90m) = %v, %v", d, err)
+	}
+	if _, err := parseAge("soon"); err == nil {
+		t.Fatal("expected error for invalid age")
+	}
+}
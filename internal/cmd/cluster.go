@@ -12,6 +12,7 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/yjhatfdu/aip/internal/cluster"
 	"github.com/yjhatfdu/aip/internal/i18n"
+	"github.com/yjhatfdu/aip/internal/norm"
 )
 
 func newClusterCommand(lang i18n.Lang) *cobra.Command {
@@ -31,6 +32,9 @@ func newClusterCommand(lang i18n.Lang) *cobra.Command {
 		Use:   "cluster [file]",
 		Short: i18n.T(lang, "cmd.cluster.short"),
 		Args:  cobra.MaximumNArgs(1),
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return applyCommandDefaults(cmd, lang, "cluster")
+		},
 		RunE: func(cmd *cobra.Command, args []string) error {
 			if algo == "" {
 				algo = "simhash"
@@ -249,3 +253,44 @@ func addJSONRecord(sigs map[string]*sigAgg, obj map[string]any, field, timeField
 	}
 	return nil
 }
+
+type sigAggregator struct {
+	sigs  map[string]*sigAgg
+	order []string
+}
+
+func newSigAggregator() *sigAggregator {
+	return &sigAggregator{sigs: map[string]*sigAgg{}}
+}
+
+func (a *sigAggregator) add(rec norm.Record) {
+	entry, ok := a.sigs[rec.Sig]
+	if !ok {
+		entry = &sigAgg{SigInfo: cluster.SigInfo{Sig: rec.Sig, Sample: rec.Raw, SampleTS: rec.TS}}
+		a.sigs[rec.Sig] = entry
+		a.order = append(a.order, rec.Sig)
+	}
+	entry.Count++
+	if entry.FirstTS == "" || (rec.TS != "" && rec.TS < entry.FirstTS) {
+		entry.FirstTS = rec.TS
+	}
+	if entry.LastTS == "" || (rec.TS != "" && rec.TS > entry.LastTS) {
+		entry.LastTS = rec.TS
+	}
+}
+
+func (a *sigAggregator) infos() []cluster.SigInfo {
+	infos := make([]cluster.SigInfo, 0, len(a.order))
+	for _, sig := range a.order {
+		infos = append(infos, a.sigs[sig].SigInfo)
+	}
+	return infos
+}
+
+func sigInfosFromRecords(records []norm.Record) []cluster.SigInfo {
+	agg := newSigAggregator()
+	for _, rec := range records {
+		agg.add(rec)
+	}
+	return agg.infos()
+}
@@ -78,3 +78,50 @@ func TestClusterCommandAutoDetectText(t *testing.T) {
 		t.Fatalf("expected clustered count, got: %q", out.String())
 	}
 }
+
+func TestClusterCommandConfigDefaults(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	t.Setenv("AIP_LANG", "en")
+	t.Setenv("AIP_PROFILE", "")
+
+	for _, kv := range [][2]string{{"cluster.threshold", "64"}, {"cluster.min-cluster", "1"}, {"cluster.format", "sample"}} {
+		root := newRoot()
+		root.SetArgs([]string{"config", "set", kv[0], kv[1]})
+		root.SetOut(&bytes.Buffer{})
+		root.SetErr(&bytes.Buffer{})
+		if err := root.Execute(); err != nil {
+			t.Fatalf("config set %s: %v", kv[0], err)
+		}
+	}
+	for _, kv := range [][2]string{{"cluster.threshold", "many"}, {"cluster.nope", "1"}} {
+		root := newRoot()
+		root.SetArgs([]string{"config", "set", kv[0], kv[1]})
+		root.SetOut(&bytes.Buffer{})
+		root.SetErr(&bytes.Buffer{})
+		if err := root.Execute(); err == nil {
+			t.Fatalf("config set %s %s should fail", kv[0], kv[1])
+		}
+	}
+
+	input := `{"sig":"alpha","raw":"sample-a"}` + "\n" + `{"sig":"beta","raw":"sample-b"}` + "\n"
+	run := func(args ...string) string {
+		root := newRoot()
+		root.SetArgs(append([]string{"cluster"}, args...))
+		root.SetIn(strings.NewReader(input))
+		out := &bytes.Buffer{}
+		root.SetOut(out)
+		root.SetErr(&bytes.Buffer{})
+		if err := root.Execute(); err != nil {
+			t.Fatalf("cluster error: %v", err)
+		}
+		return out.String()
+	}
+	if out := run(); !strings.Contains(out, "sample-") || strings.Contains(out, `"count"`) {
+		t.Fatalf("config defaults not applied: %q", out)
+	}
+	if out := run("--format", "jsonl"); !strings.Contains(out, `"count"`) {
+		t.Fatalf("explicit flag should win over config: %q", out)
+	}
+}
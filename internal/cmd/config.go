@@ -3,9 +3,14 @@ package cmd
 import (
 	"errors"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/yjhatfdu/aip/internal/config"
 	"github.com/yjhatfdu/aip/internal/i18n"
 )
@@ -21,11 +26,13 @@ func newConfigCommand(lang i18n.Lang) *cobra.Command {
 	cmd.AddCommand(newConfigGetCommand(lang))
 	cmd.AddCommand(newConfigSetCommand(lang))
 	cmd.AddCommand(newConfigWizardCommand(lang))
+	cmd.AddCommand(newConfigProfilesCommand(lang))
+	cmd.AddCommand(newConfigDoctorCommand(lang))
 	return cmd
 }
 
 func newConfigShowCommand(lang i18n.Lang) *cobra.Command {
-	var merged bool
+	var merged, origin, reveal bool
 	cmd := &cobra.Command{
 		Use:   "show",
 		Short: i18n.T(lang, "cmd.config.show.short"),
@@ -34,26 +41,49 @@ func newConfigShowCommand(lang i18n.Lang) *cobra.Command {
 			if err != nil {
 				return err
 			}
-			var cfg config.Config
-			if merged {
-				cfg, err = config.LoadMerged(path)
+			if merged || origin {
+				warnInsecureConfig(cmd.ErrOrStderr(), lang, path)
+				stack, err := loadConfigStack(cmd)
 				if err != nil {
 					return err
 				}
-			} else {
-				cfg, err = config.Load(path)
-				if err != nil {
-					if errors.Is(err, os.ErrNotExist) {
-						return fmt.Errorf("%s: %s", path, i18n.T(lang, "err.config_missing"))
-					}
-					return err
+				warnIgnoredKeys(cmd.ErrOrStderr(), lang, stack)
+				cfg := stack.Merged()
+				if !reveal {
+					cfg = config.MaskSecrets(cfg)
+				}
+				if origin {
+					fmt.Fprint(cmd.OutOrStdout(), stack.RenderOrigins(cfg))
+					return nil
+				}
+				fmt.Fprint(cmd.OutOrStdout(), renderConfig(cfg))
+				return nil
+			}
+			file, err := readConfigFile(cmd, lang, path, true)
+			if err != nil {
+				return err
+			}
+			if name := configProfile(cmd); name != "" {
+				cfg, ok := file.Profile(name)
+				if !ok {
+					return fmt.Errorf("%s: %s", name, i18n.T(lang, "err.profile_unknown"))
 				}
+				if !reveal {
+					cfg = config.MaskSecrets(cfg)
+				}
+				fmt.Fprint(cmd.OutOrStdout(), renderConfig(cfg))
+				return nil
+			}
+			if !reveal {
+				file.MaskSecrets()
 			}
-			fmt.Fprint(cmd.OutOrStdout(), renderConfig(cfg))
+			fmt.Fprint(cmd.OutOrStdout(), file.Render())
 			return nil
 		},
 	}
 	cmd.Flags().BoolVar(&merged, "merged", false, i18n.T(lang, "cmd.config.show.merged"))
+	cmd.Flags().BoolVar(&origin, "origin", false, i18n.T(lang, "cmd.config.show.origin"))
+	cmd.Flags().BoolVar(&reveal, "reveal", false, i18n.T(lang, "cmd.config.reveal"))
 	return cmd
 }
 
@@ -66,15 +96,21 @@ func newConfigWizardCommand(lang i18n.Lang) *cobra.Command {
 			if err != nil {
 				return err
 			}
-			var current config.Config
-			if cfg, err := config.Load(path); err == nil {
-				current = cfg
+			file, err := readConfigFile(cmd, lang, path, false)
+			if err != nil {
+				return err
+			}
+			name := configProfile(cmd)
+			current, err := editTarget(lang, file, name)
+			if err != nil {
+				return err
 			}
 			cfg, err := config.Wizard(cmd.InOrStdin(), cmd.ErrOrStderr(), current)
 			if err != nil {
 				return err
 			}
-			if err := config.Save(path, cfg); err != nil {
+			setTarget(file, name, cfg)
+			if err := file.Save(path); err != nil {
 				return err
 			}
 			fmt.Fprintf(cmd.ErrOrStderr(), "%s\n", i18n.T(lang, "msg.config_saved"))
@@ -83,8 +119,163 @@ func newConfigWizardCommand(lang i18n.Lang) *cobra.Command {
 	}
 }
 
+// readConfigFile loads the config file; a missing file is an error only
+// when mustExist is set, otherwise it reads as empty.
+func readConfigFile(cmd *cobra.Command, lang i18n.Lang, path string, mustExist bool) (*config.File, error) {
+	warnInsecureConfig(cmd.ErrOrStderr(), lang, path)
+	file, err := config.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		if mustExist {
+			return nil, fmt.Errorf("%s: %s", path, i18n.T(lang, "err.config_missing"))
+		}
+		return &config.File{}, nil
+	}
+	return file, err
+}
+
+// editTarget returns the settings that config set and wizard change: the
+// explicitly selected profile, or the top-level settings.
+func editTarget(lang i18n.Lang, file *config.File, name string) (config.Config, error) {
+	if name == "" {
+		return file.Config, nil
+	}
+	cfg, ok := file.Profile(name)
+	if !ok {
+		return config.Config{}, fmt.Errorf("%s: %s", name, i18n.T(lang, "err.profile_unknown"))
+	}
+	return cfg, nil
+}
+
+// loadConfigStack layers the user config and the nearest .aip.toml for the
+// profile selected on cmd.
+func loadConfigStack(cmd *cobra.Command) (config.Stack, error) {
+	userPath, err := config.DefaultPath()
+	if err != nil {
+		return config.Stack{}, err
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return config.Stack{}, err
+	}
+	return config.LoadStack(userPath, config.FindProject(wd), configProfile(cmd))
+}
+
+// applyCommandDefaults sets the flags of cmd that were not given on the
+// command line from the config section of the same name. A config that
+// cannot be loaded only warns here: the command may not need it, and the
+// LLM flags report the error when they do.
+func applyCommandDefaults(cmd *cobra.Command, lang i18n.Lang, section string) error {
+	stack, err := loadConfigStack(cmd)
+	if err != nil {
+		fmt.Fprintf(cmd.ErrOrStderr(), "%s: %v\n", i18n.T(lang, "warn.command_defaults"), err)
+		return nil
+	}
+	values := stack.Merged().Commands[section]
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		flag, err := commandFlag(cmd, section, name)
+		if err != nil {
+			return err
+		}
+		if flag.Changed {
+			continue
+		}
+		value := values[name]
+		if l, ok := stack.CommandLayer(section, name); ok {
+			value = resolveConfigPath(flag, value, l.Dir)
+		}
+		if err := cmd.Flags().Set(flag.Name, value); err != nil {
+			return fmt.Errorf("config %s.%s: %w", section, name, err)
+		}
+	}
+	return nil
+}
+
+// pathFlagAnnotation marks flags that name a file. Its value is the prefix
+// that introduces the path: "" for plain paths, "@" for prompts.
+const pathFlagAnnotation = "aip_path_flag"
+
+func markPathFlag(cmd *cobra.Command, name, prefix string) {
+	_ = cmd.Flags().SetAnnotation(name, pathFlagAnnotation, []string{prefix})
+}
+
+// resolveConfigPath makes a relative path from a config file relative to
+// that file's directory, so a project .aip.toml works from any
+// subdirectory.
+func resolveConfigPath(flag *pflag.Flag, value, dir string) string {
+	prefix, ok := flag.Annotations[pathFlagAnnotation]
+	if !ok || dir == "" || len(prefix) != 1 || !strings.HasPrefix(value, prefix[0]) {
+		return value
+	}
+	path := strings.TrimPrefix(value, prefix[0])
+	if path == "" || filepath.IsAbs(path) || strings.HasPrefix(path, "~") {
+		return value
+	}
+	return prefix[0] + filepath.Join(dir, path)
+}
+
+// commandFlag finds the flag a command key maps to; the global
+// --config-profile and the LLM connection and sampling flags cannot be
+// defaulted per command.
+func commandFlag(cmd *cobra.Command, section, name string) (*pflag.Flag, error) {
+	flagName := config.FlagName(name)
+	flag := cmd.Flags().Lookup(flagName)
+	if flag == nil || flag == cmd.Root().PersistentFlags().Lookup(flagName) {
+		return nil, fmt.Errorf("config %s.%s: %s has no --%s flag", section, name, section, flagName)
+	}
+	if _, ok := flag.Annotations[llmFlagAnnotation]; ok {
+		return nil, fmt.Errorf("config %s.%s: --%s is an LLM flag and cannot be set per command", section, name, flagName)
+	}
+	return flag, nil
+}
+
+// validateCommandKey checks a section key and its value against the flag it
+// defaults.
+func validateCommandKey(cmd *cobra.Command, key, value string) error {
+	section, name, ok := config.SplitCommandKey(key)
+	if !ok || value == "" {
+		return nil
+	}
+	target, _, err := cmd.Root().Find([]string{section})
+	if err != nil || target.Name() != section {
+		return fmt.Errorf("config %s: unknown command", section)
+	}
+	flag, err := commandFlag(target, section, name)
+	if err != nil {
+		return err
+	}
+	if err := flag.Value.Set(value); err != nil {
+		return fmt.Errorf("config %s.%s: %w", section, name, err)
+	}
+	return nil
+}
+
+func warnInsecureConfig(w io.Writer, lang i18n.Lang, path string) {
+	if config.Insecure(path) {
+		fmt.Fprintf(w, "%s: %s (chmod 600 %s)\n", path, i18n.T(lang, "warn.config_perms"), path)
+	}
+}
+
+func warnIgnoredKeys(w io.Writer, lang i18n.Lang, stack config.Stack) {
+	for _, ig := range stack.Ignored {
+		fmt.Fprintf(w, "%s: %s: %s\n", ig.Source, ig.Key, i18n.T(lang, "warn.project_key_ignored"))
+	}
+}
+
+func setTarget(file *config.File, name string, cfg config.Config) {
+	if name == "" {
+		file.Config = cfg
+		return
+	}
+	file.SetProfile(name, cfg)
+}
+
 func renderConfig(cfg config.Config) string {
-	return fmt.Sprintf("base_url = %q\napi_key = %q\nmodel = %q\n", cfg.BaseURL, cfg.APIKey, cfg.Model)
+	return config.Render(cfg)
 }
 
 func newConfigPathCommand(lang i18n.Lang) *cobra.Command {
@@ -103,7 +294,8 @@ func newConfigPathCommand(lang i18n.Lang) *cobra.Command {
 }
 
 func newConfigGetCommand(lang i18n.Lang) *cobra.Command {
-	return &cobra.Command{
+	var reveal bool
+	cmd := &cobra.Command{
 		Use:   "get <key>",
 		Short: i18n.T(lang, "cmd.config.get.short"),
 		Args:  cobra.ExactArgs(1),
@@ -112,21 +304,27 @@ func newConfigGetCommand(lang i18n.Lang) *cobra.Command {
 			if err != nil {
 				return err
 			}
-			cfg, err := config.Load(path)
+			file, err := readConfigFile(cmd, lang, path, true)
+			if err != nil {
+				return err
+			}
+			cfg, err := file.Resolve(configProfile(cmd))
 			if err != nil {
-				if errors.Is(err, os.ErrNotExist) {
-					return fmt.Errorf("%s: %s", path, i18n.T(lang, "err.config_missing"))
-				}
 				return err
 			}
 			val, ok := config.GetByKey(cfg, args[0])
 			if !ok {
 				return fmt.Errorf("%s: %s", args[0], i18n.T(lang, "err.config_key"))
 			}
+			if !reveal && config.IsSecretKey(args[0]) {
+				val = config.MaskSecret(val)
+			}
 			fmt.Fprintln(cmd.OutOrStdout(), val)
 			return nil
 		},
 	}
+	cmd.Flags().BoolVar(&reveal, "reveal", false, i18n.T(lang, "cmd.config.reveal"))
+	return cmd
 }
 
 func newConfigSetCommand(lang i18n.Lang) *cobra.Command {
@@ -139,14 +337,20 @@ func newConfigSetCommand(lang i18n.Lang) *cobra.Command {
 			if err != nil {
 				return err
 			}
-			var cfg config.Config
-			if loaded, err := config.Load(path); err == nil {
-				cfg = loaded
+			file, err := readConfigFile(cmd, lang, path, false)
+			if err != nil {
+				return err
 			}
-			if !config.SetByKey(&cfg, args[0], args[1]) {
-				return fmt.Errorf("%s: %s", args[0], i18n.T(lang, "err.config_key"))
+			name := configProfile(cmd)
+			cfg, err := editTarget(lang, file, name)
+			if err != nil {
+				return err
+			}
+			if err := setConfigKey(cmd, lang, &cfg, args[0], args[1]); err != nil {
+				return err
 			}
-			if err := config.Save(path, cfg); err != nil {
+			setTarget(file, name, cfg)
+			if err := file.Save(path); err != nil {
 				return err
 			}
 			fmt.Fprintf(cmd.ErrOrStderr(), "%s\n", i18n.T(lang, "msg.config_saved"))
@@ -154,3 +358,16 @@ func newConfigSetCommand(lang i18n.Lang) *cobra.Command {
 		},
 	}
 }
+
+func setConfigKey(cmd *cobra.Command, lang i18n.Lang, cfg *config.Config, key, value string) error {
+	if err := validateCommandKey(cmd, key, value); err != nil {
+		return err
+	}
+	if err := config.SetByKey(cfg, key, value); err != nil {
+		if errors.Is(err, config.ErrUnknownKey) {
+			return fmt.Errorf("%s: %s", key, i18n.T(lang, "err.config_key"))
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,228 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/yjhatfdu/aip/internal/config"
+	"github.com/yjhatfdu/aip/internal/i18n"
+	"github.com/yjhatfdu/aip/internal/llm"
+)
+
+type doctorReport struct {
+	out    io.Writer
+	lang   i18n.Lang
+	failed int
+}
+
+func (r *doctorReport) add(status, name, key string, args ...any) {
+	if status == "fail" {
+		r.failed++
+	}
+	fmt.Fprintf(r.out, "%-6s %-9s %s\n", "["+status+"]", name, fmt.Sprintf(i18n.T(r.lang, key), args...))
+}
+
+func newConfigDoctorCommand(lang i18n.Lang) *cobra.Command {
+	var llmOpts llmFlags
+	cmd := &cobra.Command{
+		Use:   "doctor",
+		Short: i18n.T(lang, "cmd.config.doctor.short"),
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			r := &doctorReport{out: cmd.OutOrStdout(), lang: lang}
+			stack, err := llmOpts.stack()
+			if err != nil {
+				r.add("fail", "config", "doctor.config_error", err)
+				return fmt.Errorf(i18n.T(lang, "doctor.failed"), r.failed)
+			}
+			cfg := stack.Merged()
+			checkDoctorConfig(cmd.Context(), r, stack, &cfg)
+			if r.failed == 0 {
+				llmOpts.noCache = true
+				checkDoctorEndpoint(cmd.Context(), r, &llmOpts, cfg)
+			} else {
+				r.add("warn", "connect", "doctor.skipped")
+			}
+			if r.failed > 0 {
+				return fmt.Errorf(i18n.T(lang, "doctor.failed"), r.failed)
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), i18n.T(lang, "doctor.passed"))
+			return nil
+		},
+	}
+	llmOpts.register(cmd, lang)
+	return cmd
+}
+
+// checkDoctorConfig validates the merged settings without network access,
+// resolving the API key into cfg.
+func checkDoctorConfig(ctx context.Context, r *doctorReport, stack config.Stack, cfg *config.Config) {
+	provider := cfg.Provider
+	if provider == "" {
+		provider = llm.ProviderOpenAI
+	}
+	known := false
+	for _, name := range llm.Providers() {
+		known = known || name == provider
+	}
+	if !known {
+		r.add("fail", "provider", "doctor.provider_unknown", provider, strings.Join(llm.Providers(), ", "))
+	} else {
+		r.add("ok", "provider", "doctor.value", provider)
+	}
+	for _, err := range config.EnvErrors() {
+		r.add("warn", "env", "doctor.env_invalid", err)
+	}
+	for _, o := range stack.EnvOverrides() {
+		r.add("info", "env", "doctor.env_override", o.Var, o.Key, o.Replaced.Name+" "+o.Replaced.Source)
+	}
+
+	if cfg.Model == "" {
+		r.add("fail", "model", "doctor.missing_model")
+	}
+
+	switch {
+	case cfg.BaseURL != "":
+		checkDoctorURL(ctx, r, cfg.BaseURL, provider)
+	case provider == llm.ProviderOpenAI:
+		r.add("fail", "base_url", "doctor.missing_base_url")
+	}
+
+	if err := config.ResolveAPIKey(ctx, cfg); err != nil {
+		r.add("fail", "api_key", "doctor.api_key_error", err)
+	} else if cfg.APIKey != "" {
+		r.add("ok", "api_key", "doctor.value", config.MaskSecret(cfg.APIKey))
+	} else if provider == llm.ProviderOpenAI || provider == llm.ProviderAnthropic {
+		r.add("fail", "api_key", "doctor.missing_api_key")
+	}
+}
+
+func checkDoctorURL(ctx context.Context, r *doctorReport, raw, provider string) {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		r.add("fail", "base_url", "doctor.bad_url", raw)
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if _, err := net.DefaultResolver.LookupHost(ctx, u.Hostname()); err != nil {
+		r.add("fail", "base_url", "doctor.dns", u.Hostname(), err)
+		return
+	}
+	warned := false
+	if provider != llm.ProviderOllama && strings.HasSuffix(strings.TrimRight(u.Path, "/"), "/v1") {
+		r.add("warn", "base_url", "doctor.url_v1", raw)
+		warned = true
+	}
+	if u.Scheme == "http" && !isLoopback(u.Hostname()) && provider != llm.ProviderOllama {
+		r.add("warn", "base_url", "doctor.url_plain_http", raw)
+		warned = true
+	}
+	if !warned {
+		r.add("ok", "base_url", "doctor.value", raw)
+	}
+}
+
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// checkDoctorEndpoint lists the models where the provider supports it and
+// falls back to a 1-token completion.
+func checkDoctorEndpoint(ctx context.Context, r *doctorReport, f *llmFlags, cfg config.Config) {
+	opts, err := f.options(cfg)
+	if err != nil {
+		r.add("fail", "connect", "doctor.request_failed", err)
+		return
+	}
+	opts.Retry.MaxRetries = 0
+	provider, err := llm.New(opts)
+	if err != nil {
+		r.add("fail", "connect", "doctor.request_failed", err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	if lister, ok := provider.(llm.ModelLister); ok {
+		start := time.Now()
+		models, err := lister.ListModels(ctx)
+		latency := time.Since(start).Round(time.Millisecond)
+		var apiErr *llm.APIError
+		switch {
+		case err == nil:
+			r.add("ok", "connect", "doctor.models_ok", len(models), latency)
+			switch {
+			case llm.HasModel(models, cfg.Model):
+				r.add("ok", "model", "doctor.model_ok", cfg.Model)
+			// Aliases such as Anthropic's -latest names are not listed but
+			// still served.
+			case pingModel(ctx, provider) == nil:
+				r.add("ok", "model", "doctor.model_unlisted", cfg.Model)
+			default:
+				r.add("fail", "model", "doctor.model_missing", cfg.Model, len(models), modelExamples(models))
+			}
+			return
+		case errors.Is(err, llm.ErrModelsUnsupported),
+			errors.As(err, &apiErr) && (apiErr.StatusCode == http.StatusNotFound || apiErr.StatusCode == http.StatusMethodNotAllowed):
+			// No model listing here; a completion still proves access.
+		default:
+			reportDoctorError(r, cfg, err)
+			return
+		}
+	}
+
+	start := time.Now()
+	if err := pingModel(ctx, provider); err != nil {
+		reportDoctorError(r, cfg, err)
+		return
+	}
+	r.add("ok", "connect", "doctor.completion_ok", time.Since(start).Round(time.Millisecond))
+	r.add("ok", "model", "doctor.model_ok", cfg.Model)
+}
+
+// pingModel asks the configured model for a 1-token completion.
+func pingModel(ctx context.Context, provider llm.Provider) error {
+	_, err := provider.Complete(ctx, llm.ChatRequest{
+		Messages: []llm.ChatMessage{{Role: "user", Content: "ping"}},
+		Params:   llm.Params{MaxTokens: 1},
+	})
+	return err
+}
+
+func reportDoctorError(r *doctorReport, cfg config.Config, err error) {
+	var apiErr *llm.APIError
+	switch {
+	case errors.As(err, &apiErr) && apiErr.IsAuth():
+		r.add("fail", "auth", "doctor.auth_failed", err)
+	case errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound:
+		r.add("fail", "model", "doctor.not_found", err)
+	case errors.As(err, &apiErr):
+		r.add("fail", "connect", "doctor.request_failed", err)
+	default:
+		target := cfg.BaseURL
+		if target == "" {
+			target = cfg.Provider
+		}
+		r.add("fail", "connect", "doctor.unreachable", target, err)
+	}
+}
+
+func modelExamples(models []string) string {
+	if len(models) > 5 {
+		models = models[:5]
+	}
+	return strings.Join(models, ", ")
+}
@@ -0,0 +1,197 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yjhatfdu/aip/internal/i18n"
+)
+
+func doctorEnv(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	t.Setenv("AIP_LANG", "en")
+	t.Setenv("AIP_PROFILE", "")
+	for _, name := range []string{"AIP_PROVIDER", "AIP_BASE_URL", "OPENAI_BASE_URL", "AIP_API_KEY", "OPENAI_API_KEY", "AIP_MODEL"} {
+		t.Setenv(name, "")
+	}
+}
+
+func runDoctor(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	root := newRoot()
+	root.SetArgs(append([]string{"config", "doctor"}, args...))
+	out := &bytes.Buffer{}
+	root.SetOut(out)
+	root.SetErr(&bytes.Buffer{})
+	err := root.Execute()
+	return out.String(), err
+}
+
+func modelsServer(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error":{"message":"invalid api key"}}`))
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/models":
+			_, _ = w.Write([]byte(`{"data":[{"id":"gpt-4o"},{"id":"gpt-4o-mini"}]}`))
+		case "/v1/chat/completions":
+			// Serves an unlisted alias, like Anthropic's -latest names.
+			var req struct{ Model string }
+			_ = json.NewDecoder(r.Body).Decode(&req)
+			if req.Model != "gpt-4o-latest" {
+				w.WriteHeader(http.StatusNotFound)
+				_, _ = w.Write([]byte(`{"error":{"message":"model not found"}}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"p"}}]}`))
+		default:
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestConfigDoctorPasses(t *testing.T) {
+	doctorEnv(t)
+	server := modelsServer(t)
+	out, err := runDoctor(t, "--base-url", server.URL, "--api-key", "key", "--model", "gpt-4o")
+	if err != nil {
+		t.Fatalf("doctor error: %v\n%s", err, out)
+	}
+	for _, want := range []string{"[ok]   connect   listed 2 models", "[ok]   model     gpt-4o is available", "all checks passed"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("missing %q in:\n%s", want, out)
+		}
+	}
+}
+
+func TestConfigDoctorAcceptsUnlistedAlias(t *testing.T) {
+	doctorEnv(t)
+	server := modelsServer(t)
+	out, err := runDoctor(t, "--base-url", server.URL, "--api-key", "key", "--model", "gpt-4o-latest")
+	if err != nil || !strings.Contains(out, "[ok]   model     gpt-4o-latest is not listed by this endpoint but answered a 1-token completion") {
+		t.Fatalf("unlisted alias: err=%v\n%s", err, out)
+	}
+}
+
+func TestCheckDoctorURLReportsAllWarnings(t *testing.T) {
+	out := &bytes.Buffer{}
+	r := &doctorReport{out: out, lang: i18n.LangEN}
+	checkDoctorURL(context.Background(), r, "http://10.0.0.1/v1", "openai")
+	if !strings.Contains(out.String(), "ends with /v1") || !strings.Contains(out.String(), "plain http") || strings.Contains(out.String(), "[ok]") {
+		t.Fatalf("unexpected report:\n%s", out)
+	}
+}
+
+func TestConfigDoctorReportsFailures(t *testing.T) {
+	doctorEnv(t)
+	server := modelsServer(t)
+	out, err := runDoctor(t, "--base-url", server.URL, "--api-key", "key", "--model", "gpt-5")
+	if err == nil || !strings.Contains(out, "gpt-5 is not among the 2 models of this endpoint (e.g. gpt-4o, gpt-4o-mini)") {
+		t.Fatalf("missing model: err=%v\n%s", err, out)
+	}
+
+	out, err = runDoctor(t, "--base-url", server.URL, "--api-key", "wrong", "--model", "gpt-4o")
+	if err == nil || !strings.Contains(out, "[fail] auth      credentials rejected") {
+		t.Fatalf("bad key: err=%v\n%s", err, out)
+	}
+
+	out, err = runDoctor(t, "--base-url", "example.com", "--model", "gpt-4o")
+	if err == nil || !strings.Contains(out, `"example.com" is not an http(s) URL`) || !strings.Contains(out, "no API key") || !strings.Contains(out, "skipped") {
+		t.Fatalf("bad config: err=%v\n%s", err, out)
+	}
+}
+
+func TestConfigDoctorFallsBackToCompletion(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/openai/deployments/gpt4o/chat/completions" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		_, _ = w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"p"}}]}`))
+	}))
+	t.Cleanup(server.Close)
+
+	doctorEnv(t)
+	root := newRoot()
+	root.SetArgs([]string{"config", "set", "path", "/openai/deployments/{model}/chat/completions"})
+	root.SetOut(&bytes.Buffer{})
+	root.SetErr(&bytes.Buffer{})
+	if err := root.Execute(); err != nil {
+		t.Fatalf("config set error: %v", err)
+	}
+	out, err := runDoctor(t, "--base-url", server.URL, "--api-key", "key", "--model", "gpt4o")
+	if err != nil {
+		t.Fatalf("doctor error: %v\n%s", err, out)
+	}
+	if !strings.Contains(out, "1-token completion in") {
+		t.Fatalf("unexpected output:\n%s", out)
+	}
+}
+
+func TestConfigDoctorFakeProvider(t *testing.T) {
+	doctorEnv(t)
+	out, err := runDoctor(t, "--provider", "fake", "--model", "fake")
+	if err != nil || !strings.Contains(out, "all checks passed") {
+		t.Fatalf("fake provider: err=%v\n%s", err, out)
+	}
+}
+
+func TestConfigDoctorCancelsAPIKeyCmd(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	doctorEnv(t)
+	root := newRoot()
+	root.SetArgs([]string{"config", "set", "api_key_cmd", "exec sleep 20"})
+	root.SetOut(&bytes.Buffer{})
+	root.SetErr(&bytes.Buffer{})
+	if err := root.Execute(); err != nil {
+		t.Fatalf("config set error: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	root = newRoot()
+	root.SetArgs([]string{"config", "doctor", "--provider", "fake", "--model", "fake"})
+	out := &bytes.Buffer{}
+	root.SetOut(out)
+	root.SetErr(&bytes.Buffer{})
+	start := time.Now()
+	err := root.ExecuteContext(ctx)
+	if err == nil || !strings.Contains(out.String(), "[fail] api_key") {
+		t.Fatalf("expected api_key_cmd to fail: err=%v\n%s", err, out)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Fatalf("api_key_cmd was not cancelled with the command context (%s)", elapsed)
+	}
+}
+
+func TestWarnInsecureConfigUsesLang(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no unix permissions")
+	}
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(path, []byte("model = \"m\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	warnInsecureConfig(&out, i18n.LangZH, path)
+	if !strings.Contains(out.String(), i18n.T(i18n.LangZH, "warn.config_perms")) {
+		t.Fatalf("warning not in the command language: %q", out.String())
+	}
+}
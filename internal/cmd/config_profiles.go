@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/yjhatfdu/aip/internal/config"
+	"github.com/yjhatfdu/aip/internal/i18n"
+)
+
+func newConfigProfilesCommand(lang i18n.Lang) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "profiles",
+		Short: i18n.T(lang, "cmd.config.profiles.short"),
+	}
+	cmd.AddCommand(
+		&cobra.Command{
+			Use:   "ls",
+			Short: i18n.T(lang, "cmd.config.profiles.ls"),
+			Args:  cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				_, file, err := loadProfilesFile(cmd, lang)
+				if err != nil {
+					return err
+				}
+				for _, name := range file.ProfileNames() {
+					mark := " "
+					if name == file.DefaultProfile {
+						mark = "*"
+					}
+					fmt.Fprintf(cmd.OutOrStdout(), "%s %s\n", mark, name)
+				}
+				return nil
+			},
+		},
+		&cobra.Command{
+			Use:   "use <name>",
+			Short: i18n.T(lang, "cmd.config.profiles.use"),
+			Args:  cobra.ExactArgs(1),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return editProfiles(cmd, lang, func(file *config.File) error {
+					if _, ok := file.Profile(args[0]); !ok {
+						return fmt.Errorf("%s: %s", args[0], i18n.T(lang, "err.profile_unknown"))
+					}
+					file.DefaultProfile = args[0]
+					return nil
+				})
+			},
+		},
+		&cobra.Command{
+			Use:   "add <name> [key=value...]",
+			Short: i18n.T(lang, "cmd.config.profiles.add"),
+			Args:  cobra.MinimumNArgs(1),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return editProfiles(cmd, lang, func(file *config.File) error {
+					name := args[0]
+					if _, ok := file.Profile(name); ok {
+						return fmt.Errorf("%s: %s", name, i18n.T(lang, "err.profile_exists"))
+					}
+					var cfg config.Config
+					for _, arg := range args[1:] {
+						key, value, ok := strings.Cut(arg, "=")
+						if !ok {
+							return fmt.Errorf("expected key=value, got %q", arg)
+						}
+						if err := setConfigKey(cmd, lang, &cfg, key, value); err != nil {
+							return err
+						}
+					}
+					file.SetProfile(name, cfg)
+					return nil
+				})
+			},
+		},
+		&cobra.Command{
+			Use:   "rm <name>",
+			Short: i18n.T(lang, "cmd.config.profiles.rm"),
+			Args:  cobra.ExactArgs(1),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return editProfiles(cmd, lang, func(file *config.File) error {
+					if !file.RemoveProfile(args[0]) {
+						return fmt.Errorf("%s: %s", args[0], i18n.T(lang, "err.profile_unknown"))
+					}
+					return nil
+				})
+			},
+		},
+	)
+	return cmd
+}
+
+func loadProfilesFile(cmd *cobra.Command, lang i18n.Lang) (string, *config.File, error) {
+	path, err := config.DefaultPath()
+	if err != nil {
+		return "", nil, err
+	}
+	file, err := readConfigFile(cmd, lang, path, false)
+	return path, file, err
+}
+
+func editProfiles(cmd *cobra.Command, lang i18n.Lang, edit func(*config.File) error) error {
+	path, file, err := loadProfilesFile(cmd, lang)
+	if err != nil {
+		return err
+	}
+	if err := edit(file); err != nil {
+		return err
+	}
+	if err := file.Save(path); err != nil {
+		return err
+	}
+	fmt.Fprintf(cmd.ErrOrStderr(), "%s\n", i18n.T(lang, "msg.config_saved"))
+	return nil
+}
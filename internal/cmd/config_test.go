@@ -2,9 +2,13 @@ package cmd
 
 import (
 	"bytes"
+	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"testing"
+
+	"github.com/yjhatfdu/aip/internal/i18n"
 )
 
 func TestConfigSetAndGet(t *testing.T) {
@@ -71,3 +75,293 @@ func TestConfigGetUnknownKey(t *testing.T) {
 		t.Fatal("expected error for unknown key")
 	}
 }
+
+func TestConfigSetMapKey(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	t.Setenv("AIP_LANG", "en")
+
+	root := newRoot()
+	root.SetArgs([]string{"config", "set", "headers.X-Team", "infra"})
+	root.SetOut(&bytes.Buffer{})
+	root.SetErr(&bytes.Buffer{})
+	if err := root.Execute(); err != nil {
+		t.Fatalf("config set error: %v", err)
+	}
+
+	root = newRoot()
+	root.SetArgs([]string{"config", "show"})
+	out := &bytes.Buffer{}
+	root.SetOut(out)
+	root.SetErr(&bytes.Buffer{})
+	if err := root.Execute(); err != nil {
+		t.Fatalf("config show error: %v", err)
+	}
+	if !strings.Contains(out.String(), `headers."X-Team" = "infra"`) {
+		t.Fatalf("config show missing header: %q", out.String())
+	}
+}
+
+func TestConfigProfiles(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	t.Setenv("AIP_LANG", "en")
+	t.Setenv("AIP_PROFILE", "")
+
+	run := func(args ...string) (string, error) {
+		root := newRoot()
+		root.SetArgs(args)
+		out := &bytes.Buffer{}
+		root.SetOut(out)
+		root.SetErr(&bytes.Buffer{})
+		err := root.Execute()
+		return out.String(), err
+	}
+	for _, args := range [][]string{
+		{"config", "set", "model", "top-model"},
+		{"config", "profiles", "add", "local", "provider=fake", "model=local-model"},
+		{"config", "profiles", "add", "team"},
+		{"config", "--config-profile", "team", "set", "model", "team-model"},
+		{"config", "profiles", "use", "local"},
+	} {
+		if _, err := run(args...); err != nil {
+			t.Fatalf("%v: %v", args, err)
+		}
+	}
+	if _, err := run("config", "profiles", "add", "team"); err == nil || !strings.Contains(err.Error(), "profile already exists") {
+		t.Fatalf("expected duplicate profile error, got %v", err)
+	}
+	if _, err := run("config", "profiles", "use", "nope"); err == nil {
+		t.Fatal("expected unknown profile error")
+	}
+
+	out, err := run("config", "profiles", "ls")
+	if err != nil || out != "* local\n  team\n" {
+		t.Fatalf("profiles ls = %q, %v", out, err)
+	}
+	for profile, want := range map[string]string{"": "local-model", "team": "team-model"} {
+		args := []string{"config", "get", "model"}
+		if profile != "" {
+			args = append(args, "--config-profile", profile)
+		}
+		if out, err := run(args...); err != nil || strings.TrimSpace(out) != want {
+			t.Fatalf("model for %q = %q, %v", profile, out, err)
+		}
+	}
+
+	root := newRoot()
+	root.SetArgs([]string{"summary", "hi", "--format", "json", "--no-cache"})
+	root.SetIn(strings.NewReader("hello\n"))
+	out2 := &bytes.Buffer{}
+	root.SetOut(out2)
+	root.SetErr(&bytes.Buffer{})
+	if err := root.Execute(); err != nil {
+		t.Fatalf("summary with default profile: %v", err)
+	}
+	if !strings.Contains(out2.String(), `"model":"local-model"`) {
+		t.Fatalf("default profile not applied: %q", out2.String())
+	}
+
+	if _, err := run("config", "profiles", "rm", "local"); err != nil {
+		t.Fatalf("profiles rm: %v", err)
+	}
+	out, err = run("config", "show")
+	if err != nil || strings.Contains(out, "default_profile") || !strings.Contains(out, "[profiles.team]") {
+		t.Fatalf("config show after rm = %q, %v", out, err)
+	}
+}
+
+func TestConfigMasksSecrets(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	t.Setenv("AIP_LANG", "en")
+	t.Setenv("AIP_PROFILE", "")
+
+	run := func(args ...string) (string, string, error) {
+		root := newRoot()
+		root.SetArgs(args)
+		out, errOut := &bytes.Buffer{}, &bytes.Buffer{}
+		root.SetOut(out)
+		root.SetErr(errOut)
+		err := root.Execute()
+		return out.String(), errOut.String(), err
+	}
+	if _, _, err := run("config", "set", "api_key", "sk-proj-1234567890abcd"); err != nil {
+		t.Fatalf("config set: %v", err)
+	}
+	for _, args := range [][]string{{"config", "show"}, {"config", "show", "--merged"}, {"config", "get", "api_key"}} {
+		out, _, err := run(args...)
+		if err != nil || strings.Contains(out, "1234567890") || !strings.Contains(out, "sk-********abcd") {
+			t.Fatalf("%v not masked: %q, %v", args, out, err)
+		}
+	}
+	out, _, err := run("config", "get", "api_key", "--reveal")
+	if err != nil || strings.TrimSpace(out) != "sk-proj-1234567890abcd" {
+		t.Fatalf("--reveal = %q, %v", out, err)
+	}
+
+	if runtime.GOOS == "windows" {
+		return
+	}
+	path := filepath.Join(home, ".aip", "config.toml")
+	if err := os.Chmod(path, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, errOut, _ := run("config", "show"); !strings.Contains(errOut, "readable by group or others") {
+		t.Fatalf("missing permission warning: %q", errOut)
+	}
+}
+
+func TestConfigShowOriginWithProjectFile(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	t.Setenv("AIP_LANG", "en")
+	t.Setenv("AIP_PROFILE", "")
+	t.Setenv("AIP_MODEL", "")
+	t.Setenv("AIP_BASE_URL", "https://env.example.com")
+
+	repo := filepath.Join(t.TempDir(), "repo")
+	sub := filepath.Join(repo, "src")
+	if err := os.MkdirAll(sub, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(repo, ".aip.toml"), []byte("model = \"project-model\"\napi_key_cmd = \"echo leaked\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(sub); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	root := newRoot()
+	root.SetArgs([]string{"config", "set", "model", "user-model"})
+	root.SetOut(&bytes.Buffer{})
+	root.SetErr(&bytes.Buffer{})
+	if err := root.Execute(); err != nil {
+		t.Fatalf("config set error: %v", err)
+	}
+
+	root = newRoot()
+	root.SetArgs([]string{"config", "show", "--origin"})
+	out, errOut := &bytes.Buffer{}, &bytes.Buffer{}
+	root.SetOut(out)
+	root.SetErr(errOut)
+	if err := root.Execute(); err != nil {
+		t.Fatalf("config show error: %v", err)
+	}
+	if strings.Contains(out.String(), "api_key_cmd") || !strings.Contains(errOut.String(), "api_key_cmd: warning: ignored in a project file") {
+		t.Fatalf("project api_key_cmd not ignored:\n%s\nstderr: %s", out.String(), errOut.String())
+	}
+	for _, want := range []string{
+		`model = "project-model"  # project `,
+		`base_url = "https://env.example.com"  # env`,
+		`provider = "openai"  # default`,
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("missing %q in:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestCommandDefaultsSkipLLMFlagsAndBadConfig(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	t.Setenv("AIP_LANG", "en")
+	t.Setenv("AIP_PROFILE", "")
+
+	repo := t.TempDir()
+	if err := os.WriteFile(filepath.Join(repo, ".aip.toml"), []byte("[summary]\nbase_url = \"https://evil.example\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(repo); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	run := func(in string, args ...string) (string, string, error) {
+		root := newRoot()
+		root.SetArgs(args)
+		root.SetIn(strings.NewReader(in))
+		out, errOut := &bytes.Buffer{}, &bytes.Buffer{}
+		root.SetOut(out)
+		root.SetErr(errOut)
+		err := root.Execute()
+		return out.String(), errOut.String(), err
+	}
+	_, _, err = run("hello\n", "summary", "summarize", "--provider", "fake", "--model", "m")
+	if err == nil || !strings.Contains(err.Error(), "--base-url is an LLM flag") {
+		t.Fatalf("expected the [summary] base_url to be rejected, got %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(repo, ".aip.toml"), []byte("[norm\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out, errOut, err := run("2024-01-02T03:04:05Z test\n", "norm", "--emit", "sig")
+	if err != nil || !strings.Contains(out, "<ts> test") || !strings.Contains(errOut, "command defaults from config not applied") {
+		t.Fatalf("norm with a malformed config: %q, %q, %v", out, errOut, err)
+	}
+}
+
+func TestCommandDefaultsResolvePathsAgainstProjectFile(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	t.Setenv("AIP_LANG", "en")
+	t.Setenv("AIP_PROFILE", "")
+
+	repo := t.TempDir()
+	rules := "version: 1\nrules:\n  - name: order\n    type: regex\n    pattern: 'order-\\d+'\n    replace: '<order>'\n"
+	if err := os.WriteFile(filepath.Join(repo, "ours.yaml"), []byte(rules), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(repo, ".aip.toml"), []byte("[norm]\nrules = \"ours.yaml\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	sub := filepath.Join(repo, "src", "app")
+	if err := os.MkdirAll(sub, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(sub); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	root := newRoot()
+	root.SetArgs([]string{"norm", "--emit", "sig"})
+	root.SetIn(strings.NewReader("shipped order-17\n"))
+	out := &bytes.Buffer{}
+	root.SetOut(out)
+	root.SetErr(&bytes.Buffer{})
+	if err := root.Execute(); err != nil {
+		t.Fatalf("norm from a subdirectory: %v", err)
+	}
+	if got := strings.TrimSpace(out.String()); got != "shipped <order>" {
+		t.Fatalf("sig = %q", got)
+	}
+
+	summary := newSummaryCommand(i18n.LangEN)
+	system := summary.Flags().Lookup("system")
+	if got := resolveConfigPath(system, "@prompts/triage.txt", repo); got != "@"+filepath.Join(repo, "prompts", "triage.txt") {
+		t.Fatalf("system = %q", got)
+	}
+	if got := resolveConfigPath(system, "be brief", repo); got != "be brief" {
+		t.Fatalf("inline system = %q", got)
+	}
+}
@@ -0,0 +1,174 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/yjhatfdu/aip/internal/cluster"
+	"github.com/yjhatfdu/aip/internal/diagnose"
+	"github.com/yjhatfdu/aip/internal/i18n"
+	"github.com/yjhatfdu/aip/internal/llm"
+	"github.com/yjhatfdu/aip/internal/norm"
+	"github.com/yjhatfdu/aip/internal/sampling"
+)
+
+func newDiagnoseCommand(lang i18n.Lang) *cobra.Command {
+	var (
+		profile   string
+		rulesPath string
+		top       int
+		per       int
+		threshold int
+		focus     string
+		format    string
+		llmOpts   llmFlags
+	)
+
+	cmd := &cobra.Command{
+		Use:   "diagnose [file]",
+		Short: i18n.T(lang, "cmd.diagnose.short"),
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if format == "" {
+				format = "text"
+			}
+			if format != "text" && format != "markdown" && format != "json" {
+				return fmt.Errorf("unknown format: %s", format)
+			}
+			n, err := norm.New(profile, rulesPath, "")
+			if err != nil {
+				return err
+			}
+
+			var (
+				reader  io.Reader = cmd.InOrStdin()
+				srcFile           = ""
+			)
+			if len(args) == 1 {
+				file, err := os.Open(args[0])
+				if err != nil {
+					return err
+				}
+				defer file.Close()
+				reader = file
+				srcFile = args[0]
+			}
+
+			client, err := llmOpts.client()
+			if err != nil {
+				return err
+			}
+
+			sigs := newSigAggregator()
+			sampler, err := sampling.NewSampler(per, sampling.MethodReservoir, 1, norm.ParseTime)
+			if err != nil {
+				return err
+			}
+			lines := 0
+			err = scanEvents(reader, n.Assembler(), 0, func(ev norm.Event) error {
+				lines += ev.Lines
+				if strings.TrimSpace(ev.Text) == "" {
+					return nil
+				}
+				rec := n.Normalize(ev.Text, norm.Source{File: srcFile, Line: ev.Line})
+				sigs.add(rec)
+				sampler.Add(rec.Sig, sampling.Item{TS: rec.TS, Raw: rec.Raw, File: rec.Src.File, Line: rec.Src.Line})
+				return nil
+			})
+			if err != nil && !errors.Is(err, io.EOF) {
+				if srcFile != "" {
+					return fmt.Errorf("%s: %w", srcFile, err)
+				}
+				return err
+			}
+			infos := sigs.infos()
+			if len(infos) == 0 {
+				return errors.New("empty input")
+			}
+
+			clusters, err := cluster.ClusterSigs(infos, cluster.Params{
+				Threshold:  threshold,
+				Bands:      8,
+				BandBits:   8,
+				MinCluster: 1,
+			})
+			if err != nil {
+				return err
+			}
+			stats := diagnose.Stats{Lines: lines, Signatures: len(infos), Clusters: len(clusters)}
+			if top > 0 && len(clusters) > top {
+				clusters = clusters[:top]
+			}
+			evidence := make([]diagnose.ClusterEvidence, 0, len(clusters))
+			for i, c := range clusters {
+				var candidates []sampling.Item
+				for _, sig := range c.Members {
+					candidates = append(candidates, sampler.Group(sig).Samples...)
+				}
+				evidence = append(evidence, diagnose.ClusterEvidence{
+					ID:      i + 1,
+					Cluster: c,
+					Samples: sampling.Stratify(candidates, per, norm.ParseTime),
+				})
+			}
+
+			ctx, cancel := context.WithTimeout(cmd.Context(), 2*time.Minute)
+			defer cancel()
+			resp, err := client.Complete(ctx, llm.ChatRequest{
+				Messages: []llm.ChatMessage{
+					{Role: "system", Content: diagnose.SystemPrompt()},
+					{Role: "user", Content: diagnose.BuildUserPrompt(focus, stats, evidence)},
+				},
+			})
+			if err != nil {
+				return err
+			}
+			if len(resp.Choices) == 0 {
+				return errors.New("empty response")
+			}
+			output := resp.Choices[0].Message.Content
+			report, err := diagnose.Parse(output)
+			if err != nil {
+				fmt.Fprintf(cmd.ErrOrStderr(), "diagnose: %v; showing raw model output\n", err)
+				report = diagnose.Report{Summary: strings.TrimSpace(output)}
+			}
+			diagnose.Resolve(&report, evidence)
+			stats.Model = resp.Model
+			stats.Usage = resp.Usage
+			stats.CostUSD = resp.Metrics.CostUSD
+			stats.LatencyMS = resp.Metrics.Latency.Milliseconds()
+			stats.TTFTMS = resp.Metrics.TTFT.Milliseconds()
+			report.Stats = stats
+
+			out := cmd.OutOrStdout()
+			switch format {
+			case "json":
+				enc := json.NewEncoder(out)
+				enc.SetEscapeHTML(false)
+				return enc.Encode(report)
+			case "markdown":
+				_, err = io.WriteString(out, diagnose.RenderMarkdown(report))
+			default:
+				_, err = io.WriteString(out, diagnose.RenderText(report))
+			}
+			return err
+		},
+	}
+
+	cmd.Flags().StringVar(&profile, "profile", "generic", "norm profile "+normProfiles())
+	cmd.Flags().StringVar(&rulesPath, "rules", "", "rules file path (YAML)")
+	cmd.Flags().IntVar(&top, "top", 15, "clusters sent to the model")
+	cmd.Flags().IntVar(&per, "per", 3, "sample lines per cluster")
+	cmd.Flags().IntVar(&threshold, "threshold", 4, "simhash hamming distance threshold")
+	cmd.Flags().StringVar(&focus, "focus", "", "extra instruction, e.g. \"slow queries after 09:00\"")
+	cmd.Flags().StringVar(&format, "format", "text", "format: text|markdown|json")
+	llmOpts.register(cmd, lang)
+	return cmd
+}
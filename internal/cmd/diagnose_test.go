@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/yjhatfdu/aip/internal/diagnose"
+	"github.com/yjhatfdu/aip/internal/llm"
+)
+
+func TestDiagnoseCommandJSON(t *testing.T) {
+	var prompt string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req llm.ChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode: %v", err)
+			return
+		}
+		prompt = req.Messages[len(req.Messages)-1].Content
+		content := `{"summary":"connections refused","root_causes":[{"title":"db down","confidence":"high","clusters":[1],"evidence":[{"line":2}]}],"next_steps":["check the database"]}`
+		resp := llm.ChatResponse{
+			Model: req.Model,
+			Choices: []struct {
+				Message llm.ChatMessage `json:"message"`
+			}{
+				{Message: llm.ChatMessage{Role: "assistant", Content: content}},
+			},
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(server.Close)
+
+	input := strings.Join([]string{
+		"2024-01-01T00:00:01Z conn 1 refused",
+		"2024-01-01T00:00:02Z conn 2 refused",
+		"2024-01-01T00:00:03Z cache warmed",
+	}, "\n") + "\n"
+
+	root := newRoot()
+	root.SetArgs([]string{
+		"diagnose",
+		"--format", "json",
+		"--base-url", server.URL,
+		"--api-key", "key",
+		"--model", "model",
+	})
+	root.SetIn(strings.NewReader(input))
+	out := &bytes.Buffer{}
+	root.SetOut(out)
+	root.SetErr(&bytes.Buffer{})
+
+	if err := root.Execute(); err != nil {
+		t.Fatalf("diagnose error: %v", err)
+	}
+	if !strings.Contains(prompt, "[C1] count=2") || !strings.Contains(prompt, "L2: 2024-01-01T00:00:02Z conn 2 refused") {
+		t.Fatalf("unexpected prompt: %q", prompt)
+	}
+	var report diagnose.Report
+	if err := json.Unmarshal(out.Bytes(), &report); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if len(report.RootCauses) != 1 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	rc := report.RootCauses[0]
+	if len(rc.Evidence) != 1 || rc.Evidence[0].Text != "2024-01-01T00:00:02Z conn 2 refused" {
+		t.Fatalf("unexpected evidence: %+v", rc.Evidence)
+	}
+	if len(rc.TimeRanges) != 1 || rc.TimeRanges[0].Start != "2024-01-01T00:00:01Z" {
+		t.Fatalf("unexpected time ranges: %+v", rc.TimeRanges)
+	}
+	if report.Stats.Lines != 3 || report.Stats.Signatures != 2 {
+		t.Fatalf("unexpected stats: %+v", report.Stats)
+	}
+}
+
+func TestDiagnoseConfigProfileFlag(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	t.Setenv("AIP_LANG", "en")
+	t.Setenv("AIP_PROFILE", "")
+
+	var model string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req llm.ChatRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		model = req.Model
+		resp := llm.ChatResponse{Model: req.Model}
+		resp.Choices = append(resp.Choices, struct {
+			Message llm.ChatMessage `json:"message"`
+		}{Message: llm.ChatMessage{Role: "assistant", Content: `{"summary":"ok","root_causes":[],"next_steps":[]}`}})
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(server.Close)
+
+	for _, args := range [][]string{
+		{"config", "set", "model", "top-model"},
+		{"config", "profiles", "add", "ops", "base_url=" + server.URL, "api_key=key", "model=ops-model"},
+	} {
+		root := newRoot()
+		root.SetArgs(args)
+		root.SetOut(&bytes.Buffer{})
+		root.SetErr(&bytes.Buffer{})
+		if err := root.Execute(); err != nil {
+			t.Fatalf("%v: %v", args, err)
+		}
+	}
+
+	root := newRoot()
+	root.SetArgs([]string{"diagnose", "--config-profile", "ops", "--profile", "postgres", "--format", "json", "--no-cache"})
+	root.SetIn(strings.NewReader("2024-01-01 00:00:01.000 UTC [42] ERROR:  relation \"users\" does not exist\n"))
+	root.SetOut(&bytes.Buffer{})
+	root.SetErr(&bytes.Buffer{})
+	if err := root.Execute(); err != nil {
+		t.Fatalf("diagnose error: %v", err)
+	}
+	if model != "ops-model" {
+		t.Fatalf("--config-profile not applied next to --profile: model %q", model)
+	}
+}
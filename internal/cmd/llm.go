@@ -0,0 +1,220 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/yjhatfdu/aip/internal/cache"
+	"github.com/yjhatfdu/aip/internal/config"
+	"github.com/yjhatfdu/aip/internal/i18n"
+	"github.com/yjhatfdu/aip/internal/llm"
+	"github.com/yjhatfdu/aip/internal/tokens"
+)
+
+type llmFlags struct {
+	provider string
+	baseURL  string
+	apiKey   string
+	model    string
+	noCache  bool
+	retries  int
+	stats    bool
+	meter    *llm.Meter
+
+	temperature float64
+	maxTokens   int
+	topP        float64
+	seed        int64
+	stop        []string
+	cmd         *cobra.Command
+	flags       *pflag.FlagSet
+	lang        i18n.Lang
+}
+
+// llmFlagAnnotation marks the flags added by register; command sections of
+// the config cannot default them.
+const llmFlagAnnotation = "aip_llm_flag"
+
+func (f *llmFlags) register(cmd *cobra.Command, lang i18n.Lang) {
+	cmd.Flags().StringVar(&f.provider, "provider", "", "LLM provider openai|anthropic|ollama|fake")
+	cmd.Flags().StringVar(&f.baseURL, "base-url", "", "LLM base URL")
+	cmd.Flags().StringVar(&f.apiKey, "api-key", "", "LLM API key")
+	cmd.Flags().StringVar(&f.model, "model", "", "LLM model")
+	cmd.Flags().BoolVar(&f.noCache, "no-cache", false, "bypass the on-disk response cache")
+	cmd.Flags().BoolVar(&f.stats, "stats", false, "print calls, tokens, cost and latency on stderr when done")
+	cmd.Flags().IntVar(&f.retries, "retries", -1, "retries on rate limits and server errors (-1 = config or default)")
+	cmd.Flags().Float64Var(&f.temperature, "temperature", 0, "sampling temperature (default from config or provider)")
+	cmd.Flags().IntVar(&f.maxTokens, "max-tokens", 0, "max output tokens (default from config or provider)")
+	cmd.Flags().Float64Var(&f.topP, "top-p", 0, "nucleus sampling top_p (default from config or provider)")
+	cmd.Flags().Int64Var(&f.seed, "seed", 0, "sampling seed, where supported")
+	cmd.Flags().StringSliceVar(&f.stop, "stop", nil, "stop sequences")
+	for _, name := range []string{"provider", "base-url", "api-key", "model", "no-cache", "stats", "retries", "temperature", "max-tokens", "top-p", "seed", "stop"} {
+		_ = cmd.Flags().SetAnnotation(name, llmFlagAnnotation, []string{"true"})
+	}
+	f.cmd = cmd
+	f.flags = cmd.Flags()
+	f.lang = lang
+	// Stats are printed from a deferred call so a run that fails partway
+	// still reports the calls it made; cobra skips PostRun on errors.
+	if run := cmd.RunE; run != nil {
+		cmd.RunE = func(cmd *cobra.Command, args []string) error {
+			defer func() {
+				if f.stats && f.meter != nil {
+					writeLLMStats(cmd.ErrOrStderr(), f.meter.Totals())
+				}
+			}()
+			return run(cmd, args)
+		}
+	}
+}
+
+// llmMetrics is embedded in JSON output next to the token usage.
+type llmMetrics struct {
+	CostUSD   *float64 `json:"cost_usd,omitempty"`
+	LatencyMS int64    `json:"latency_ms,omitempty"`
+	TTFTMS    int64    `json:"ttft_ms,omitempty"`
+}
+
+func newLLMMetrics(m llm.Metrics) llmMetrics {
+	return llmMetrics{CostUSD: m.CostUSD, LatencyMS: m.Latency.Milliseconds(), TTFTMS: m.TTFT.Milliseconds()}
+}
+
+func writeLLMStats(w io.Writer, t llm.MeterTotals) {
+	cost := "n/a"
+	if t.CostUSD != nil {
+		cost = fmt.Sprintf("$%.6f", *t.CostUSD)
+	}
+	fmt.Fprintf(w, "stats: calls=%d cached=%d tokens=%d+%d cost=%s latency=%s ttft=%s\n",
+		t.Calls, t.Cached, t.Usage.PromptTokens, t.Usage.CompletionTokens, cost,
+		t.Latency.Round(time.Millisecond), t.TTFT.Round(time.Millisecond))
+}
+
+// params merges generation defaults from config with explicitly set flags.
+func (f *llmFlags) params(cfg config.Config) llm.Params {
+	p := llm.Params{
+		Temperature: cfg.Temperature,
+		MaxTokens:   cfg.MaxTokens,
+		TopP:        cfg.TopP,
+		Seed:        cfg.Seed,
+		Stop:        cfg.Stop,
+	}
+	if f.flags == nil {
+		return p
+	}
+	if f.flags.Changed("temperature") {
+		p.Temperature = &f.temperature
+	}
+	if f.flags.Changed("max-tokens") {
+		p.MaxTokens = f.maxTokens
+	}
+	if f.flags.Changed("top-p") {
+		p.TopP = &f.topP
+	}
+	if f.flags.Changed("seed") {
+		p.Seed = &f.seed
+	}
+	if f.flags.Changed("stop") {
+		p.Stop = f.stop
+	}
+	return p
+}
+
+// config returns the effective configuration: defaults, user file, project
+// file, environment, then flags.
+func (f *llmFlags) config() (config.Config, error) {
+	stack, err := f.stack()
+	if err != nil {
+		return config.Config{}, err
+	}
+	return stack.Merged(), nil
+}
+
+func (f *llmFlags) stack() (config.Stack, error) {
+	if path, err := config.DefaultPath(); err == nil {
+		warnInsecureConfig(f.cmd.ErrOrStderr(), f.lang, path)
+	}
+	stack, err := loadConfigStack(f.cmd)
+	if err != nil {
+		return config.Stack{}, err
+	}
+	warnIgnoredKeys(f.cmd.ErrOrStderr(), f.lang, stack)
+	stack.Add(config.LayerFlags, "", config.Config{
+		Provider: f.provider,
+		BaseURL:  f.baseURL,
+		APIKey:   f.apiKey,
+		Model:    f.model,
+	})
+	return stack, nil
+}
+
+// contextSize is the model's context window from config or the built-in
+// table, 0 when unknown.
+func contextSize(cfg config.Config) int {
+	if size, ok := cfg.ContextSizes[cfg.Model]; ok {
+		return size
+	}
+	return tokens.ContextSize(cfg.Model)
+}
+
+func (f *llmFlags) client() (llm.Provider, error) {
+	cfg, err := f.config()
+	if err != nil {
+		return nil, err
+	}
+	if err := config.ResolveAPIKey(context.Background(), &cfg); err != nil {
+		return nil, err
+	}
+	opts, err := f.options(cfg)
+	if err != nil {
+		return nil, err
+	}
+	provider, err := llm.New(opts)
+	if err != nil {
+		return nil, err
+	}
+	prices := make(map[string]llm.Price, len(cfg.Pricing))
+	for model, p := range cfg.Pricing {
+		prices[model] = llm.Price{Input: p.Input, Output: p.Output}
+	}
+	f.meter = llm.NewMeter(llm.WithParams(provider, f.params(cfg)), prices)
+	f.meter.Model = cfg.Model
+	return f.meter, nil
+}
+
+// options maps the effective config onto provider options, with the
+// response cache unless disabled.
+func (f *llmFlags) options(cfg config.Config) (llm.Options, error) {
+	opts := llm.Options{
+		Provider:   cfg.Provider,
+		BaseURL:    cfg.BaseURL,
+		APIKey:     cfg.APIKey,
+		Model:      cfg.Model,
+		NumCtx:     cfg.NumCtx,
+		KeepAlive:  cfg.KeepAlive,
+		Path:       cfg.Path,
+		Query:      cfg.Query,
+		AuthHeader: cfg.AuthHeader,
+		AuthScheme: cfg.AuthScheme,
+		Headers:    cfg.Headers,
+		Retry:      llm.DefaultRetryPolicy(),
+	}
+	if cfg.MaxRetries != nil {
+		opts.Retry.MaxRetries = *cfg.MaxRetries
+	}
+	if f.retries >= 0 {
+		opts.Retry.MaxRetries = f.retries
+	}
+	if !f.noCache && os.Getenv("AIP_NO_CACHE") == "" {
+		dir, err := cache.DefaultDir()
+		if err != nil {
+			return llm.Options{}, err
+		}
+		opts.Cache = cache.New(dir)
+	}
+	return opts, nil
+}
@@ -0,0 +1,17 @@
+package cmd
+
+import (
+	"os"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "aip-cache-")
+	if err != nil {
+		panic(err)
+	}
+	os.Setenv("AIP_CACHE_DIR", dir)
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
@@ -0,0 +1,210 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/yjhatfdu/aip/internal/chunk"
+	"github.com/yjhatfdu/aip/internal/i18n"
+	"github.com/yjhatfdu/aip/internal/llm"
+	"github.com/yjhatfdu/aip/internal/summary"
+)
+
+type mapResult struct {
+	Chunk     int       `json:"chunk"`
+	StartLine int       `json:"start_line"`
+	EndLine   int       `json:"end_line"`
+	Offset    int64     `json:"offset"`
+	Bytes     int       `json:"bytes"`
+	Output    string    `json:"output"`
+	Usage     llm.Usage `json:"usage,omitempty"`
+	Model     string    `json:"model,omitempty"`
+	llmMetrics
+}
+
+type mapJob struct {
+	done   chan struct{}
+	result mapResult
+	err    error
+}
+
+func newMapCommand(lang i18n.Lang) *cobra.Command {
+	var (
+		systemValue string
+		format      string
+		chunkChars  int
+		chunkTokens int
+		overlap     int
+		concurrency int
+		llmOpts     llmFlags
+	)
+
+	cmd := &cobra.Command{
+		Use:   "map <prompt> [file]",
+		Short: i18n.T(lang, "cmd.map.short"),
+		Args:  cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if format == "" {
+				format = "text"
+			}
+			if format != "text" && format != "jsonl" {
+				return fmt.Errorf("unknown format: %s", format)
+			}
+			if concurrency <= 0 {
+				concurrency = 1
+			}
+			userPrompt, err := summary.LoadPrompt(args[0])
+			if err != nil {
+				return err
+			}
+			systemPrompt := summary.BuildSystemPrompt()
+			if systemValue != "" {
+				systemPrompt, err = summary.LoadPrompt(systemValue)
+				if err != nil {
+					return err
+				}
+			}
+
+			var (
+				reader  io.Reader = cmd.InOrStdin()
+				srcFile           = ""
+			)
+			if len(args) == 2 {
+				file, err := os.Open(args[1])
+				if err != nil {
+					return err
+				}
+				defer file.Close()
+				reader = file
+				srcFile = args[1]
+			}
+
+			client, err := llmOpts.client()
+			if err != nil {
+				return err
+			}
+
+			ctx, cancel := context.WithCancel(cmd.Context())
+			defer cancel()
+
+			splitter := chunk.NewSplitter(reader, chunk.Options{
+				MaxChars:  chunkChars,
+				MaxTokens: chunkTokens,
+				Overlap:   overlap,
+			})
+			queue := make(chan *mapJob, concurrency)
+			sem := make(chan struct{}, concurrency)
+			readErr := make(chan error, 1)
+
+			go func() {
+				defer close(queue)
+				for {
+					c, err := splitter.Next()
+					if errors.Is(err, io.EOF) {
+						readErr <- nil
+						return
+					}
+					if err != nil {
+						readErr <- err
+						return
+					}
+					select {
+					case sem <- struct{}{}:
+					case <-ctx.Done():
+						readErr <- nil
+						return
+					}
+					job := &mapJob{done: make(chan struct{})}
+					go func(c chunk.Chunk) {
+						defer func() { <-sem }()
+						defer close(job.done)
+						job.result, job.err = runMapChunk(ctx, client, systemPrompt, userPrompt, c)
+					}(c)
+					select {
+					case queue <- job:
+					case <-ctx.Done():
+						readErr <- nil
+						return
+					}
+				}
+			}()
+
+			out := cmd.OutOrStdout()
+			enc := json.NewEncoder(out)
+			enc.SetEscapeHTML(false)
+			for job := range queue {
+				<-job.done
+				if job.err != nil {
+					cancel()
+					return fmt.Errorf("chunk %d (lines %d-%d): %w", job.result.Chunk, job.result.StartLine, job.result.EndLine, job.err)
+				}
+				switch format {
+				case "text":
+					text := job.result.Output
+					if !strings.HasSuffix(text, "\n") {
+						text += "\n"
+					}
+					if _, err := io.WriteString(out, text); err != nil {
+						return err
+					}
+				case "jsonl":
+					if err := enc.Encode(job.result); err != nil {
+						return err
+					}
+				}
+			}
+			if err := <-readErr; err != nil {
+				if srcFile != "" {
+					return fmt.Errorf("%s: %w", srcFile, err)
+				}
+				return err
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&systemValue, "system", "", "system prompt or @file")
+	cmd.Flags().StringVar(&format, "format", "text", "format: text|jsonl")
+	cmd.Flags().IntVar(&chunkChars, "chunk-chars", 8000, "max chars per chunk")
+	cmd.Flags().IntVar(&chunkTokens, "chunk-tokens", 0, "max estimated tokens per chunk (overrides --chunk-chars)")
+	cmd.Flags().IntVar(&overlap, "overlap", 0, "lines repeated from the previous chunk")
+	cmd.Flags().IntVar(&concurrency, "concurrency", 4, "parallel LLM requests")
+	llmOpts.register(cmd, lang)
+	return cmd
+}
+
+func runMapChunk(ctx context.Context, client llm.Provider, systemPrompt, userPrompt string, c chunk.Chunk) (mapResult, error) {
+	result := mapResult{
+		Chunk:     c.Index,
+		StartLine: c.StartLine,
+		EndLine:   c.EndLine,
+		Offset:    c.Offset,
+		Bytes:     len(c.Text),
+	}
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer cancel()
+	resp, err := client.Complete(ctx, llm.ChatRequest{
+		Messages: []llm.ChatMessage{
+			{Role: "system", Content: systemPrompt},
+			{Role: "user", Content: summary.BuildUserPrompt(userPrompt, c.Text)},
+		},
+	})
+	if err != nil {
+		return result, err
+	}
+	if len(resp.Choices) == 0 {
+		return result, errors.New("empty response")
+	}
+	result.Output = resp.Choices[0].Message.Content
+	result.Usage = resp.Usage
+	result.llmMetrics = newLLMMetrics(resp.Metrics)
+	result.Model = resp.Model
+	return result, nil
+}
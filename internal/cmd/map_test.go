@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yjhatfdu/aip/internal/llm"
+)
+
+func newMapTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req llm.ChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode: %v", err)
+			return
+		}
+		user := req.Messages[len(req.Messages)-1].Content
+		input := strings.TrimSpace(user[strings.Index(user, "INPUT:\n")+len("INPUT:\n"):])
+		first := strings.SplitN(input, "\n", 2)[0]
+		if first == "line1" {
+			time.Sleep(50 * time.Millisecond)
+		}
+		resp := llm.ChatResponse{
+			Model: req.Model,
+			Usage: llm.Usage{PromptTokens: 1, CompletionTokens: 1, TotalTokens: 2},
+			Choices: []struct {
+				Message llm.ChatMessage `json:"message"`
+			}{
+				{Message: llm.ChatMessage{Role: "assistant", Content: "seen " + first}},
+			},
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestMapCommandTextKeepsOrder(t *testing.T) {
+	server := newMapTestServer(t)
+
+	root := newRoot()
+	root.SetArgs([]string{
+		"map", "extract",
+		"--chunk-chars", "12",
+		"--concurrency", "4",
+		"--base-url", server.URL,
+		"--api-key", "key",
+		"--model", "model",
+	})
+	root.SetIn(strings.NewReader("line1\nline2\nline3\nline4\nline5\nline6\n"))
+	out := &bytes.Buffer{}
+	root.SetOut(out)
+	root.SetErr(&bytes.Buffer{})
+
+	if err := root.Execute(); err != nil {
+		t.Fatalf("map error: %v", err)
+	}
+	want := "seen line1\nseen line3\nseen line5\n"
+	if out.String() != want {
+		t.Fatalf("unexpected output: %q", out.String())
+	}
+}
+
+func TestMapCommandJSONL(t *testing.T) {
+	server := newMapTestServer(t)
+
+	root := newRoot()
+	root.SetArgs([]string{
+		"map", "extract",
+		"--format", "jsonl",
+		"--chunk-chars", "12",
+		"--overlap", "1",
+		"--base-url", server.URL,
+		"--api-key", "key",
+		"--model", "model",
+	})
+	root.SetIn(strings.NewReader("line1\nline2\nline3\n"))
+	out := &bytes.Buffer{}
+	root.SetOut(out)
+	root.SetErr(&bytes.Buffer{})
+
+	if err := root.Execute(); err != nil {
+		t.Fatalf("map error: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 results, got %q", out.String())
+	}
+	var second mapResult
+	if err := json.Unmarshal([]byte(lines[1]), &second); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if second.Chunk != 1 || second.StartLine != 2 || second.EndLine != 3 || second.Offset != 6 {
+		t.Fatalf("unexpected chunk position: %+v", second)
+	}
+	if second.Output != "seen line2" || second.Usage.TotalTokens != 2 {
+		t.Fatalf("unexpected result: %+v", second)
+	}
+}
@@ -2,12 +2,14 @@ package cmd
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/yjhatfdu/aip/internal/i18n"
@@ -20,18 +22,29 @@ func newNormCommand(lang i18n.Lang) *cobra.Command {
 		rulesPath string
 		emit      string
 		bucket    string
+
+		multiline    bool
+		flushTimeout time.Duration
+
+		miner      string
+		minerState string
+		drainOpts  norm.DrainOptions
+		input      norm.InputOptions
+
+		detectLines int
 	)
 
 	cmd := &cobra.Command{
 		Use:   "norm [file]",
 		Short: i18n.T(lang, "cmd.norm.short"),
 		Args:  cobra.MaximumNArgs(1),
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return applyCommandDefaults(cmd, lang, "norm")
+		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			n, err := norm.New(profile, rulesPath, bucket)
-			if err != nil {
-				return err
+			if profile == "auto" && detectLines < 1 {
+				return errors.New("--detect-lines must be > 0")
 			}
-
 			var (
 				reader  io.Reader = cmd.InOrStdin()
 				srcFile           = ""
@@ -46,26 +59,75 @@ func newNormCommand(lang i18n.Lang) *cobra.Command {
 				srcFile = args[0]
 			}
 
+			if !isStream(reader) {
+				flushTimeout = 0
+			}
+
+			var (
+				n   *norm.Normalizer
+				err error
+			)
+			if profile == "auto" {
+				var head []string
+				head, reader, err = peekLines(reader, detectLines, flushTimeout)
+				if err != nil {
+					return err
+				}
+				detection, err := norm.DetectProfile(head)
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(cmd.ErrOrStderr(), "norm: profile auto: %s\n", detection)
+				n, err = norm.NewDetected(detection, rulesPath, bucket)
+				if err != nil {
+					return err
+				}
+			} else if n, err = norm.New(profile, rulesPath, bucket); err != nil {
+				return err
+			}
+			if err := n.SetInput(input); err != nil {
+				return err
+			}
+
 			if emit == "" {
 				emit = "jsonl"
 			}
 
-			scanner := bufio.NewScanner(reader)
-			scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
-			line := 0
+			var drain *norm.Drain
+			switch miner {
+			case "", "rules":
+			case "drain":
+				if minerState != "" {
+					drain, err = norm.LoadDrain(minerState, drainOpts)
+				} else {
+					drain = norm.NewDrain(drainOpts)
+				}
+				if err != nil {
+					return err
+				}
+			default:
+				return fmt.Errorf("unknown miner: %s", miner)
+			}
+
 			out := cmd.OutOrStdout()
 			enc := json.NewEncoder(out)
 			enc.SetEscapeHTML(false)
 
-			for scanner.Scan() {
-				line++
-				record := n.Normalize(scanner.Text(), norm.Source{
+			var asm *norm.Assembler
+			if multiline {
+				asm = n.Assembler()
+			}
+			err = scanEvents(reader, asm, flushTimeout, func(ev norm.Event) error {
+				record := n.Normalize(ev.Text, norm.Source{
 					File: srcFile,
-					Line: line,
+					Line: ev.Line,
 				})
+				if drain != nil {
+					record = drain.Mine(record)
+				}
 				switch emit {
 				case "sig":
-					if _, err := fmt.Fprintln(out, record.Sig); err != nil {
+					if _, err := fmt.Fprintln(out, escapeNewlines(record.Sig)); err != nil {
 						return err
 					}
 				case "jsonl":
@@ -82,21 +144,254 @@ func newNormCommand(lang i18n.Lang) *cobra.Command {
 				default:
 					return fmt.Errorf("unknown emit: %s", emit)
 				}
-			}
-			if err := scanner.Err(); err != nil && !errors.Is(err, io.EOF) {
+				return nil
+			})
+			if err != nil && !errors.Is(err, io.EOF) {
 				return err
 			}
+			if drain != nil && minerState != "" {
+				return drain.Save(minerState)
+			}
 			return nil
 		},
 	}
 
-	cmd.Flags().StringVar(&profile, "profile", "generic", "norm profile generic|postgres|kernel")
+	cmd.Flags().StringVar(&profile, "profile", "generic", "norm profile "+normProfiles()+"|auto")
+	cmd.Flags().IntVar(&detectLines, "detect-lines", 200, "lines sampled by --profile auto (on stdin, fewer once --flush-timeout passes without input)")
 	cmd.Flags().StringVar(&rulesPath, "rules", "", "rules file path (YAML)")
 	cmd.Flags().StringVar(&emit, "emit", "jsonl", "emit: sig|jsonl|tsv")
 	cmd.Flags().StringVar(&bucket, "bucket", "", "bucket duration (e.g. 1m, 1h)")
+	cmd.Flags().BoolVar(&multiline, "multiline", true, "join continuation lines into one event where the profile or rules define it")
+	cmd.Flags().DurationVar(&flushTimeout, "flush-timeout", time.Second, "emit a pending multiline event after this long without input (stdin and pipes only)")
+	cmd.Flags().StringVar(&input.Format, "input", "text", "input format: text|json|logfmt|auto (auto detects per line)")
+	cmd.Flags().StringSliceVar(&input.TSFields, "ts-field", nil, "timestamp field names for structured input (default ts,time,timestamp,@timestamp,t)")
+	cmd.Flags().StringSliceVar(&input.LevelFields, "level-field", nil, "level field names for structured input (default level,lvl,severity,loglevel)")
+	cmd.Flags().StringSliceVar(&input.MsgFields, "msg-field", nil, "message field names for structured input (default msg,message,@message,log)")
+	cmd.Flags().StringSliceVar(&input.SigFields, "sig-fields", nil, "static fields of structured input added to the signature (e.g. service,logger)")
+	cmd.Flags().StringVar(&miner, "miner", "rules", "signature engine: rules|drain (learn templates on top of the rules)")
+	cmd.Flags().StringVar(&minerState, "miner-state", "", "file to load learned drain templates from and save them to")
+	markPathFlag(cmd, "rules", "")
+	markPathFlag(cmd, "miner-state", "")
+	cmd.Flags().Float64Var(&drainOpts.Sim, "drain-sim", 0.4, "drain similarity threshold for joining a template")
+	cmd.Flags().IntVar(&drainOpts.Depth, "drain-depth", 4, "drain parse tree depth")
+	cmd.AddCommand(newNormProfilesCommand(lang))
 	return cmd
 }
 
+func newNormProfilesCommand(lang i18n.Lang) *cobra.Command {
+	return &cobra.Command{
+		Use:   "profiles",
+		Short: i18n.T(lang, "cmd.norm.profiles.short"),
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out := cmd.OutOrStdout()
+			for _, name := range append([]string{"generic"}, norm.Profiles()...) {
+				desc, err := norm.ProfileDescription(name)
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(out, "%-9s %s\n", name, desc)
+			}
+			fmt.Fprintf(out, "%-9s %s\n", "auto", "detect from the first --detect-lines lines, mixing profiles per line in merged files")
+			return nil
+		},
+	}
+}
+
+type peeked struct {
+	line string
+	err  error
+}
+
+// peekLines reads up to n lines and returns them with a reader that replays
+// them before the rest of r. With an idle timeout, it stops early once a
+// line has arrived and no other follows for that long, as on tail -f.
+func peekLines(r io.Reader, n int, idle time.Duration) ([]string, io.Reader, error) {
+	br := bufio.NewReader(r)
+	ch := make(chan peeked, n)
+	go func() {
+		defer close(ch)
+		for i := 0; i < n; i++ {
+			line, err := br.ReadString('\n')
+			ch <- peeked{line, err}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	var (
+		buf   bytes.Buffer
+		lines []string
+		timer *time.Timer
+		fired <-chan time.Time
+	)
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+	for {
+		select {
+		case p, ok := <-ch:
+			if !ok {
+				return lines, io.MultiReader(&buf, br), nil
+			}
+			buf.WriteString(p.line)
+			if p.line != "" {
+				lines = append(lines, strings.TrimRight(p.line, "\r\n"))
+			}
+			if p.err != nil && !errors.Is(p.err, io.EOF) {
+				return nil, nil, p.err
+			}
+			if idle > 0 {
+				if timer == nil {
+					timer = time.NewTimer(idle)
+					fired = timer.C
+				} else {
+					timer.Reset(idle)
+				}
+			}
+		case <-fired:
+			return lines, io.MultiReader(&buf, &lateLines{ch: ch}, br), nil
+		}
+	}
+}
+
+// lateLines replays the lines peekLines was still reading when it stopped
+// waiting; the reader underneath is free once the channel is closed.
+type lateLines struct {
+	ch   <-chan peeked
+	rest string
+	err  error
+}
+
+func (l *lateLines) Read(p []byte) (int, error) {
+	for l.rest == "" {
+		if l.err != nil {
+			return 0, l.err
+		}
+		next, ok := <-l.ch
+		if !ok {
+			return 0, io.EOF
+		}
+		l.rest = next.line
+		if next.err != nil && !errors.Is(next.err, io.EOF) {
+			l.err = next.err
+		}
+	}
+	n := copy(p, l.rest)
+	l.rest = l.rest[n:]
+	return n, nil
+}
+
+// scanEvents reads lines, groups them into events with asm and calls emit
+// for each. With a timeout, a pending event is emitted once no line has
+// arrived for that long, so a stream is not held back waiting for the next
+// event to start. Callers pass 0 for regular files, which never wait.
+func scanEvents(r io.Reader, asm *norm.Assembler, timeout time.Duration, emit func(norm.Event) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	if asm == nil || timeout <= 0 {
+		line := 0
+		for scanner.Scan() {
+			line++
+			if ev, ok := asm.Add(scanner.Text(), line); ok {
+				if err := emit(ev); err != nil {
+					return err
+				}
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return err
+		}
+		if ev, ok := asm.Flush(); ok {
+			return emit(ev)
+		}
+		return nil
+	}
+
+	lines := make(chan string, 1024)
+	stop := make(chan struct{})
+	defer close(stop)
+	scanErr := make(chan error, 1)
+	go func() {
+		defer close(lines)
+		for scanner.Scan() {
+			select {
+			case lines <- scanner.Text():
+			case <-stop:
+				scanErr <- nil
+				return
+			}
+		}
+		scanErr <- scanner.Err()
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	line := 0
+	for {
+		select {
+		case text, ok := <-lines:
+			if !ok {
+				if err := <-scanErr; err != nil {
+					return err
+				}
+				if ev, ok := asm.Flush(); ok {
+					return emit(ev)
+				}
+				return nil
+			}
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timer.Reset(timeout)
+			line++
+			if ev, ok := asm.Add(text, line); ok {
+				if err := emit(ev); err != nil {
+					return err
+				}
+			}
+		case <-timer.C:
+			// Lines that queued up while emit was slow still belong to the
+			// pending event; only an idle input flushes it.
+			if len(lines) > 0 {
+				continue
+			}
+			if ev, ok := asm.Flush(); ok {
+				if err := emit(ev); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+// isStream reports whether r may block waiting for more input: stdin, a pipe
+// or a terminal, as opposed to a regular file.
+func isStream(r io.Reader) bool {
+	f, ok := r.(*os.File)
+	if !ok {
+		return true
+	}
+	info, err := f.Stat()
+	return err != nil || !info.Mode().IsRegular()
+}
+
 func sanitizeTSV(value string) string {
-	return strings.ReplaceAll(value, "\t", " ")
+	return escapeNewlines(strings.ReplaceAll(value, "\t", " "))
+}
+
+// escapeNewlines keeps multiline events on one output line.
+func escapeNewlines(value string) string {
+	return strings.ReplaceAll(value, "\n", `\n`)
+}
+
+// normProfiles lists the --profile values for flag help.
+func normProfiles() string {
+	return strings.Join(append([]string{"generic"}, norm.Profiles()...), "|")
 }
@@ -2,8 +2,16 @@ package cmd
 
 import (
 	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/yjhatfdu/aip/internal/norm"
 )
 
 func TestNormJSONDoesNotEscapeHTML(t *testing.T) {
@@ -27,3 +35,306 @@ func TestNormJSONDoesNotEscapeHTML(t *testing.T) {
 		t.Fatalf("expected <ts> in output: %q", got)
 	}
 }
+
+func TestNormMultilinePostgres(t *testing.T) {
+	root := newRoot()
+	root.SetArgs([]string{"norm", "--profile", "postgres", "--emit", "sig"})
+	root.SetIn(strings.NewReader(strings.Join([]string{
+		"2023-03-07 09:06:08 CET [130096]: ERROR:  relation \"t\" does not exist",
+		"2023-03-07 09:06:08 CET [130096]: STATEMENT:  select *",
+		"\tfrom t",
+		"2023-03-07 09:06:09 CET [130096]: LOG:  checkpoint starting",
+	}, "\n") + "\n"))
+	out := &bytes.Buffer{}
+	root.SetOut(out)
+	root.SetErr(&bytes.Buffer{})
+	if err := root.Execute(); err != nil {
+		t.Fatalf("norm error: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], "STATEMENT:  select *\\n\tfrom t") {
+		t.Fatalf("unexpected output: %q", out.String())
+	}
+
+	root = newRoot()
+	root.SetArgs([]string{"norm", "--profile", "postgres", "--multiline=false", "--emit", "sig"})
+	root.SetIn(strings.NewReader("2023-03-07 09:06:08 CET [1]: ERROR:  x\n2023-03-07 09:06:08 CET [1]: DETAIL:  y\n"))
+	out.Reset()
+	root.SetOut(out)
+	if err := root.Execute(); err != nil {
+		t.Fatalf("norm error: %v", err)
+	}
+	if got := strings.Count(out.String(), "\n"); got != 2 {
+		t.Fatalf("--multiline=false output: %q", out.String())
+	}
+}
+
+func TestNormMultilineFlushTimeout(t *testing.T) {
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	out := &syncBuffer{}
+	root := newRoot()
+	root.SetArgs([]string{"norm", "--profile", "postgres", "--flush-timeout", "20ms"})
+	root.SetIn(pr)
+	root.SetOut(out)
+	root.SetErr(&bytes.Buffer{})
+	done := make(chan error, 1)
+	go func() { done <- root.Execute() }()
+
+	if _, err := io.WriteString(pw, "2023-03-07 09:06:08 CET [1]: ERROR:  x\n2023-03-07 09:06:08 CET [1]: DETAIL:  y\n"); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.Contains(out.String(), `"line":1`) {
+		if time.Now().After(deadline) {
+			t.Fatalf("pending event not flushed: %q", out.String())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	pw.Close()
+	if err := <-done; err != nil {
+		t.Fatalf("norm error: %v", err)
+	}
+	if got := strings.Count(out.String(), "\n"); got != 1 {
+		t.Fatalf("unexpected output: %q", out.String())
+	}
+}
+
+func TestScanEventsSlowConsumer(t *testing.T) {
+	n, err := norm.New("postgres", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var input strings.Builder
+	for i := 0; i < 100; i++ {
+		input.WriteString("2023-03-07 09:06:08 CET [1]: ERROR:  x\n2023-03-07 09:06:08 CET [1]: DETAIL:  y\n")
+	}
+	events := 0
+	err = scanEvents(strings.NewReader(input.String()), n.Assembler(), time.Millisecond, func(ev norm.Event) error {
+		events++
+		if !strings.Contains(ev.Text, "DETAIL") {
+			t.Fatalf("event %d split from its continuation: %q", events, ev.Text)
+		}
+		time.Sleep(2 * time.Millisecond)
+		return nil
+	})
+	if err != nil || events != 100 {
+		t.Fatalf("scanEvents = %d events, %v", events, err)
+	}
+}
+
+func TestIsStream(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	if err := os.WriteFile(path, []byte("x\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pr.Close()
+	defer pw.Close()
+	if isStream(file) || !isStream(pr) || !isStream(strings.NewReader("")) {
+		t.Fatalf("isStream: file=%v pipe=%v", isStream(file), isStream(pr))
+	}
+}
+
+func TestNormAutoDetectDoesNotWaitForSample(t *testing.T) {
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	out := &syncBuffer{}
+	root := newRoot()
+	root.SetArgs([]string{"norm", "--profile", "auto", "--emit", "sig", "--flush-timeout", "20ms"})
+	root.SetIn(pr)
+	root.SetOut(out)
+	root.SetErr(&bytes.Buffer{})
+	done := make(chan error, 1)
+	go func() { done <- root.Execute() }()
+
+	line := "1:M 10 Oct 2023 13:55:36.120 * Ready to accept connections tcp\n"
+	if _, err := io.WriteString(pw, strings.Repeat(line, 3)); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for strings.Count(out.String(), "\n") < 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("detection still waiting for the sample: %q", out.String())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if _, err := io.WriteString(pw, line); err != nil {
+		t.Fatal(err)
+	}
+	pw.Close()
+	if err := <-done; err != nil {
+		t.Fatalf("norm error: %v", err)
+	}
+	if got := strings.Count(out.String(), "\n"); got != 4 {
+		t.Fatalf("unexpected output: %q", out.String())
+	}
+}
+
+func TestNormRejectsBadDetectLines(t *testing.T) {
+	root := newRoot()
+	root.SetArgs([]string{"norm", "--profile", "auto", "--detect-lines", "-1"})
+	root.SetIn(strings.NewReader("hello 1\n"))
+	root.SetOut(&bytes.Buffer{})
+	root.SetErr(&bytes.Buffer{})
+	if err := root.Execute(); err == nil || !strings.Contains(err.Error(), "--detect-lines must be > 0") {
+		t.Fatalf("expected --detect-lines error, got %v", err)
+	}
+}
+
+func TestPeekLinesKeepsLateLines(t *testing.T) {
+	pr, pw := io.Pipe()
+	go func() {
+		io.WriteString(pw, "a\n")
+		time.Sleep(200 * time.Millisecond)
+		io.WriteString(pw, "b\nc\n")
+		pw.Close()
+	}()
+	head, r, err := peekLines(pr, 10, 10*time.Millisecond)
+	if err != nil || len(head) != 1 || head[0] != "a" {
+		t.Fatalf("peekLines = %q, %v", head, err)
+	}
+	rest, err := io.ReadAll(r)
+	if err != nil || string(rest) != "a\nb\nc\n" {
+		t.Fatalf("replayed %q, %v", rest, err)
+	}
+}
+
+// syncBuffer lets a test read output while the command is still writing.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestNormDrainMinerState(t *testing.T) {
+	state := filepath.Join(t.TempDir(), "drain.json")
+	run := func(input string) []norm.Record {
+		t.Helper()
+		root := newRoot()
+		root.SetArgs([]string{"norm", "--miner", "drain", "--miner-state", state})
+		root.SetIn(strings.NewReader(input))
+		out := &bytes.Buffer{}
+		root.SetOut(out)
+		root.SetErr(&bytes.Buffer{})
+		if err := root.Execute(); err != nil {
+			t.Fatalf("norm error: %v", err)
+		}
+		var recs []norm.Record
+		for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+			var rec norm.Record
+			if err := json.Unmarshal([]byte(line), &rec); err != nil {
+				t.Fatalf("bad record %q: %v", line, err)
+			}
+			recs = append(recs, rec)
+		}
+		return recs
+	}
+
+	first := run("table users locked by alice\ntable orders locked by bob\n")
+	if first[0].TemplateID == "" || first[1].TemplateID != first[0].TemplateID || first[1].Sig != "table <*> locked by <*>" {
+		t.Fatalf("first run: %+v", first)
+	}
+	second := run("table items locked by carol\n")
+	if second[0].TemplateID != first[0].TemplateID || second[0].Sig != "table <*> locked by <*>" {
+		t.Fatalf("second run: %+v", second)
+	}
+	if got := second[0].Vars["param"]; len(got) != 2 || got[0] != "items" || got[1] != "carol" {
+		t.Fatalf("params = %v", got)
+	}
+}
+
+func TestNormStructuredInput(t *testing.T) {
+	root := newRoot()
+	root.SetArgs([]string{"norm", "--input", "auto", "--msg-field", "event", "--sig-fields", "svc", "--emit", "sig"})
+	root.SetIn(strings.NewReader(strings.Join([]string{
+		`{"ts":"2024-01-02T03:04:05Z","level":"info","event":"order 17 shipped","svc":"shop","order_id2":17}`,
+		`level=info event="order 18 shipped" svc=shop order_id2=18`,
+		`plain line 19`,
+	}, "\n") + "\n"))
+	out := &bytes.Buffer{}
+	root.SetOut(out)
+	root.SetErr(&bytes.Buffer{})
+	if err := root.Execute(); err != nil {
+		t.Fatalf("norm error: %v", err)
+	}
+	want := "info order <number> shipped svc=shop\ninfo order <number> shipped svc=shop\nplain line <number>\n"
+	if out.String() != want {
+		t.Fatalf("output = %q", out.String())
+	}
+}
+
+func TestNormProfileAuto(t *testing.T) {
+	root := newRoot()
+	root.SetArgs([]string{"norm", "--profile", "auto", "--detect-lines", "3", "--emit", "sig"})
+	root.SetIn(strings.NewReader(strings.Join([]string{
+		"1:M 10 Oct 2023 13:55:36.127 * Server initialized",
+		"1:M 10 Oct 2023 13:55:36.130 * Ready to accept connections tcp",
+		"1:S 10 Oct 2023 14:05:12.450 * Connecting to MASTER 10.0.0.3:6379",
+		"1:S 10 Oct 2023 14:05:12.451 # Error condition on socket for SYNC: Connection refused",
+	}, "\n") + "\n"))
+	out := &bytes.Buffer{}
+	errOut := &bytes.Buffer{}
+	root.SetOut(out)
+	root.SetErr(errOut)
+	if err := root.Execute(); err != nil {
+		t.Fatalf("norm error: %v", err)
+	}
+	if got := errOut.String(); got != "norm: profile auto: redis (timestamps on 3 of 3 lines sampled)\n" {
+		t.Fatalf("stderr = %q", got)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 4 || lines[3] != "<pid>:<role> <ts> # Error condition on socket for SYNC: Connection refused" {
+		t.Fatalf("output = %q", out.String())
+	}
+}
+
+func TestNormProfilesList(t *testing.T) {
+	root := newRoot()
+	root.SetArgs([]string{"norm", "profiles"})
+	out := &bytes.Buffer{}
+	root.SetOut(out)
+	root.SetErr(&bytes.Buffer{})
+	if err := root.Execute(); err != nil {
+		t.Fatalf("norm profiles error: %v", err)
+	}
+	for _, want := range []string{"generic   Built-in", "postgres  PostgreSQL log profile", "k8s       Kubernetes container logs", "auto      detect"} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("missing %q in:\n%s", want, out.String())
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "app.log")
+	if err := os.WriteFile(path, []byte("user 1 logged in\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	root = newRoot()
+	root.SetArgs([]string{"norm", "--emit", "sig", path})
+	out.Reset()
+	root.SetOut(out)
+	if err := root.Execute(); err != nil {
+		t.Fatalf("norm with file error: %v", err)
+	}
+	if out.String() != "user <number> logged in\n" {
+		t.Fatalf("output = %q", out.String())
+	}
+}
@@ -0,0 +1,167 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/yjhatfdu/aip/internal/i18n"
+	"github.com/yjhatfdu/aip/internal/norm"
+	"github.com/yjhatfdu/aip/internal/reduce"
+)
+
+func newReduceCommand(lang i18n.Lang) *cobra.Command {
+	var (
+		by      []string
+		top     int
+		samples int
+		seed    int64
+		format  string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "reduce [file]",
+		Short: i18n.T(lang, "cmd.reduce.short"),
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if format == "" {
+				format = "jsonl"
+			}
+			r, err := reduce.New(by, samples, seed)
+			if err != nil {
+				return err
+			}
+
+			var (
+				reader  io.Reader = cmd.InOrStdin()
+				srcFile           = ""
+			)
+			if len(args) == 1 {
+				file, err := os.Open(args[0])
+				if err != nil {
+					return err
+				}
+				defer file.Close()
+				reader = file
+				srcFile = args[0]
+			}
+
+			if err := readReduceInput(reader, r); err != nil {
+				if srcFile != "" {
+					return fmt.Errorf("%s: %w", srcFile, err)
+				}
+				return err
+			}
+			groups := r.Top(top)
+
+			out := cmd.OutOrStdout()
+			switch format {
+			case "jsonl":
+				enc := json.NewEncoder(out)
+				enc.SetEscapeHTML(false)
+				for _, g := range groups {
+					if err := enc.Encode(g); err != nil {
+						return err
+					}
+				}
+			case "json":
+				payload := map[string]any{"by": r.Keys(), "total_groups": r.Len(), "groups": groups}
+				enc := json.NewEncoder(out)
+				enc.SetEscapeHTML(false)
+				return enc.Encode(payload)
+			case "text":
+				for _, g := range groups {
+					if _, err := fmt.Fprintf(out, "%d\t%s\n", g.Count, strings.Join(groupValues(r.Keys(), g), "\t")); err != nil {
+						return err
+					}
+					for _, s := range g.Samples {
+						if _, err := fmt.Fprintf(out, "  %s\n", s.Raw); err != nil {
+							return err
+						}
+					}
+				}
+			case "markdown":
+				_, err := io.WriteString(out, renderReduceMarkdown(r.Keys(), groups))
+				return err
+			default:
+				return fmt.Errorf("unknown format: %s", format)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringSliceVar(&by, "by", []string{"sig"}, "group keys: sig|bucket|ts|raw|src.file|src.line|src.host|vars.<name>")
+	cmd.Flags().IntVar(&top, "top", 20, "keep the top N groups (0 = all)")
+	cmd.Flags().IntVar(&samples, "samples", 3, "reservoir samples per group")
+	cmd.Flags().Int64Var(&seed, "seed", 1, "sampling random seed")
+	cmd.Flags().StringVar(&format, "format", "jsonl", "format: jsonl|json|text|markdown")
+	return cmd
+}
+
+func readReduceInput(r io.Reader, reducer *reduce.Reducer) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		rawLine := strings.TrimSpace(scanner.Text())
+		if rawLine == "" {
+			continue
+		}
+		var rec norm.Record
+		if err := json.Unmarshal([]byte(rawLine), &rec); err != nil {
+			return fmt.Errorf("line %d: invalid json", line)
+		}
+		reducer.Add(rec)
+	}
+	if err := scanner.Err(); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+func groupValues(keys []string, g reduce.Group) []string {
+	values := make([]string, len(keys))
+	for i, key := range keys {
+		values[i] = g.Key[key]
+	}
+	return values
+}
+
+func renderReduceMarkdown(keys []string, groups []reduce.Group) string {
+	var b strings.Builder
+	b.WriteString("| count | ")
+	b.WriteString(strings.Join(keys, " | "))
+	b.WriteString(" | first_ts | last_ts | samples |\n|---:|")
+	b.WriteString(strings.Repeat("---|", len(keys)+3))
+	b.WriteString("\n")
+	for _, g := range groups {
+		cells := []string{fmt.Sprint(g.Count)}
+		for _, v := range groupValues(keys, g) {
+			cells = append(cells, markdownCell(v))
+		}
+		samples := make([]string, 0, len(g.Samples))
+		for _, s := range g.Samples {
+			samples = append(samples, markdownCell(s.Raw))
+		}
+		cells = append(cells, g.FirstTS, g.LastTS, strings.Join(samples, "<br>"))
+		b.WriteString("| ")
+		b.WriteString(strings.Join(cells, " | "))
+		b.WriteString(" |\n")
+	}
+	return b.String()
+}
+
+func markdownCell(v string) string {
+	v = strings.ReplaceAll(v, "|", `\|`)
+	v = strings.ReplaceAll(v, "\n", " ")
+	if v == "" {
+		return " "
+	}
+	return "`" + strings.ReplaceAll(v, "`", "'") + "`"
+}
@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/yjhatfdu/aip/internal/reduce"
+)
+
+const reduceInput = `{"raw":"a1","sig":"alpha","ts":"2024-01-01T00:00:02Z","bucket":"b1","src":{"host":"h1","line":1}}
+{"raw":"a2","sig":"alpha","ts":"2024-01-01T00:00:01Z","bucket":"b1","src":{"host":"h2","line":2}}
+{"raw":"b1","sig":"beta","ts":"2024-01-01T00:00:03Z","bucket":"b2","src":{"host":"h1","line":3}}
+`
+
+func TestReduceCommandJSONL(t *testing.T) {
+	root := newRoot()
+	root.SetArgs([]string{"reduce", "--by", "sig,bucket", "--samples", "1"})
+	root.SetIn(strings.NewReader(reduceInput))
+	out := &bytes.Buffer{}
+	root.SetOut(out)
+	root.SetErr(&bytes.Buffer{})
+
+	if err := root.Execute(); err != nil {
+		t.Fatalf("reduce error: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 groups, got %q", out.String())
+	}
+	var g reduce.Group
+	if err := json.Unmarshal([]byte(lines[0]), &g); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if g.Key["sig"] != "alpha" || g.Key["bucket"] != "b1" || g.Count != 2 {
+		t.Fatalf("unexpected group: %+v", g)
+	}
+	if g.FirstTS != "2024-01-01T00:00:01Z" || len(g.Samples) != 1 {
+		t.Fatalf("unexpected group details: %+v", g)
+	}
+}
+
+func TestReduceCommandMarkdownByHost(t *testing.T) {
+	root := newRoot()
+	root.SetArgs([]string{"reduce", "--by", "src.host", "--top", "1", "--format", "markdown"})
+	root.SetIn(strings.NewReader(reduceInput))
+	out := &bytes.Buffer{}
+	root.SetOut(out)
+	root.SetErr(&bytes.Buffer{})
+
+	if err := root.Execute(); err != nil {
+		t.Fatalf("reduce error: %v", err)
+	}
+	got := out.String()
+	if !strings.HasPrefix(got, "| count | src.host |") || !strings.Contains(got, "| 2 | `h1` |") {
+		t.Fatalf("unexpected markdown: %q", got)
+	}
+	if strings.Contains(got, "h2") {
+		t.Fatalf("--top 1 not applied: %q", got)
+	}
+}
+
+func TestReduceCommandInvalidJSON(t *testing.T) {
+	root := newRoot()
+	root.SetArgs([]string{"reduce"})
+	root.SetIn(strings.NewReader("not json\n"))
+	root.SetOut(&bytes.Buffer{})
+	root.SetErr(&bytes.Buffer{})
+	if err := root.Execute(); err == nil {
+		t.Fatal("expected error for invalid json")
+	}
+}
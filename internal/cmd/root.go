@@ -1,6 +1,8 @@
 package cmd
 
 import (
+	"os"
+
 	"github.com/spf13/cobra"
 	"github.com/yjhatfdu/aip/internal/i18n"
 )
@@ -18,20 +20,31 @@ func newRoot() *cobra.Command {
 		SilenceUsage:  true,
 		SilenceErrors: true,
 	}
+	root.PersistentFlags().String("config-profile", "", "config profile (default AIP_PROFILE, then default_profile)")
 
 	root.AddCommand(
 		newSummaryCommand(lang),
-		// newStubCommand(lang, "map", "cmd.map.short"),
-		// newStubCommand(lang, "watch", "cmd.watch.short"),
+		newMapCommand(lang),
+		newWatchCommand(lang),
 		newNormCommand(lang),
-		// newStubCommand(lang, "reduce", "cmd.reduce.short"),
+		newReduceCommand(lang),
 		newClusterCommand(lang),
-		// newStubCommand(lang, "sample", "cmd.sample.short"),
-		// newStubCommand(lang, "diagnose", "cmd.diagnose.short"),
-		// newStubCommand(lang, "cache", "cmd.cache.short"),
+		newSampleCommand(lang),
+		newDiagnoseCommand(lang),
+		newCacheCommand(lang),
 		newConfigCommand(lang),
 		newVersionCommand(lang),
 	)
 
 	return root
 }
+
+// configProfile is the config profile selected with the global
+// --config-profile flag or AIP_PROFILE. It is not --profile, which selects
+// the norm profile on norm, sample, watch and diagnose.
+func configProfile(cmd *cobra.Command) string {
+	if flag := cmd.Root().PersistentFlags().Lookup("config-profile"); flag != nil && flag.Changed {
+		return flag.Value.String()
+	}
+	return os.Getenv("AIP_PROFILE")
+}
@@ -0,0 +1,280 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/yjhatfdu/aip/internal/cluster"
+	"github.com/yjhatfdu/aip/internal/i18n"
+	"github.com/yjhatfdu/aip/internal/norm"
+	"github.com/yjhatfdu/aip/internal/sampling"
+)
+
+type sampleTarget struct {
+	sig   string
+	count int
+	hash  uint64
+}
+
+func newSampleCommand(lang i18n.Lang) *cobra.Command {
+	var (
+		from      string
+		k         int
+		per       int
+		method    string
+		seed      int64
+		threshold int
+		profile   string
+		rulesPath string
+		format    string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "sample [file]",
+		Short: i18n.T(lang, "cmd.sample.short"),
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if format == "" {
+				format = "jsonl"
+			}
+			n, err := norm.New(profile, rulesPath, "")
+			if err != nil {
+				return err
+			}
+			sampler, err := sampling.NewSampler(per, method, seed, norm.ParseTime)
+			if err != nil {
+				return err
+			}
+
+			var targets []sampleTarget
+			if from != "" {
+				targets, err = readSampleTargets(from, k)
+				if err != nil {
+					return err
+				}
+			}
+
+			var (
+				reader  io.Reader = cmd.InOrStdin()
+				srcFile           = ""
+			)
+			if len(args) == 1 {
+				file, err := os.Open(args[0])
+				if err != nil {
+					return err
+				}
+				defer file.Close()
+				reader = file
+				srcFile = args[0]
+			}
+
+			match := func(sig string) (string, bool) { return sig, true }
+			if targets != nil {
+				match = func(sig string) (string, bool) {
+					return matchSampleTarget(targets, sig, threshold)
+				}
+			}
+			err = readSampleInput(reader, n, srcFile, func(rec norm.Record) {
+				group, ok := match(rec.Sig)
+				if !ok {
+					return
+				}
+				sampler.Add(group, sampling.Item{TS: rec.TS, Raw: rec.Raw, File: rec.Src.File, Line: rec.Src.Line})
+			})
+			if err != nil {
+				if srcFile != "" {
+					return fmt.Errorf("%s: %w", srcFile, err)
+				}
+				return err
+			}
+
+			var groups []sampling.Group
+			if targets != nil {
+				for _, t := range targets {
+					g := sampler.Group(t.sig)
+					g.Matched = g.Count
+					g.Count = t.count
+					groups = append(groups, g)
+				}
+			} else {
+				groups = sampler.Top(k)
+			}
+
+			out := cmd.OutOrStdout()
+			switch format {
+			case "jsonl":
+				enc := json.NewEncoder(out)
+				enc.SetEscapeHTML(false)
+				for _, g := range groups {
+					if err := enc.Encode(g); err != nil {
+						return err
+					}
+				}
+			case "json":
+				enc := json.NewEncoder(out)
+				enc.SetEscapeHTML(false)
+				return enc.Encode(map[string]any{"groups": groups})
+			case "text":
+				for _, g := range groups {
+					if _, err := fmt.Fprintf(out, "# %d\t%s\n", g.Count, g.Sig); err != nil {
+						return err
+					}
+					for _, s := range g.Samples {
+						if _, err := fmt.Fprintln(out, s.Raw); err != nil {
+							return err
+						}
+					}
+				}
+			default:
+				return fmt.Errorf("unknown format: %s", format)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&from, "from", "", "cluster/reduce JSONL listing the groups to sample")
+	cmd.Flags().IntVar(&k, "k", 10, "number of top groups (0 = all)")
+	cmd.Flags().IntVar(&per, "per", 5, "samples per group")
+	cmd.Flags().StringVar(&method, "method", "reservoir", "sampling method: reservoir|time")
+	cmd.Flags().Int64Var(&seed, "seed", 1, "sampling random seed")
+	cmd.Flags().IntVar(&threshold, "threshold", 4, "simhash distance for matching lines to --from clusters")
+	cmd.Flags().StringVar(&profile, "profile", "generic", "norm profile for raw input "+normProfiles())
+	cmd.Flags().StringVar(&rulesPath, "rules", "", "rules file path (YAML)")
+	cmd.Flags().StringVar(&format, "format", "jsonl", "format: jsonl|json|text")
+	return cmd
+}
+
+// readSampleInput accepts either norm JSONL or raw log lines, which are
+// normalized on the fly.
+func readSampleInput(r io.Reader, n *norm.Normalizer, srcFile string, add func(norm.Record)) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+
+	const (
+		inputUnknown = iota
+		inputJSONL
+		inputText
+	)
+	mode := inputUnknown
+	asm := n.Assembler()
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := scanner.Text()
+		if mode == inputUnknown {
+			if strings.TrimSpace(text) == "" {
+				continue
+			}
+			mode = inputText
+			var rec norm.Record
+			if err := json.Unmarshal([]byte(text), &rec); err == nil && rec.Sig != "" {
+				mode = inputJSONL
+			}
+		}
+		if mode == inputText {
+			if ev, ok := asm.Add(text, line); ok {
+				add(n.Normalize(ev.Text, norm.Source{File: srcFile, Line: ev.Line}))
+			}
+			continue
+		}
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		var rec norm.Record
+		if err := json.Unmarshal([]byte(text), &rec); err != nil {
+			return fmt.Errorf("line %d: invalid json", line)
+		}
+		add(rec)
+	}
+	if err := scanner.Err(); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	if ev, ok := asm.Flush(); ok {
+		add(n.Normalize(ev.Text, norm.Source{File: srcFile, Line: ev.Line}))
+	}
+	return nil
+}
+
+func readSampleTargets(path string, k int) ([]sampleTarget, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	var targets []sampleTarget
+	line := 0
+	for scanner.Scan() {
+		line++
+		rawLine := strings.TrimSpace(scanner.Text())
+		if rawLine == "" {
+			continue
+		}
+		obj, ok := parseJSONObject(rawLine)
+		if !ok {
+			return nil, fmt.Errorf("%s: line %d: invalid json", path, line)
+		}
+		sig := ""
+		switch {
+		case obj["repr"] != nil:
+			sig = fmt.Sprint(obj["repr"])
+		case obj["sig"] != nil:
+			sig = fmt.Sprint(obj["sig"])
+		default:
+			if key, ok := obj["key"].(map[string]any); ok && key["sig"] != nil {
+				sig = fmt.Sprint(key["sig"])
+			}
+		}
+		if sig == "" {
+			return nil, fmt.Errorf("%s: line %d: missing repr/sig", path, line)
+		}
+		count := 0
+		if v, ok := obj["count"].(float64); ok {
+			count = int(v)
+		}
+		targets = append(targets, sampleTarget{sig: sig, count: count, hash: cluster.Simhash(sig, 1)})
+	}
+	if err := scanner.Err(); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	sort.SliceStable(targets, func(i, j int) bool {
+		return targets[i].count > targets[j].count
+	})
+	if k > 0 && len(targets) > k {
+		targets = targets[:k]
+	}
+	return targets, nil
+}
+
+// matchSampleTarget maps a signature onto the exact or nearest group; cluster
+// output only keeps the representative signature, so members are found by
+// simhash distance.
+func matchSampleTarget(targets []sampleTarget, sig string, threshold int) (string, bool) {
+	for _, t := range targets {
+		if t.sig == sig {
+			return t.sig, true
+		}
+	}
+	h := cluster.Simhash(sig, 1)
+	best := -1
+	bestDist := threshold + 1
+	for i, t := range targets {
+		if d := cluster.Hamming(h, t.hash); d < bestDist {
+			best = i
+			bestDist = d
+		}
+	}
+	if best < 0 {
+		return "", false
+	}
+	return targets[best].sig, true
+}
@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/yjhatfdu/aip/internal/sampling"
+)
+
+func TestSampleCommandStreamingTopK(t *testing.T) {
+	input := strings.Join([]string{
+		"conn 1 refused",
+		"conn 2 refused",
+		"conn 3 refused",
+		"disk full",
+	}, "\n") + "\n"
+
+	root := newRoot()
+	root.SetArgs([]string{"sample", "--k", "1", "--per", "2"})
+	root.SetIn(strings.NewReader(input))
+	out := &bytes.Buffer{}
+	root.SetOut(out)
+	root.SetErr(&bytes.Buffer{})
+
+	if err := root.Execute(); err != nil {
+		t.Fatalf("sample error: %v", err)
+	}
+	var g sampling.Group
+	if err := json.Unmarshal(bytes.TrimSpace(out.Bytes()), &g); err != nil {
+		t.Fatalf("unmarshal %q: %v", out.String(), err)
+	}
+	if g.Sig != "conn <number> refused" || g.Count != 3 || len(g.Samples) != 2 {
+		t.Fatalf("unexpected group: %+v", g)
+	}
+	if g.Samples[0].Line == 0 {
+		t.Fatalf("expected source line on samples: %+v", g.Samples)
+	}
+}
+
+func TestSampleCommandFromClusters(t *testing.T) {
+	dir := t.TempDir()
+	clusters := filepath.Join(dir, "clusters.jsonl")
+	content := `{"count":3,"repr":"conn <number> refused"}` + "\n" + `{"count":1,"repr":"disk full"}` + "\n"
+	if err := os.WriteFile(clusters, []byte(content), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	input := "conn 1 refused\ndisk full\nconn 2 refused\nunrelated noise here\n"
+
+	root := newRoot()
+	root.SetArgs([]string{"sample", "--from", clusters, "--per", "5", "--format", "text"})
+	root.SetIn(strings.NewReader(input))
+	out := &bytes.Buffer{}
+	root.SetOut(out)
+	root.SetErr(&bytes.Buffer{})
+
+	if err := root.Execute(); err != nil {
+		t.Fatalf("sample error: %v", err)
+	}
+	want := "# 3\tconn <number> refused\nconn 1 refused\nconn 2 refused\n# 1\tdisk full\ndisk full\n"
+	if out.String() != want {
+		t.Fatalf("unexpected output: %q", out.String())
+	}
+}
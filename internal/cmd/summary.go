@@ -7,19 +7,28 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/yjhatfdu/aip/internal/config"
 	"github.com/yjhatfdu/aip/internal/i18n"
 	"github.com/yjhatfdu/aip/internal/llm"
+	"github.com/yjhatfdu/aip/internal/schema"
 	"github.com/yjhatfdu/aip/internal/summary"
+	"github.com/yjhatfdu/aip/internal/tokens"
 )
 
 type summaryResult struct {
-	Output string    `json:"output"`
-	Usage  llm.Usage `json:"usage,omitempty"`
-	Model  string    `json:"model,omitempty"`
+	Output   string          `json:"output"`
+	Data     json.RawMessage `json:"data,omitempty"`
+	Usage    llm.Usage       `json:"usage,omitempty"`
+	Model    string          `json:"model,omitempty"`
+	Strategy string          `json:"strategy,omitempty"`
+	Depth    int             `json:"depth,omitempty"`
+	Chunks   int             `json:"chunks,omitempty"`
+	Calls    int             `json:"calls,omitempty"`
+	llmMetrics
 }
 
 func newSummaryCommand(lang i18n.Lang) *cobra.Command {
@@ -29,16 +38,21 @@ func newSummaryCommand(lang i18n.Lang) *cobra.Command {
 		maxChars    int
 		includeHead int
 		includeTail int
-		baseURL     string
-		apiKey      string
-		model       string
 		stream      bool
+		strategy    string
+		concurrency int
+		schemaPath  string
+		maxTokensIn int
+		llmOpts     llmFlags
 	)
 
 	cmd := &cobra.Command{
 		Use:   "summary <prompt> [file]",
 		Short: i18n.T(lang, "cmd.summary.short"),
 		Args:  cobra.RangeArgs(1, 2),
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return applyCommandDefaults(cmd, lang, "summary")
+		},
 		RunE: func(cmd *cobra.Command, args []string) error {
 			userPrompt, err := summary.LoadPrompt(args[0])
 			if err != nil {
@@ -66,45 +80,95 @@ func newSummaryCommand(lang i18n.Lang) *cobra.Command {
 				srcFile = args[1]
 			}
 
-			input, err := summary.ReadInput(reader, summary.InputOptions{
+			if strategy == "" {
+				strategy = summary.StrategySingle
+			}
+			if strategy != summary.StrategySingle {
+				for _, name := range []string{"include-head", "include-tail", "stream"} {
+					if cmd.Flags().Changed(name) {
+						return fmt.Errorf("--%s is only supported with --strategy single", name)
+					}
+				}
+			}
+			var outSchema *schema.Schema
+			if schemaPath != "" {
+				if strategy != summary.StrategySingle {
+					return errors.New("--schema is only supported with --strategy single")
+				}
+				outSchema, err = schema.Load(schemaPath)
+				if err != nil {
+					return err
+				}
+			}
+			switch strategy {
+			case summary.StrategySingle:
+			case summary.StrategyMapReduce, summary.StrategyRefine:
+				input, err := summary.ReadFull(reader)
+				if err != nil {
+					if srcFile != "" {
+						return fmt.Errorf("%s: %w", srcFile, err)
+					}
+					return err
+				}
+				cfg, err := llmOpts.config()
+				if err != nil {
+					return err
+				}
+				params := llmOpts.params(cfg)
+				budget := maxTokensIn
+				if budget <= 0 {
+					budget = inputBudget(cfg, params, tokens.ForModel(cfg.Model), systemPrompt, userPrompt)
+				}
+				client, err := llmOpts.client()
+				if err != nil {
+					return err
+				}
+				return runSummaryStrategy(cmd, client, strategy, format, systemPrompt, userPrompt, input, summary.StrategyOptions{
+					MaxChars:    maxChars,
+					MaxTokens:   budget,
+					ReplyTokens: replyBudget(cfg, params),
+					Concurrency: concurrency,
+				})
+			default:
+				return fmt.Errorf("unknown strategy: %s", strategy)
+			}
+
+			cfg, err := llmOpts.config()
+			if err != nil {
+				return err
+			}
+			inputOpts := summary.InputOptions{
 				MaxChars:    maxChars,
 				IncludeHead: includeHead,
 				IncludeTail: includeTail,
-			})
+				MaxTokens:   maxTokensIn,
+				Encoding:    tokens.ForModel(cfg.Model),
+			}
+			// --max-chars stays a cap next to the token budget; only an
+			// explicit --max-chars 0 lifts it.
+			if inputOpts.MaxTokens <= 0 {
+				inputOpts.MaxTokens = inputBudget(cfg, llmOpts.params(cfg), inputOpts.Encoding, systemPrompt, userPrompt)
+			}
+			input, stats, err := summary.ReadInputStats(reader, inputOpts)
 			if err != nil {
 				if srcFile != "" {
 					return fmt.Errorf("%s: %w", srcFile, err)
 				}
 				return err
 			}
+			if stats.Truncated() {
+				fmt.Fprintf(cmd.ErrOrStderr(), "summary: input truncated to ~%d of ~%d tokens (%d of %d lines kept, %d bytes dropped); raise --max-chars or --max-tokens-in, or use --strategy map-reduce\n",
+					stats.KeptTokens, stats.Tokens, stats.KeptLines, stats.Lines, stats.Bytes-stats.KeptBytes)
+			}
 
-			cfgPath, err := config.DefaultPath()
+			client, err := llmOpts.client()
 			if err != nil {
 				return err
 			}
-			cfg, err := config.LoadMerged(cfgPath)
-			if err != nil && !errors.Is(err, os.ErrNotExist) {
-				return err
-			}
-			cfg = config.Merge(cfg, config.Config{
-				BaseURL: baseURL,
-				APIKey:  apiKey,
-				Model:   model,
-			})
-			if cfg.BaseURL == "" || cfg.APIKey == "" || cfg.Model == "" {
-				return errors.New("missing base_url/api_key/model (set env, config, or flags)")
-			}
-
-			client := llm.Client{
-				BaseURL: cfg.BaseURL,
-				APIKey:  cfg.APIKey,
-				Model:   cfg.Model,
-			}
 			ctx, cancel := context.WithTimeout(cmd.Context(), 2*time.Minute)
 			defer cancel()
 
 			req := llm.ChatRequest{
-				Model: cfg.Model,
 				Messages: []llm.ChatMessage{
 					{Role: "system", Content: systemPrompt},
 					{Role: "user", Content: summary.BuildUserPrompt(userPrompt, input)},
@@ -117,12 +181,34 @@ func newSummaryCommand(lang i18n.Lang) *cobra.Command {
 			if format == "json" {
 				stream = false
 			}
+			if outSchema != nil {
+				if format != "text" && format != "json" {
+					return fmt.Errorf("unknown format: %s", format)
+				}
+				resp, data, err := summary.CompleteStructured(ctx, client, req, summary.SchemaName(schemaPath), outSchema)
+				if err != nil {
+					return err
+				}
+				if format == "text" {
+					_, err = fmt.Fprintln(out, string(data))
+					return err
+				}
+				enc := json.NewEncoder(out)
+				enc.SetEscapeHTML(false)
+				return enc.Encode(summaryResult{
+					Output:     string(data),
+					Data:       data,
+					Usage:      resp.Usage,
+					Model:      resp.Model,
+					llmMetrics: newLLMMetrics(llmOpts.meter.Totals().Metrics),
+				})
+			}
 			switch format {
 			case "text":
 				if stream {
 					last := byte(0)
 					wrote := false
-					err := client.StreamComplete(ctx, req, func(delta string) error {
+					_, err := client.StreamComplete(ctx, req, func(delta string) error {
 						if delta == "" {
 							return nil
 						}
@@ -158,9 +244,10 @@ func newSummaryCommand(lang i18n.Lang) *cobra.Command {
 					return errors.New("empty response")
 				}
 				payload := summaryResult{
-					Output: resp.Choices[0].Message.Content,
-					Usage:  resp.Usage,
-					Model:  resp.Model,
+					Output:     resp.Choices[0].Message.Content,
+					Usage:      resp.Usage,
+					Model:      resp.Model,
+					llmMetrics: newLLMMetrics(resp.Metrics),
 				}
 				enc := json.NewEncoder(out)
 				enc.SetEscapeHTML(false)
@@ -173,12 +260,103 @@ func newSummaryCommand(lang i18n.Lang) *cobra.Command {
 
 	cmd.Flags().StringVar(&systemValue, "system", "", "system prompt or @file")
 	cmd.Flags().StringVar(&format, "format", "text", "format: text|json")
-	cmd.Flags().IntVar(&maxChars, "max-chars", 40000, "max input chars")
+	cmd.Flags().IntVar(&maxChars, "max-chars", 40000, "max input chars (per request for map-reduce/refine)")
+	cmd.Flags().IntVar(&maxTokensIn, "max-tokens-in", 0, "input budget in estimated tokens, per request for map-reduce/refine (0 = model context size from config or built-in table)")
 	cmd.Flags().IntVar(&includeHead, "include-head", 0, "include head chars")
 	cmd.Flags().IntVar(&includeTail, "include-tail", 0, "include tail chars")
-	cmd.Flags().StringVar(&baseURL, "base-url", "", "LLM base URL")
-	cmd.Flags().StringVar(&apiKey, "api-key", "", "LLM API key")
-	cmd.Flags().StringVar(&model, "model", "", "LLM model")
 	cmd.Flags().BoolVar(&stream, "stream", true, "stream output")
+	cmd.Flags().StringVar(&strategy, "strategy", "single", "strategy: single|map-reduce|refine")
+	cmd.Flags().IntVar(&concurrency, "concurrency", 4, "parallel LLM requests for map-reduce")
+	cmd.Flags().StringVar(&schemaPath, "schema", "", "JSON schema file; the reply is requested as JSON and validated")
+	markPathFlag(cmd, "system", "@")
+	markPathFlag(cmd, "schema", "")
+	llmOpts.register(cmd, lang)
 	return cmd
 }
+
+// inputBudget derives the input token budget from the model's context window,
+// leaving room for the prompts and the reply; 0 means unknown.
+func inputBudget(cfg config.Config, params llm.Params, enc tokens.Encoding, systemPrompt, userPrompt string) int {
+	size := contextSize(cfg)
+	if size <= 0 {
+		return 0
+	}
+	reply := replyBudget(cfg, params)
+	const overhead = 64
+	budget := size - reply - enc.Count(systemPrompt) - enc.Count(summary.BuildUserPrompt(userPrompt, "")) - overhead
+	return max(budget, 1)
+}
+
+// replyBudget is the room kept for the model's answer: --max-tokens or
+// max_tokens, else a quarter of the context window up to 4096; 0 when both
+// are unknown.
+func replyBudget(cfg config.Config, params llm.Params) int {
+	if params.MaxTokens > 0 {
+		return params.MaxTokens
+	}
+	return min(4096, contextSize(cfg)/4)
+}
+
+func runSummaryStrategy(cmd *cobra.Command, client llm.Provider, strategy, format, systemPrompt, userPrompt, input string, opts summary.StrategyOptions) error {
+	if format == "" {
+		format = "text"
+	}
+	if format != "text" && format != "json" {
+		return fmt.Errorf("unknown format: %s", format)
+	}
+	var (
+		mu    sync.Mutex
+		model string
+	)
+	complete := func(ctx context.Context, system, user string) (string, llm.Usage, error) {
+		ctx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+		defer cancel()
+		resp, err := client.Complete(ctx, llm.ChatRequest{
+			Messages: []llm.ChatMessage{
+				{Role: "system", Content: system},
+				{Role: "user", Content: user},
+			},
+		})
+		if err != nil {
+			return "", llm.Usage{}, err
+		}
+		if len(resp.Choices) == 0 {
+			return "", llm.Usage{}, errors.New("empty response")
+		}
+		mu.Lock()
+		model = resp.Model
+		mu.Unlock()
+		return resp.Choices[0].Message.Content, resp.Usage, nil
+	}
+
+	run := summary.MapReduce
+	if strategy == summary.StrategyRefine {
+		run = summary.Refine
+	}
+	result, err := run(cmd.Context(), complete, systemPrompt, userPrompt, input, opts)
+	if err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	if format == "json" {
+		var metrics llmMetrics
+		if meter, ok := client.(*llm.Meter); ok {
+			metrics = newLLMMetrics(meter.Totals().Metrics)
+		}
+		enc := json.NewEncoder(out)
+		enc.SetEscapeHTML(false)
+		return enc.Encode(summaryResult{
+			Output:     result.Output,
+			Usage:      result.Usage,
+			Model:      model,
+			Strategy:   strategy,
+			Depth:      result.Depth,
+			Chunks:     result.Chunks,
+			Calls:      result.Calls,
+			llmMetrics: metrics,
+		})
+	}
+	_, err = fmt.Fprintln(out, result.Output)
+	return err
+}
@@ -5,7 +5,10 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
+	"sync/atomic"
 	"testing"
 
 	"github.com/yjhatfdu/aip/internal/llm"
@@ -86,3 +89,375 @@ func TestSummaryCommandJSON(t *testing.T) {
 		t.Fatalf("unexpected json output: %q", out.String())
 	}
 }
+
+func TestSummaryCommandMapReduceJSON(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp := llm.ChatResponse{
+			Model: "model",
+			Usage: llm.Usage{PromptTokens: 1, CompletionTokens: 1, TotalTokens: 2},
+			Choices: []struct {
+				Message llm.ChatMessage `json:"message"`
+			}{
+				{Message: llm.ChatMessage{Role: "assistant", Content: "part"}},
+			},
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(server.Close)
+
+	root := newRoot()
+	root.SetArgs([]string{
+		"summary",
+		"summarize",
+		"--strategy", "map-reduce",
+		"--max-chars", "12",
+		"--format", "json",
+		"--base-url", server.URL,
+		"--api-key", "key",
+		"--model", "model",
+	})
+	root.SetIn(strings.NewReader("line1\nline2\nline3\nline4\n"))
+	out := &bytes.Buffer{}
+	root.SetOut(out)
+	root.SetErr(&bytes.Buffer{})
+
+	if err := root.Execute(); err != nil {
+		t.Fatalf("summary error: %v", err)
+	}
+	var got summaryResult
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if got.Strategy != "map-reduce" || got.Chunks != 2 || got.Depth != 2 || got.Calls != 3 {
+		t.Fatalf("unexpected result: %+v", got)
+	}
+	if got.Usage.TotalTokens != 6 {
+		t.Fatalf("usage not aggregated: %+v", got.Usage)
+	}
+}
+
+func TestSummaryCommandAnthropic(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/messages" || r.Header.Get("x-api-key") != "key" {
+			t.Errorf("unexpected request %s %v", r.URL.Path, r.Header)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte("data: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\"from claude\"}}\n\n"))
+		_, _ = w.Write([]byte("data: {\"type\":\"message_stop\"}\n\n"))
+	}))
+	t.Cleanup(server.Close)
+
+	root := newRoot()
+	root.SetArgs([]string{
+		"summary",
+		"summarize",
+		"--provider", "anthropic",
+		"--base-url", server.URL,
+		"--api-key", "key",
+		"--model", "claude",
+		"--no-cache",
+	})
+	root.SetIn(strings.NewReader("hello\n"))
+	out := &bytes.Buffer{}
+	root.SetOut(out)
+	root.SetErr(&bytes.Buffer{})
+
+	if err := root.Execute(); err != nil {
+		t.Fatalf("summary error: %v", err)
+	}
+	if strings.TrimSpace(out.String()) != "from claude" {
+		t.Fatalf("unexpected output: %q", out.String())
+	}
+}
+
+func TestSummaryCommandFakeProvider(t *testing.T) {
+	root := newRoot()
+	root.SetArgs([]string{
+		"summary",
+		"summarize",
+		"--provider", "fake",
+		"--model", "fake-model",
+		"--format", "json",
+		"--no-cache",
+	})
+	root.SetIn(strings.NewReader("hello\nworld\n"))
+	out := &bytes.Buffer{}
+	root.SetOut(out)
+	root.SetErr(&bytes.Buffer{})
+
+	if err := root.Execute(); err != nil {
+		t.Fatalf("summary error: %v", err)
+	}
+	var got summaryResult
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("invalid json: %v", err)
+	}
+	if got.Model != "fake-model" || !strings.HasPrefix(got.Output, "fake response") {
+		t.Fatalf("unexpected result: %+v", got)
+	}
+}
+
+func TestSummaryCommandOllama(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/chat" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		_, _ = w.Write([]byte(`{"message":{"content":"local summary"},"done":false}` + "\n" + `{"done":true}` + "\n"))
+	}))
+	t.Cleanup(server.Close)
+
+	root := newRoot()
+	root.SetArgs([]string{
+		"summary",
+		"summarize",
+		"--provider", "ollama",
+		"--base-url", server.URL,
+		"--model", "llama3",
+		"--no-cache",
+	})
+	root.SetIn(strings.NewReader("hello\n"))
+	out := &bytes.Buffer{}
+	root.SetOut(out)
+	root.SetErr(&bytes.Buffer{})
+
+	if err := root.Execute(); err != nil {
+		t.Fatalf("summary error: %v", err)
+	}
+	if strings.TrimSpace(out.String()) != "local summary" {
+		t.Fatalf("unexpected output: %q", out.String())
+	}
+}
+
+func TestSummaryCommandSchemaAndParams(t *testing.T) {
+	var got map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decode: %v", err)
+		}
+		resp := llm.ChatResponse{Model: "model"}
+		resp.Choices = append(resp.Choices, struct {
+			Message llm.ChatMessage `json:"message"`
+		}{Message: llm.ChatMessage{Role: "assistant", Content: `{"severity":"high"}`}})
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(server.Close)
+
+	schemaPath := filepath.Join(t.TempDir(), "triage.json")
+	if err := os.WriteFile(schemaPath, []byte(`{"type":"object","required":["severity"],"properties":{"severity":{"enum":["low","high"]}}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	root := newRoot()
+	root.SetArgs([]string{
+		"summary",
+		"triage",
+		"--schema", schemaPath,
+		"--temperature", "0",
+		"--max-tokens", "200",
+		"--stop", "END",
+		"--base-url", server.URL,
+		"--api-key", "key",
+		"--model", "model",
+		"--no-cache",
+	})
+	root.SetIn(strings.NewReader("hello\n"))
+	out := &bytes.Buffer{}
+	root.SetOut(out)
+	root.SetErr(&bytes.Buffer{})
+
+	if err := root.Execute(); err != nil {
+		t.Fatalf("summary error: %v", err)
+	}
+	if strings.TrimSpace(out.String()) != `{"severity":"high"}` {
+		t.Fatalf("unexpected output: %q", out.String())
+	}
+	format, _ := got["response_format"].(map[string]any)
+	if format["type"] != "json_schema" || got["temperature"] != float64(0) || got["max_tokens"] != float64(200) {
+		t.Fatalf("unexpected request: %v", got)
+	}
+	if stop, _ := got["stop"].([]any); len(stop) != 1 || stop[0] != "END" {
+		t.Fatalf("unexpected stop: %v", got["stop"])
+	}
+	if _, ok := got["top_p"]; ok {
+		t.Fatalf("unset top_p should be omitted: %v", got)
+	}
+}
+
+func TestSummaryCommandTokenBudgetWarning(t *testing.T) {
+	root := newRoot()
+	root.SetArgs([]string{
+		"summary",
+		"summarize",
+		"--provider", "fake",
+		"--model", "fake-model",
+		"--max-tokens-in", "20",
+		"--no-cache",
+	})
+	root.SetIn(strings.NewReader(strings.Repeat("ERROR connection refused to db-01\n", 40)))
+	out := &bytes.Buffer{}
+	errOut := &bytes.Buffer{}
+	root.SetOut(out)
+	root.SetErr(errOut)
+
+	if err := root.Execute(); err != nil {
+		t.Fatalf("summary error: %v", err)
+	}
+	if !strings.Contains(errOut.String(), "input truncated to ~") || !strings.Contains(errOut.String(), "of 40 lines kept") {
+		t.Fatalf("missing truncation warning: %q", errOut.String())
+	}
+}
+
+func TestSummaryCommandMapReduceTokenBudget(t *testing.T) {
+	root := newRoot()
+	root.SetArgs([]string{
+		"summary",
+		"summarize",
+		"--provider", "fake",
+		"--model", "fake-model",
+		"--strategy", "map-reduce",
+		"--max-tokens-in", "20",
+		"--format", "json",
+		"--no-cache",
+	})
+	root.SetIn(strings.NewReader(strings.Repeat("ERROR connection refused to db-01\n", 40)))
+	out := &bytes.Buffer{}
+	root.SetOut(out)
+	root.SetErr(&bytes.Buffer{})
+
+	if err := root.Execute(); err != nil {
+		t.Fatalf("summary error: %v", err)
+	}
+	var got summaryResult
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("invalid json: %v", err)
+	}
+	// 9 estimated tokens per line: two lines per chunk, not one 40000-char chunk.
+	if got.Chunks != 20 {
+		t.Fatalf("chunks = %d, want 20", got.Chunks)
+	}
+}
+
+func TestSummaryCommandStrategyRejectsSingleFlags(t *testing.T) {
+	for _, flag := range []string{"--include-head=100", "--include-tail=100", "--stream=false"} {
+		root := newRoot()
+		root.SetArgs([]string{"summary", "summarize", "--provider", "fake", "--model", "m", "--strategy", "refine", flag})
+		root.SetIn(strings.NewReader("hello\n"))
+		root.SetOut(&bytes.Buffer{})
+		root.SetErr(&bytes.Buffer{})
+		if err := root.Execute(); err == nil || !strings.Contains(err.Error(), "only supported with --strategy single") {
+			t.Fatalf("%s with refine: %v", flag, err)
+		}
+	}
+}
+
+func TestSummaryCommandKeepsMaxCharsWithTokenBudget(t *testing.T) {
+	input := strings.Repeat("ERROR connection refused to db-01 port 5432\n", 2000)
+	for _, tc := range []struct {
+		args      []string
+		truncated bool
+	}{
+		{nil, true},
+		{[]string{"--max-chars", "0"}, false},
+	} {
+		root := newRoot()
+		root.SetArgs(append([]string{"summary", "summarize", "--provider", "fake", "--model", "gpt-4o", "--no-cache"}, tc.args...))
+		root.SetIn(strings.NewReader(input))
+		root.SetOut(&bytes.Buffer{})
+		errOut := &bytes.Buffer{}
+		root.SetErr(errOut)
+		if err := root.Execute(); err != nil {
+			t.Fatalf("summary %v error: %v", tc.args, err)
+		}
+		if got := strings.Contains(errOut.String(), "input truncated"); got != tc.truncated {
+			t.Fatalf("summary %v: truncated=%v, want %v (%q)", tc.args, got, tc.truncated, errOut.String())
+		}
+	}
+}
+
+func TestSummaryCommandStatsAndCost(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp := llm.ChatResponse{
+			Model: "gpt-4o-mini",
+			Usage: llm.Usage{PromptTokens: 1000, CompletionTokens: 500, TotalTokens: 1500},
+		}
+		resp.Choices = append(resp.Choices, struct {
+			Message llm.ChatMessage `json:"message"`
+		}{Message: llm.ChatMessage{Role: "assistant", Content: "ok"}})
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(server.Close)
+
+	root := newRoot()
+	root.SetArgs([]string{
+		"summary",
+		"summarize",
+		"--format", "json",
+		"--stream=false",
+		"--stats",
+		"--base-url", server.URL,
+		"--api-key", "key",
+		"--model", "gpt-4o-mini",
+		"--no-cache",
+	})
+	root.SetIn(strings.NewReader("hello\n"))
+	out := &bytes.Buffer{}
+	errOut := &bytes.Buffer{}
+	root.SetOut(out)
+	root.SetErr(errOut)
+
+	if err := root.Execute(); err != nil {
+		t.Fatalf("summary error: %v", err)
+	}
+	var got summaryResult
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("invalid json: %v", err)
+	}
+	if got.CostUSD == nil || *got.CostUSD < 0.000449 || *got.CostUSD > 0.000451 {
+		t.Fatalf("unexpected cost: %+v", got.llmMetrics)
+	}
+	if !strings.Contains(errOut.String(), "stats: calls=1 cached=0 tokens=1000+500 cost=$0.000450") {
+		t.Fatalf("missing stats footer: %q", errOut.String())
+	}
+}
+
+func TestSummaryCommandStatsOnFailure(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) > 1 {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":{"message":"context length exceeded"}}`))
+			return
+		}
+		resp := llm.ChatResponse{Model: "gpt-4o-mini", Usage: llm.Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15}}
+		resp.Choices = append(resp.Choices, struct {
+			Message llm.ChatMessage `json:"message"`
+		}{Message: llm.ChatMessage{Role: "assistant", Content: "partial"}})
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(server.Close)
+
+	root := newRoot()
+	root.SetArgs([]string{
+		"summary",
+		"summarize",
+		"--strategy", "map-reduce",
+		"--max-chars", "20",
+		"--concurrency", "1",
+		"--stats",
+		"--retries", "0",
+		"--base-url", server.URL,
+		"--api-key", "key",
+		"--model", "gpt-4o-mini",
+		"--no-cache",
+	})
+	root.SetIn(strings.NewReader("first chunk of text\nsecond chunk of text\n"))
+	root.SetOut(&bytes.Buffer{})
+	errOut := &bytes.Buffer{}
+	root.SetErr(errOut)
+
+	if err := root.Execute(); err == nil {
+		t.Fatal("expected the second call to fail")
+	}
+	if !strings.Contains(errOut.String(), "stats: calls=1 cached=0 tokens=10+5") {
+		t.Fatalf("missing stats footer after failure: %q", errOut.String())
+	}
+}
@@ -0,0 +1,361 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/yjhatfdu/aip/internal/cluster"
+	"github.com/yjhatfdu/aip/internal/i18n"
+	"github.com/yjhatfdu/aip/internal/llm"
+	"github.com/yjhatfdu/aip/internal/norm"
+	"github.com/yjhatfdu/aip/internal/summary"
+)
+
+type watchReport struct {
+	WindowStart string    `json:"window_start"`
+	WindowEnd   string    `json:"window_end"`
+	Lines       int       `json:"lines"`
+	Clusters    int       `json:"clusters"`
+	Dropped     int       `json:"dropped,omitempty"`
+	Output      string    `json:"output"`
+	Usage       llm.Usage `json:"usage,omitempty"`
+	Model       string    `json:"model,omitempty"`
+	llmMetrics
+}
+
+type watchEntry struct {
+	at     time.Time
+	record norm.Record
+}
+
+type watchWindow struct {
+	start   time.Time
+	end     time.Time
+	records []norm.Record
+	dropped int
+}
+
+// windowBuffer keeps the records of the last span. When maxChars is set the
+// oldest records are dropped once their raw lines exceed it, so a burst
+// cannot grow the buffer without bound; dropped counts them until the next
+// snapshot.
+type windowBuffer struct {
+	span     time.Duration
+	maxChars int
+	entries  []watchEntry
+	size     int
+	dropped  int
+}
+
+func (b *windowBuffer) add(at time.Time, rec norm.Record) {
+	b.entries = append(b.entries, watchEntry{at: at, record: rec})
+	b.size += len(rec.Raw)
+	for b.maxChars > 0 && b.size > b.maxChars && len(b.entries) > 1 {
+		b.size -= len(b.entries[0].record.Raw)
+		b.entries[0] = watchEntry{}
+		b.entries = b.entries[1:]
+		b.dropped++
+	}
+}
+
+func (b *windowBuffer) snapshot(now time.Time) watchWindow {
+	start := now.Add(-b.span)
+	drop := 0
+	for drop < len(b.entries) && b.entries[drop].at.Before(start) {
+		b.size -= len(b.entries[drop].record.Raw)
+		drop++
+	}
+	b.entries = append(b.entries[:0], b.entries[drop:]...)
+	records := make([]norm.Record, len(b.entries))
+	for i, e := range b.entries {
+		records[i] = e.record
+	}
+	w := watchWindow{start: start, end: now, records: records, dropped: b.dropped}
+	b.dropped = 0
+	return w
+}
+
+func newWatchCommand(lang i18n.Lang) *cobra.Command {
+	var (
+		window      time.Duration
+		every       time.Duration
+		policy      string
+		format      string
+		profile     string
+		rulesPath   string
+		top         int
+		threshold   int
+		maxChars    int
+		systemValue string
+		llmOpts     llmFlags
+	)
+
+	cmd := &cobra.Command{
+		Use:   "watch <prompt> [file]",
+		Short: i18n.T(lang, "cmd.watch.short"),
+		Args:  cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if window <= 0 || every <= 0 {
+				return errors.New("--window and --every must be > 0")
+			}
+			if policy != "drop" && policy != "coalesce" {
+				return fmt.Errorf("unknown policy: %s", policy)
+			}
+			if format == "" {
+				format = "text"
+			}
+			if format != "text" && format != "jsonl" {
+				return fmt.Errorf("unknown format: %s", format)
+			}
+			userPrompt, err := summary.LoadPrompt(args[0])
+			if err != nil {
+				return err
+			}
+			systemPrompt := summary.BuildSystemPrompt()
+			if systemValue != "" {
+				systemPrompt, err = summary.LoadPrompt(systemValue)
+				if err != nil {
+					return err
+				}
+			}
+			n, err := norm.New(profile, rulesPath, "")
+			if err != nil {
+				return err
+			}
+
+			var (
+				reader  io.Reader = cmd.InOrStdin()
+				srcFile           = ""
+			)
+			if len(args) == 2 {
+				file, err := os.Open(args[1])
+				if err != nil {
+					return err
+				}
+				defer file.Close()
+				reader = file
+				srcFile = args[1]
+			}
+
+			client, err := llmOpts.client()
+			if err != nil {
+				return err
+			}
+
+			ctx, cancel := context.WithCancel(cmd.Context())
+			defer cancel()
+
+			type scanned struct {
+				line int
+				text string
+			}
+			lines := make(chan scanned, 1024)
+			scanErr := make(chan error, 1)
+			go func() {
+				defer close(lines)
+				scanner := bufio.NewScanner(reader)
+				scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+				no := 0
+				for scanner.Scan() {
+					no++
+					select {
+					case lines <- scanned{line: no, text: scanner.Text()}:
+					case <-ctx.Done():
+						scanErr <- nil
+						return
+					}
+				}
+				scanErr <- scanner.Err()
+			}()
+
+			// The worker owns the LLM calls so a slow request never blocks
+			// the reader; windows arriving while it is busy are dropped or
+			// coalesced into the single pending slot.
+			jobs := make(chan watchWindow, 1)
+			if policy == "drop" {
+				jobs = make(chan watchWindow)
+			}
+			done := make(chan struct{})
+			out := cmd.OutOrStdout()
+			errOut := cmd.ErrOrStderr()
+			go func() {
+				defer close(done)
+				for w := range jobs {
+					report, err := runWatchWindow(ctx, client, systemPrompt, userPrompt, w, top, threshold)
+					if err != nil {
+						fmt.Fprintf(errOut, "watch: window %s: %v\n", w.end.Format(time.RFC3339), err)
+						continue
+					}
+					if err := writeWatchReport(out, format, report); err != nil {
+						fmt.Fprintf(errOut, "watch: %v\n", err)
+						cancel()
+						return
+					}
+				}
+			}()
+
+			buf := &windowBuffer{span: window, maxChars: maxChars}
+			ticker := time.NewTicker(every)
+			defer ticker.Stop()
+			var readErr error
+		loop:
+			for {
+				select {
+				case ln, ok := <-lines:
+					if !ok {
+						readErr = <-scanErr
+						break loop
+					}
+					buf.add(time.Now(), n.Normalize(ln.text, norm.Source{File: srcFile, Line: ln.line}))
+				case now := <-ticker.C:
+					submitWatchWindow(jobs, policy, buf, errOut, buf.snapshot(now))
+				case <-ctx.Done():
+					break loop
+				}
+			}
+
+			if ctx.Err() == nil {
+				if w := buf.snapshot(time.Now()); len(w.records) > 0 {
+					select {
+					case jobs <- w:
+					case <-ctx.Done():
+					}
+				}
+			}
+			close(jobs)
+			<-done
+			if readErr != nil && !errors.Is(readErr, io.EOF) {
+				if srcFile != "" {
+					return fmt.Errorf("%s: %w", srcFile, readErr)
+				}
+				return readErr
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().DurationVar(&window, "window", 5*time.Minute, "window length")
+	cmd.Flags().DurationVar(&every, "every", time.Minute, "report interval")
+	cmd.Flags().StringVar(&policy, "policy", "coalesce", "when the LLM is busy: drop|coalesce")
+	cmd.Flags().StringVar(&format, "format", "text", "format: text|jsonl")
+	cmd.Flags().StringVar(&profile, "profile", "generic", "norm profile "+normProfiles())
+	cmd.Flags().StringVar(&rulesPath, "rules", "", "rules file path (YAML)")
+	cmd.Flags().IntVar(&top, "top", 20, "clusters included in each prompt")
+	cmd.Flags().IntVar(&threshold, "threshold", 4, "simhash hamming distance threshold")
+	cmd.Flags().IntVar(&maxChars, "max-chars", 4<<20, "max raw chars buffered per window; older lines beyond it are dropped and counted (0 = unlimited)")
+	cmd.Flags().StringVar(&systemValue, "system", "", "system prompt or @file")
+	llmOpts.register(cmd, lang)
+	return cmd
+}
+
+// submitWatchWindow hands w to the worker. A window that is dropped, or
+// replaced while pending, returns its count of lines dropped over the buffer
+// limit to buf, so the next report still accounts for them.
+func submitWatchWindow(jobs chan watchWindow, policy string, buf *windowBuffer, errOut io.Writer, w watchWindow) {
+	if len(w.records) == 0 {
+		buf.dropped += w.dropped
+		return
+	}
+	select {
+	case jobs <- w:
+		return
+	default:
+	}
+	if policy == "coalesce" {
+		select {
+		case old := <-jobs:
+			w.dropped += old.dropped
+		default:
+		}
+		select {
+		case jobs <- w:
+			return
+		default:
+		}
+	}
+	buf.dropped += w.dropped
+	fmt.Fprintf(errOut, "watch: window %s dropped (%d lines), previous analysis still running\n", w.end.Format(time.RFC3339), len(w.records))
+}
+
+func runWatchWindow(ctx context.Context, client llm.Provider, systemPrompt, userPrompt string, w watchWindow, top, threshold int) (watchReport, error) {
+	clusters, err := cluster.ClusterSigs(sigInfosFromRecords(w.records), cluster.Params{
+		Threshold:  threshold,
+		Bands:      8,
+		BandBits:   8,
+		MinCluster: 1,
+		Samples:    2,
+	})
+	if err != nil {
+		return watchReport{}, err
+	}
+	report := watchReport{
+		WindowStart: w.start.UTC().Format(time.RFC3339),
+		WindowEnd:   w.end.UTC().Format(time.RFC3339),
+		Lines:       len(w.records),
+		Clusters:    len(clusters),
+		Dropped:     w.dropped,
+	}
+	if top > 0 && len(clusters) > top {
+		clusters = clusters[:top]
+	}
+	header := fmt.Sprintf("Log window %s to %s: %d lines in %d clusters (top %d shown as count, time range, signature, samples).",
+		report.WindowStart, report.WindowEnd, report.Lines, report.Clusters, len(clusters))
+	if report.Dropped > 0 {
+		header += fmt.Sprintf(" %d older lines were dropped over the buffer limit and are not counted.", report.Dropped)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer cancel()
+	resp, err := client.Complete(ctx, llm.ChatRequest{
+		Messages: []llm.ChatMessage{
+			{Role: "system", Content: systemPrompt},
+			{Role: "user", Content: summary.BuildUserPrompt(userPrompt, header+"\n\n"+renderClusterDigest(clusters))},
+		},
+	})
+	if err != nil {
+		return watchReport{}, err
+	}
+	if len(resp.Choices) == 0 {
+		return watchReport{}, errors.New("empty response")
+	}
+	report.Output = resp.Choices[0].Message.Content
+	report.Usage = resp.Usage
+	report.llmMetrics = newLLMMetrics(resp.Metrics)
+	report.Model = resp.Model
+	return report, nil
+}
+
+func renderClusterDigest(clusters []cluster.Cluster) string {
+	var b strings.Builder
+	for _, c := range clusters {
+		fmt.Fprintf(&b, "%d\t%s..%s\t%s\n", c.Count, c.FirstTS, c.LastTS, c.Repr)
+		for _, s := range c.Samples {
+			fmt.Fprintf(&b, "  sample: %s\n", s.Raw)
+		}
+	}
+	return b.String()
+}
+
+func writeWatchReport(w io.Writer, format string, report watchReport) error {
+	if format == "jsonl" {
+		enc := json.NewEncoder(w)
+		enc.SetEscapeHTML(false)
+		return enc.Encode(report)
+	}
+	text := strings.TrimRight(report.Output, "\n")
+	dropped := ""
+	if report.Dropped > 0 {
+		dropped = fmt.Sprintf(", %d dropped", report.Dropped)
+	}
+	_, err := fmt.Fprintf(w, "=== %s .. %s (%d lines, %d clusters%s) ===\n%s\n\n",
+		report.WindowStart, report.WindowEnd, report.Lines, report.Clusters, dropped, text)
+	return err
+}
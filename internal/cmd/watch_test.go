@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yjhatfdu/aip/internal/llm"
+	"github.com/yjhatfdu/aip/internal/norm"
+)
+
+func TestWatchCommandFlushesFinalWindow(t *testing.T) {
+	var prompt string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req llm.ChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode: %v", err)
+			return
+		}
+		prompt = req.Messages[len(req.Messages)-1].Content
+		resp := llm.ChatResponse{
+			Model: req.Model,
+			Choices: []struct {
+				Message llm.ChatMessage `json:"message"`
+			}{
+				{Message: llm.ChatMessage{Role: "assistant", Content: "all quiet"}},
+			},
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(server.Close)
+
+	root := newRoot()
+	root.SetArgs([]string{
+		"watch", "what changed?",
+		"--every", "1h",
+		"--format", "jsonl",
+		"--base-url", server.URL,
+		"--api-key", "key",
+		"--model", "model",
+	})
+	root.SetIn(strings.NewReader("conn 1 failed\nconn 2 failed\nconn 3 failed\n"))
+	out := &bytes.Buffer{}
+	root.SetOut(out)
+	root.SetErr(&bytes.Buffer{})
+
+	if err := root.Execute(); err != nil {
+		t.Fatalf("watch error: %v", err)
+	}
+	var report watchReport
+	if err := json.Unmarshal(out.Bytes(), &report); err != nil {
+		t.Fatalf("unmarshal %q: %v", out.String(), err)
+	}
+	if report.Lines != 3 || report.Clusters != 1 || report.Output != "all quiet" {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	if !strings.Contains(prompt, "3\t..\tconn <number> failed") {
+		t.Fatalf("cluster digest missing from prompt: %q", prompt)
+	}
+}
+
+func TestWindowBufferDropsExpired(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	buf := &windowBuffer{span: time.Minute}
+	buf.add(base, norm.Record{Raw: "old"})
+	buf.add(base.Add(90*time.Second), norm.Record{Raw: "new"})
+
+	w := buf.snapshot(base.Add(2 * time.Minute))
+	if len(w.records) != 1 || w.records[0].Raw != "new" {
+		t.Fatalf("unexpected window: %+v", w.records)
+	}
+	if !w.start.Equal(base.Add(time.Minute)) {
+		t.Fatalf("unexpected window start: %v", w.start)
+	}
+}
+
+func TestWindowBufferCapsBurst(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	buf := &windowBuffer{span: time.Minute, maxChars: 10}
+	for i := 0; i < 5; i++ {
+		buf.add(base, norm.Record{Raw: "line"})
+	}
+	w := buf.snapshot(base)
+	if len(w.records) != 2 || w.dropped != 3 {
+		t.Fatalf("expected 2 kept and 3 dropped, got %d and %d", len(w.records), w.dropped)
+	}
+	buf.add(base, norm.Record{Raw: "x"})
+	if w := buf.snapshot(base); len(w.records) != 3 || w.dropped != 0 {
+		t.Fatalf("expected the drop count to reset, got %d kept and %d dropped", len(w.records), w.dropped)
+	}
+}
+
+func TestSubmitWatchWindowKeepsDropCount(t *testing.T) {
+	buf := &windowBuffer{span: time.Minute}
+	errOut := &bytes.Buffer{}
+	rec := []norm.Record{{Raw: "line"}}
+
+	jobs := make(chan watchWindow, 1)
+	submitWatchWindow(jobs, "coalesce", buf, errOut, watchWindow{records: rec, dropped: 2})
+	submitWatchWindow(jobs, "coalesce", buf, errOut, watchWindow{records: rec, dropped: 3})
+	if w := <-jobs; w.dropped != 5 {
+		t.Fatalf("coalesced window should carry 5 dropped lines, got %d", w.dropped)
+	}
+
+	submitWatchWindow(make(chan watchWindow), "drop", buf, errOut, watchWindow{records: rec, dropped: 4})
+	submitWatchWindow(jobs, "drop", buf, errOut, watchWindow{dropped: 1})
+	if w := buf.snapshot(time.Now()); w.dropped != 5 {
+		t.Fatalf("next window should carry 5 dropped lines, got %d", w.dropped)
+	}
+	if !strings.Contains(errOut.String(), "dropped (1 lines)") {
+		t.Fatalf("missing drop notice: %q", errOut)
+	}
+}
@@ -0,0 +1,85 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// CommandSections are the commands whose flags can be defaulted from a
+// config table of the same name, e.g. [cluster] threshold = 6.
+var CommandSections = []string{"norm", "cluster", "summary"}
+
+func isCommandSection(name string) bool {
+	for _, section := range CommandSections {
+		if section == name {
+			return true
+		}
+	}
+	return false
+}
+
+// SplitCommandKey recognizes keys such as cluster.threshold or
+// cluster.min-cluster and returns the section and the key in TOML style,
+// with dashes turned into underscores.
+func SplitCommandKey(key string) (string, string, bool) {
+	section, name, ok := strings.Cut(key, ".")
+	if !ok || !isCommandSection(section) || name == "" || strings.Contains(name, ".") {
+		return "", "", false
+	}
+	return section, strings.ReplaceAll(name, "-", "_"), true
+}
+
+// FlagName maps a command key to its flag, e.g. min_cluster to min-cluster.
+func FlagName(key string) string {
+	return strings.ReplaceAll(key, "_", "-")
+}
+
+func setCommandValue(cfg *Config, section, name, value string) {
+	if value == "" {
+		delete(cfg.Commands[section], name)
+		return
+	}
+	if cfg.Commands == nil {
+		cfg.Commands = map[string]map[string]string{}
+	}
+	if cfg.Commands[section] == nil {
+		cfg.Commands[section] = map[string]string{}
+	}
+	cfg.Commands[section][name] = value
+}
+
+func mergeCommands(base, overrides map[string]map[string]string) map[string]map[string]string {
+	if len(overrides) == 0 {
+		return base
+	}
+	out := make(map[string]map[string]string, len(base)+len(overrides))
+	for section, values := range base {
+		out[section] = values
+	}
+	for section, values := range overrides {
+		out[section] = mergeMap(out[section], values)
+	}
+	return out
+}
+
+var plainLiteral = regexp.MustCompile(`^(true|false|[+-]?[0-9]+(\.[0-9]+)?)$`)
+
+func writeCommands(b *strings.Builder, commands map[string]map[string]string) {
+	for _, section := range CommandSections {
+		values := commands[section]
+		names := make([]string, 0, len(values))
+		for name := range values {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			val := values[name]
+			if !plainLiteral.MatchString(val) {
+				val = quote(val)
+			}
+			fmt.Fprintf(b, "%s.%s = %s\n", section, tomlKey(name), val)
+		}
+	}
+}
@@ -7,56 +7,230 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 )
 
 type Config struct {
-	BaseURL string
-	APIKey  string
-	Model   string
+	Provider string
+	BaseURL  string
+	APIKey   string
+	// APIKeyCmd and APIKeyFile supply the key when APIKey is empty; see
+	// ResolveAPIKey.
+	APIKeyCmd  string
+	APIKeyFile string
+	Model      string
+	MaxRetries *int
+	NumCtx     int
+	KeepAlive  string
+	Path       string
+	Query      map[string]string
+	AuthHeader string
+	AuthScheme string
+	Headers    map[string]string
+
+	Temperature *float64
+	MaxTokens   int
+	TopP        *float64
+	Seed        *int64
+	Stop        []string
+
+	// ContextSizes overrides the built-in context window table, in tokens
+	// per model name.
+	ContextSizes map[string]int
+	// Pricing maps model names to USD per million input/output tokens.
+	Pricing map[string]Price
+	// Commands holds flag defaults per command section (see
+	// CommandSections), keyed by flag name with underscores.
+	Commands map[string]map[string]string
+}
+
+type Price struct {
+	Input  float64
+	Output float64
+}
+
+func (p Price) String() string {
+	return strconv.FormatFloat(p.Input, 'f', -1, 64) + "/" + strconv.FormatFloat(p.Output, 'f', -1, 64)
+}
+
+// ParsePrice reads "input/output" in USD per million tokens, e.g. "0.15/0.60".
+func ParsePrice(v string) (Price, error) {
+	in, out, ok := strings.Cut(v, "/")
+	if !ok {
+		return Price{}, fmt.Errorf("expected input/output USD per 1M tokens, got %q", v)
+	}
+	input, err1 := strconv.ParseFloat(strings.TrimSpace(in), 64)
+	output, err2 := strconv.ParseFloat(strings.TrimSpace(out), 64)
+	if err1 != nil || err2 != nil || input < 0 || output < 0 {
+		return Price{}, fmt.Errorf("expected input/output USD per 1M tokens, got %q", v)
+	}
+	return Price{Input: input, Output: output}, nil
 }
 
+var ErrUnknownKey = errors.New("unknown config key")
+
+// LoadMerged returns the settings of the file at path for the profile named
+// by AIP_PROFILE (or default_profile) with environment overrides applied.
 func LoadMerged(path string) (Config, error) {
-	var cfg Config
-	if loaded, err := Load(path); err == nil {
-		cfg = loaded
-	} else if !os.IsNotExist(err) {
+	return LoadProfile(path, os.Getenv("AIP_PROFILE"))
+}
+
+// LoadProfile is LoadMerged for an explicitly selected profile; an empty
+// name selects default_profile. A missing file is not an error.
+func LoadProfile(path, name string) (Config, error) {
+	stack, err := LoadStack(path, "", name)
+	if err != nil {
 		return Config{}, err
 	}
-	applyEnv(&cfg)
-	return cfg, nil
+	return stack.Merged(), nil
 }
 
 func Merge(base Config, overrides Config) Config {
 	out := base
+	if overrides.Provider != "" {
+		out.Provider = overrides.Provider
+	}
 	if overrides.BaseURL != "" {
 		out.BaseURL = overrides.BaseURL
 	}
-	if overrides.APIKey != "" {
-		out.APIKey = overrides.APIKey
+	if overrides.APIKey != "" || overrides.APIKeyCmd != "" || overrides.APIKeyFile != "" {
+		// A credential source replaces the inherited one as a whole, so a
+		// profile's api_key_cmd is not shadowed by a top-level api_key.
+		out.APIKey, out.APIKeyCmd, out.APIKeyFile = overrides.APIKey, overrides.APIKeyCmd, overrides.APIKeyFile
 	}
 	if overrides.Model != "" {
 		out.Model = overrides.Model
 	}
+	if overrides.MaxRetries != nil {
+		out.MaxRetries = overrides.MaxRetries
+	}
+	if overrides.NumCtx != 0 {
+		out.NumCtx = overrides.NumCtx
+	}
+	if overrides.KeepAlive != "" {
+		out.KeepAlive = overrides.KeepAlive
+	}
+	if overrides.Path != "" {
+		out.Path = overrides.Path
+	}
+	if overrides.AuthHeader != "" {
+		out.AuthHeader = overrides.AuthHeader
+	}
+	if overrides.AuthScheme != "" {
+		out.AuthScheme = overrides.AuthScheme
+	}
+	if overrides.Temperature != nil {
+		out.Temperature = overrides.Temperature
+	}
+	if overrides.MaxTokens != 0 {
+		out.MaxTokens = overrides.MaxTokens
+	}
+	if overrides.TopP != nil {
+		out.TopP = overrides.TopP
+	}
+	if overrides.Seed != nil {
+		out.Seed = overrides.Seed
+	}
+	if len(overrides.Stop) > 0 {
+		out.Stop = overrides.Stop
+	}
+	if len(overrides.ContextSizes) > 0 {
+		sizes := make(map[string]int, len(base.ContextSizes)+len(overrides.ContextSizes))
+		for k, v := range base.ContextSizes {
+			sizes[k] = v
+		}
+		for k, v := range overrides.ContextSizes {
+			sizes[k] = v
+		}
+		out.ContextSizes = sizes
+	}
+	if len(overrides.Pricing) > 0 {
+		pricing := make(map[string]Price, len(base.Pricing)+len(overrides.Pricing))
+		for k, v := range base.Pricing {
+			pricing[k] = v
+		}
+		for k, v := range overrides.Pricing {
+			pricing[k] = v
+		}
+		out.Pricing = pricing
+	}
+	out.Commands = mergeCommands(base.Commands, overrides.Commands)
+	out.Query = mergeMap(base.Query, overrides.Query)
+	out.Headers = mergeMap(base.Headers, overrides.Headers)
 	return out
 }
 
-func applyEnv(cfg *Config) {
-	if val := pickEnv("AIP_BASE_URL", "OPENAI_BASE_URL"); val != "" {
-		cfg.BaseURL = val
+func mergeMap(base, overrides map[string]string) map[string]string {
+	if len(overrides) == 0 {
+		return base
+	}
+	out := make(map[string]string, len(base)+len(overrides))
+	for k, v := range base {
+		out[k] = v
 	}
-	if val := pickEnv("AIP_API_KEY", "OPENAI_API_KEY"); val != "" {
-		cfg.APIKey = val
+	for k, v := range overrides {
+		out[k] = v
 	}
-	if val := pickEnv("AIP_MODEL"); val != "" {
-		cfg.Model = val
+	return out
+}
+
+// envVars maps config keys to the variables that override them, in order of
+// preference.
+var envVars = []struct {
+	key   string
+	names []string
+}{
+	{"provider", []string{"AIP_PROVIDER"}},
+	{"base_url", []string{"AIP_BASE_URL", "OPENAI_BASE_URL"}},
+	{"api_key", []string{"AIP_API_KEY", "OPENAI_API_KEY"}},
+	{"model", []string{"AIP_MODEL"}},
+	{"max_retries", []string{"AIP_MAX_RETRIES"}},
+	{"num_ctx", []string{"AIP_NUM_CTX"}},
+	{"keep_alive", []string{"AIP_KEEP_ALIVE"}},
+}
+
+// envConfig holds the settings given through AIP_* variables.
+func envConfig() Config {
+	cfg, _ := readEnv()
+	return cfg
+}
+
+// EnvErrors reports variables whose values are ignored because they are
+// malformed.
+func EnvErrors() []error {
+	_, errs := readEnv()
+	return errs
+}
+
+func readEnv() (Config, []error) {
+	var (
+		cfg  Config
+		errs []error
+	)
+	for _, env := range envVars {
+		name := EnvVar(env.key)
+		if name == "" {
+			continue
+		}
+		if err := SetByKey(&cfg, env.key, os.Getenv(name)); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
 	}
+	return cfg, errs
 }
 
-func pickEnv(keys ...string) string {
-	for _, key := range keys {
-		if val := os.Getenv(key); val != "" {
-			return val
+// EnvVar returns the variable that sets key, or "" when none does.
+func EnvVar(key string) string {
+	for _, env := range envVars {
+		if env.key != key {
+			continue
+		}
+		for _, name := range env.names {
+			if os.Getenv(name) != "" {
+				return name
+			}
 		}
 	}
 	return ""
@@ -64,29 +238,232 @@ func pickEnv(keys ...string) string {
 
 func GetByKey(cfg Config, key string) (string, bool) {
 	switch key {
+	case "provider":
+		return cfg.Provider, cfg.Provider != ""
 	case "base_url":
 		return cfg.BaseURL, cfg.BaseURL != ""
 	case "api_key":
 		return cfg.APIKey, cfg.APIKey != ""
+	case "api_key_cmd":
+		return cfg.APIKeyCmd, cfg.APIKeyCmd != ""
+	case "api_key_file":
+		return cfg.APIKeyFile, cfg.APIKeyFile != ""
 	case "model":
 		return cfg.Model, cfg.Model != ""
-	default:
-		return "", false
+	case "max_retries":
+		if cfg.MaxRetries == nil {
+			return "", false
+		}
+		return strconv.Itoa(*cfg.MaxRetries), true
+	case "num_ctx":
+		if cfg.NumCtx == 0 {
+			return "", false
+		}
+		return strconv.Itoa(cfg.NumCtx), true
+	case "keep_alive":
+		return cfg.KeepAlive, cfg.KeepAlive != ""
+	case "path":
+		return cfg.Path, cfg.Path != ""
+	case "auth_header":
+		return cfg.AuthHeader, cfg.AuthHeader != ""
+	case "auth_scheme":
+		return cfg.AuthScheme, cfg.AuthScheme != ""
+	case "temperature":
+		if cfg.Temperature == nil {
+			return "", false
+		}
+		return strconv.FormatFloat(*cfg.Temperature, 'g', -1, 64), true
+	case "max_tokens":
+		if cfg.MaxTokens == 0 {
+			return "", false
+		}
+		return strconv.Itoa(cfg.MaxTokens), true
+	case "top_p":
+		if cfg.TopP == nil {
+			return "", false
+		}
+		return strconv.FormatFloat(*cfg.TopP, 'g', -1, 64), true
+	case "seed":
+		if cfg.Seed == nil {
+			return "", false
+		}
+		return strconv.FormatInt(*cfg.Seed, 10), true
+	case "stop":
+		return strings.Join(cfg.Stop, ","), len(cfg.Stop) > 0
+	}
+	if section, name, ok := SplitCommandKey(key); ok {
+		val, ok := cfg.Commands[section][name]
+		return val, ok
 	}
+	if table, name, ok := splitMapKey(key); ok {
+		val, ok := mapField(cfg, table)[name]
+		return val, ok
+	}
+	return "", false
 }
 
-func SetByKey(cfg *Config, key, value string) bool {
+func SetByKey(cfg *Config, key, value string) error {
 	switch key {
+	case "provider":
+		cfg.Provider = value
 	case "base_url":
 		cfg.BaseURL = value
 	case "api_key":
 		cfg.APIKey = value
+	case "api_key_cmd":
+		cfg.APIKeyCmd = value
+	case "api_key_file":
+		cfg.APIKeyFile = value
 	case "model":
 		cfg.Model = value
+	case "max_retries":
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return fmt.Errorf("%s: expected a non-negative integer", key)
+		}
+		cfg.MaxRetries = &n
+	case "num_ctx":
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return fmt.Errorf("%s: expected a non-negative integer", key)
+		}
+		cfg.NumCtx = n
+	case "keep_alive":
+		cfg.KeepAlive = value
+	case "path":
+		cfg.Path = value
+	case "auth_header":
+		cfg.AuthHeader = value
+	case "auth_scheme":
+		cfg.AuthScheme = value
+	case "temperature", "top_p":
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil || f < 0 {
+			return fmt.Errorf("%s: expected a non-negative number", key)
+		}
+		if key == "temperature" {
+			cfg.Temperature = &f
+		} else {
+			cfg.TopP = &f
+		}
+	case "max_tokens":
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return fmt.Errorf("%s: expected a non-negative integer", key)
+		}
+		cfg.MaxTokens = n
+	case "seed":
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%s: expected an integer", key)
+		}
+		cfg.Seed = &n
+	case "stop":
+		cfg.Stop = nil
+		if value != "" {
+			cfg.Stop = strings.Split(value, ",")
+		}
 	default:
-		return false
+		if section, name, ok := SplitCommandKey(key); ok {
+			setCommandValue(cfg, section, name, value)
+			return nil
+		}
+		table, name, ok := splitMapKey(key)
+		if !ok {
+			return ErrUnknownKey
+		}
+		return setMapValue(cfg, table, name, value)
 	}
-	return true
+	return nil
+}
+
+// splitMapKey recognizes map entries such as query.api-version,
+// headers."X-Team", context_sizes."gpt-4o" or pricing."gpt-4o"; the name may
+// be quoted as in the TOML file.
+func splitMapKey(key string) (string, string, bool) {
+	table, name, ok := strings.Cut(key, ".")
+	switch table {
+	case "query", "headers", "context_sizes", "pricing":
+	default:
+		ok = false
+	}
+	if !ok {
+		return "", "", false
+	}
+	if unquoted, err := strconv.Unquote(name); err == nil {
+		name = unquoted
+	}
+	if name == "" {
+		return "", "", false
+	}
+	return table, name, true
+}
+
+func mapField(cfg Config, table string) map[string]string {
+	switch table {
+	case "query":
+		return cfg.Query
+	case "context_sizes":
+		out := make(map[string]string, len(cfg.ContextSizes))
+		for name, size := range cfg.ContextSizes {
+			out[name] = strconv.Itoa(size)
+		}
+		return out
+	case "pricing":
+		out := make(map[string]string, len(cfg.Pricing))
+		for name, price := range cfg.Pricing {
+			out[name] = price.String()
+		}
+		return out
+	}
+	return cfg.Headers
+}
+
+// setMapValue sets one entry; an empty value removes it.
+func setMapValue(cfg *Config, table, name, value string) error {
+	if table == "pricing" {
+		if value == "" {
+			delete(cfg.Pricing, name)
+			return nil
+		}
+		price, err := ParsePrice(value)
+		if err != nil {
+			return fmt.Errorf("%s.%s: %w", table, name, err)
+		}
+		if cfg.Pricing == nil {
+			cfg.Pricing = map[string]Price{}
+		}
+		cfg.Pricing[name] = price
+		return nil
+	}
+	if table == "context_sizes" {
+		if value == "" {
+			delete(cfg.ContextSizes, name)
+			return nil
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return fmt.Errorf("%s.%s: expected a positive integer", table, name)
+		}
+		if cfg.ContextSizes == nil {
+			cfg.ContextSizes = map[string]int{}
+		}
+		cfg.ContextSizes[name] = n
+		return nil
+	}
+	m := &cfg.Headers
+	if table == "query" {
+		m = &cfg.Query
+	}
+	if value == "" {
+		delete(*m, name)
+		return nil
+	}
+	if *m == nil {
+		*m = map[string]string{}
+	}
+	(*m)[name] = value
+	return nil
 }
 
 func DefaultPath() (string, error) {
@@ -105,26 +482,27 @@ func EnsureDir(path string) error {
 	return os.MkdirAll(dir, 0o755)
 }
 
+// Load returns the file settings for default_profile, without environment
+// overrides.
 func Load(path string) (Config, error) {
-	f, err := os.Open(path)
+	f, err := ReadFile(path)
 	if err != nil {
 		return Config{}, err
 	}
-	defer f.Close()
-	return parseTOML(f), nil
+	return f.Resolve("")
 }
 
+// Save writes cfg as the top-level settings, keeping the profiles and unknown
+// keys already in the file.
 func Save(path string, cfg Config) error {
-	if err := EnsureDir(path); err != nil {
-		return err
-	}
-	f, err := os.Create(path)
-	if err != nil {
+	f, err := ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		f = &File{}
+	} else if err != nil {
 		return err
 	}
-	defer f.Close()
-	_, err = f.WriteString(renderTOML(cfg))
-	return err
+	f.Config = cfg
+	return f.Save(path)
 }
 
 func Wizard(r io.Reader, w io.Writer, current Config) (Config, error) {
@@ -144,12 +522,93 @@ func Wizard(r io.Reader, w io.Writer, current Config) (Config, error) {
 		return Config{}, err
 	}
 
+	provider, err := prompt(reader, w, "AIP_PROVIDER", current.Provider)
+	if err != nil {
+		return Config{}, err
+	}
+	if provider == "ollama" {
+		numCtx := ""
+		if current.NumCtx > 0 {
+			numCtx = strconv.Itoa(current.NumCtx)
+		}
+		numCtx, err = prompt(reader, w, "AIP_NUM_CTX", numCtx)
+		if err != nil {
+			return Config{}, err
+		}
+		if numCtx != "" {
+			if err := SetByKey(&cfg, "num_ctx", numCtx); err != nil {
+				return Config{}, err
+			}
+		}
+		cfg.KeepAlive, err = prompt(reader, w, "AIP_KEEP_ALIVE", current.KeepAlive)
+		if err != nil {
+			return Config{}, err
+		}
+	}
+	if provider == "" || provider == "openai" {
+		if cfg.Path, err = prompt(reader, w, "AIP_PATH", current.Path); err != nil {
+			return Config{}, err
+		}
+		query, err := prompt(reader, w, "AIP_QUERY", formatPairs(current.Query))
+		if err != nil {
+			return Config{}, err
+		}
+		if cfg.Query, err = parsePairs(query); err != nil {
+			return Config{}, fmt.Errorf("query: %w", err)
+		}
+		if cfg.AuthHeader, err = prompt(reader, w, "AIP_AUTH_HEADER", current.AuthHeader); err != nil {
+			return Config{}, err
+		}
+		if cfg.AuthScheme, err = prompt(reader, w, "AIP_AUTH_SCHEME", current.AuthScheme); err != nil {
+			return Config{}, err
+		}
+		headers, err := prompt(reader, w, "AIP_HEADERS", formatPairs(current.Headers))
+		if err != nil {
+			return Config{}, err
+		}
+		if cfg.Headers, err = parsePairs(headers); err != nil {
+			return Config{}, fmt.Errorf("headers: %w", err)
+		}
+	}
+
 	cfg.BaseURL = baseURL
 	cfg.APIKey = apiKey
 	cfg.Model = model
+	cfg.Provider = provider
 	return cfg, nil
 }
 
+// formatPairs and parsePairs map a query or header table to the
+// "name=value,name=value" form used by the wizard.
+func formatPairs(m map[string]string) string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = name + "=" + m[name]
+	}
+	return strings.Join(parts, ",")
+}
+
+func parsePairs(v string) (map[string]string, error) {
+	if strings.TrimSpace(v) == "" {
+		return nil, nil
+	}
+	out := map[string]string{}
+	for _, part := range strings.Split(v, ",") {
+		name, val, ok := strings.Cut(part, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("expected name=value, got %q", part)
+		}
+		out[name] = strings.TrimSpace(val)
+	}
+	return out, nil
+}
+
 func prompt(r *bufio.Reader, w io.Writer, name, current string) (string, error) {
 	if current != "" {
 		fmt.Fprintf(w, "%s [%s]: ", name, current)
@@ -167,11 +626,63 @@ func prompt(r *bufio.Reader, w io.Writer, name, current string) (string, error)
 	return line, nil
 }
 
+func Render(cfg Config) string {
+	return renderTOML(cfg)
+}
+
 func renderTOML(cfg Config) string {
 	var b strings.Builder
+	writeKV(&b, "provider", cfg.Provider)
 	writeKV(&b, "base_url", cfg.BaseURL)
 	writeKV(&b, "api_key", cfg.APIKey)
+	writeKV(&b, "api_key_cmd", cfg.APIKeyCmd)
+	writeKV(&b, "api_key_file", cfg.APIKeyFile)
 	writeKV(&b, "model", cfg.Model)
+	if cfg.MaxRetries != nil {
+		fmt.Fprintf(&b, "max_retries = %d\n", *cfg.MaxRetries)
+	}
+	if cfg.NumCtx > 0 {
+		fmt.Fprintf(&b, "num_ctx = %d\n", cfg.NumCtx)
+	}
+	writeKV(&b, "keep_alive", cfg.KeepAlive)
+	writeKV(&b, "path", cfg.Path)
+	writeKV(&b, "auth_header", cfg.AuthHeader)
+	writeKV(&b, "auth_scheme", cfg.AuthScheme)
+	if cfg.Temperature != nil {
+		fmt.Fprintf(&b, "temperature = %s\n", strconv.FormatFloat(*cfg.Temperature, 'g', -1, 64))
+	}
+	if cfg.MaxTokens > 0 {
+		fmt.Fprintf(&b, "max_tokens = %d\n", cfg.MaxTokens)
+	}
+	if cfg.TopP != nil {
+		fmt.Fprintf(&b, "top_p = %s\n", strconv.FormatFloat(*cfg.TopP, 'g', -1, 64))
+	}
+	if cfg.Seed != nil {
+		fmt.Fprintf(&b, "seed = %d\n", *cfg.Seed)
+	}
+	if len(cfg.Stop) > 0 {
+		stop := make([]string, len(cfg.Stop))
+		for i, s := range cfg.Stop {
+			stop[i] = quote(s)
+		}
+		fmt.Fprintf(&b, "stop = [%s]\n", strings.Join(stop, ", "))
+	}
+	writeMap(&b, "query", cfg.Query)
+	writeMap(&b, "headers", cfg.Headers)
+	sizes := make([]string, 0, len(cfg.ContextSizes))
+	for name := range cfg.ContextSizes {
+		sizes = append(sizes, name)
+	}
+	sort.Strings(sizes)
+	for _, name := range sizes {
+		fmt.Fprintf(&b, "context_sizes.%s = %d\n", quote(name), cfg.ContextSizes[name])
+	}
+	prices := make(map[string]string, len(cfg.Pricing))
+	for name, price := range cfg.Pricing {
+		prices[name] = price.String()
+	}
+	writeMap(&b, "pricing", prices)
+	writeCommands(&b, cfg.Commands)
 	return b.String()
 }
 
@@ -180,40 +691,18 @@ func writeKV(b *strings.Builder, key, val string) {
 		return
 	}
 	b.WriteString(key)
-	b.WriteString(" = \"")
-	b.WriteString(escape(val))
-	b.WriteString("\"\n")
+	b.WriteString(" = ")
+	b.WriteString(quote(val))
+	b.WriteString("\n")
 }
 
-func escape(v string) string {
-	v = strings.ReplaceAll(v, "\\", "\\\\")
-	v = strings.ReplaceAll(v, "\"", "\\\"")
-	return v
-}
-
-func parseTOML(r io.Reader) Config {
-	var cfg Config
-	scanner := bufio.NewScanner(r)
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line == "" || strings.HasPrefix(line, "#") {
-			continue
-		}
-		parts := strings.SplitN(line, "=", 2)
-		if len(parts) != 2 {
-			continue
-		}
-		key := strings.TrimSpace(parts[0])
-		val := strings.TrimSpace(parts[1])
-		val = strings.Trim(val, "\"")
-		switch key {
-		case "base_url":
-			cfg.BaseURL = val
-		case "api_key":
-			cfg.APIKey = val
-		case "model":
-			cfg.Model = val
-		}
+func writeMap(b *strings.Builder, table string, m map[string]string) {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		writeKV(b, table+"."+quote(name), m[name])
 	}
-	return cfg
 }
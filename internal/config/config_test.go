@@ -2,6 +2,7 @@ package config
 
 import (
 	"bytes"
+	"errors"
 	"path/filepath"
 	"strings"
 	"testing"
@@ -14,7 +15,7 @@ func TestRenderAndParseTOML(t *testing.T) {
 		Model:   "gpt-test",
 	}
 	out := renderTOML(cfg)
-	parsed := parseTOML(strings.NewReader(out))
+	parsed := mustParse(t, out)
 
 	if parsed.BaseURL != cfg.BaseURL {
 		t.Fatalf("BaseURL mismatch: got %q want %q", parsed.BaseURL, cfg.BaseURL)
@@ -99,3 +100,154 @@ func TestLoadMergedUsesEnvOverrides(t *testing.T) {
 		t.Fatalf("Model override failed: %q", merged.Model)
 	}
 }
+
+func TestMaxRetriesRoundTrip(t *testing.T) {
+	var cfg Config
+	if err := SetByKey(&cfg, "max_retries", "5"); err != nil {
+		t.Fatalf("SetByKey error: %v", err)
+	}
+	if err := SetByKey(&cfg, "max_retries", "many"); err == nil {
+		t.Fatal("expected error for non-numeric max_retries")
+	}
+	parsed := mustParse(t, renderTOML(cfg))
+	if got, ok := GetByKey(parsed, "max_retries"); !ok || got != "5" {
+		t.Fatalf("max_retries = %q, %v", got, ok)
+	}
+	if err := SetByKey(&cfg, "nope", "1"); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("expected ErrUnknownKey, got %v", err)
+	}
+}
+
+func TestWizardOllamaOptions(t *testing.T) {
+	in := bytes.NewBufferString("http://localhost:11434\n\nllama3\nollama\n8192\n10m\n")
+	cfg, err := Wizard(in, &bytes.Buffer{}, Config{})
+	if err != nil {
+		t.Fatalf("wizard error: %v", err)
+	}
+	if cfg.Provider != "ollama" || cfg.NumCtx != 8192 || cfg.KeepAlive != "10m" || cfg.APIKey != "" {
+		t.Fatalf("unexpected config: %+v", cfg)
+	}
+	parsed := mustParse(t, renderTOML(cfg))
+	if parsed.Provider != cfg.Provider || parsed.NumCtx != cfg.NumCtx || parsed.KeepAlive != cfg.KeepAlive {
+		t.Fatalf("round trip mismatch: %+v", parsed)
+	}
+}
+
+func TestMapKeysRoundTrip(t *testing.T) {
+	var cfg Config
+	for key, val := range map[string]string{
+		"path":               "/openai/deployments/{model}/chat/completions",
+		"query.api-version":  "2024-06-01",
+		`headers."X-Team"`:   "infra",
+		"auth_header":        "api-key",
+		"headers.X-Trace-On": "1",
+	} {
+		if err := SetByKey(&cfg, key, val); err != nil {
+			t.Fatalf("SetByKey(%s) error: %v", key, err)
+		}
+	}
+	if err := SetByKey(&cfg, "headers.X-Trace-On", ""); err != nil {
+		t.Fatalf("SetByKey remove error: %v", err)
+	}
+	parsed := mustParse(t, renderTOML(cfg))
+	if got, ok := GetByKey(parsed, "headers.X-Team"); !ok || got != "infra" {
+		t.Fatalf("headers.X-Team = %q, %v", got, ok)
+	}
+	if got, ok := GetByKey(parsed, `query."api-version"`); !ok || got != "2024-06-01" {
+		t.Fatalf("query.api-version = %q, %v", got, ok)
+	}
+	if _, ok := parsed.Headers["X-Trace-On"]; ok || parsed.Path != cfg.Path || parsed.AuthHeader != "api-key" {
+		t.Fatalf("unexpected parsed config: %+v", parsed)
+	}
+	if err := SetByKey(&cfg, "other.key", "x"); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("expected ErrUnknownKey, got %v", err)
+	}
+}
+
+func TestWizardGatewayOptions(t *testing.T) {
+	in := bytes.NewBufferString("https://corp.openai.azure.com\nsecret\ngpt4o\n\n/openai/deployments/{model}/chat/completions\napi-version=2024-06-01\napi-key\n\nX-Team=infra\n")
+	cfg, err := Wizard(in, &bytes.Buffer{}, Config{})
+	if err != nil {
+		t.Fatalf("wizard error: %v", err)
+	}
+	if cfg.Query["api-version"] != "2024-06-01" || cfg.AuthHeader != "api-key" || cfg.Headers["X-Team"] != "infra" || cfg.AuthScheme != "" {
+		t.Fatalf("unexpected config: %+v", cfg)
+	}
+}
+
+func TestGenerationParamsRoundTrip(t *testing.T) {
+	var cfg Config
+	for key, val := range map[string]string{"temperature": "0.2", "max_tokens": "512", "top_p": "0.9", "seed": "42", "stop": "END,###"} {
+		if err := SetByKey(&cfg, key, val); err != nil {
+			t.Fatalf("SetByKey(%s) error: %v", key, err)
+		}
+	}
+	if err := SetByKey(&cfg, "temperature", "hot"); err == nil {
+		t.Fatal("expected error for non-numeric temperature")
+	}
+	parsed := mustParse(t, renderTOML(cfg))
+	for key, want := range map[string]string{"temperature": "0.2", "max_tokens": "512", "top_p": "0.9", "seed": "42", "stop": "END,###"} {
+		if got, ok := GetByKey(parsed, key); !ok || got != want {
+			t.Fatalf("%s = %q, %v; want %q", key, got, ok, want)
+		}
+	}
+}
+
+func TestPricingRoundTrip(t *testing.T) {
+	var cfg Config
+	if err := SetByKey(&cfg, `pricing."my-model"`, "0.5/1.5"); err != nil {
+		t.Fatalf("SetByKey error: %v", err)
+	}
+	if err := SetByKey(&cfg, "pricing.other", "cheap"); err == nil {
+		t.Fatal("expected error for malformed price")
+	}
+	parsed := mustParse(t, renderTOML(cfg))
+	if p := parsed.Pricing["my-model"]; p.Input != 0.5 || p.Output != 1.5 {
+		t.Fatalf("unexpected pricing: %+v", parsed.Pricing)
+	}
+	if got, ok := GetByKey(parsed, "pricing.my-model"); !ok || got != "0.5/1.5" {
+		t.Fatalf("pricing.my-model = %q, %v", got, ok)
+	}
+}
+
+func TestCommandSections(t *testing.T) {
+	f, err := ParseFile([]byte(`
+[cluster]
+threshold = 6
+min-cluster = 3
+
+[norm]
+profile = "postgres"
+rules = "ours.yaml"
+`))
+	if err != nil {
+		t.Fatalf("ParseFile error: %v", err)
+	}
+	cfg := f.Config
+	if got, ok := GetByKey(cfg, "cluster.min_cluster"); !ok || got != "3" {
+		t.Fatalf("cluster.min_cluster = %q, %v", got, ok)
+	}
+	if err := SetByKey(&cfg, "summary.max-chars", "8000"); err != nil {
+		t.Fatalf("SetByKey error: %v", err)
+	}
+	if err := SetByKey(&cfg, "cluster.threshold", ""); err != nil {
+		t.Fatalf("SetByKey remove error: %v", err)
+	}
+	out := renderTOML(cfg)
+	for _, want := range []string{"norm.profile = \"postgres\"\n", "cluster.min_cluster = 3\n", "summary.max_chars = 8000\n"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("render missing %q:\n%s", want, out)
+		}
+	}
+	parsed := mustParse(t, out)
+	if _, ok := parsed.Commands["cluster"]["threshold"]; ok || parsed.Commands["norm"]["rules"] != "ours.yaml" {
+		t.Fatalf("unexpected round trip: %+v", parsed.Commands)
+	}
+	merged := Merge(parsed, Config{Commands: map[string]map[string]string{"cluster": {"threshold": "8"}}})
+	if merged.Commands["cluster"]["threshold"] != "8" || merged.Commands["cluster"]["min_cluster"] != "3" {
+		t.Fatalf("sections not merged per key: %+v", merged.Commands)
+	}
+	if err := SetByKey(&cfg, "reduce.top", "5"); err != ErrUnknownKey {
+		t.Fatalf("expected ErrUnknownKey for reduce.top, got %v", err)
+	}
+}
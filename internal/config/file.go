@@ -0,0 +1,346 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// File is a parsed config file: top-level settings, named profiles under
+// [profiles.<name>] and any keys aip does not know, which Save writes back
+// unchanged.
+type File struct {
+	Config         Config
+	DefaultProfile string
+
+	extra    map[string]any
+	profiles map[string]*profile
+}
+
+type profile struct {
+	cfg   Config
+	extra map[string]any
+}
+
+func ReadFile(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := ParseFile(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return f, nil
+}
+
+func ParseFile(data []byte) (*File, error) {
+	var raw map[string]any
+	if _, err := toml.Decode(string(data), &raw); err != nil {
+		return nil, err
+	}
+	f := &File{}
+	var err error
+	if f.Config, f.extra, err = decodeTable(raw, ""); err != nil {
+		return nil, err
+	}
+	if v, ok := f.extra["default_profile"]; ok {
+		name, ok := v.(string)
+		if !ok {
+			return nil, errors.New("default_profile: expected a string")
+		}
+		f.DefaultProfile = name
+		delete(f.extra, "default_profile")
+	}
+	if v, ok := f.extra["profiles"]; ok {
+		tables, ok := v.(map[string]any)
+		if !ok {
+			return nil, errors.New("profiles: expected a table")
+		}
+		delete(f.extra, "profiles")
+		for name, tv := range tables {
+			table, ok := tv.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("profiles.%s: expected a table", name)
+			}
+			cfg, extra, err := decodeTable(table, "profiles."+name+".")
+			if err != nil {
+				return nil, err
+			}
+			if f.profiles == nil {
+				f.profiles = map[string]*profile{}
+			}
+			f.profiles[name] = &profile{cfg: cfg, extra: extra}
+		}
+	}
+	return f, nil
+}
+
+// decodeTable maps the keys of one table onto a Config and returns the keys
+// it does not know.
+func decodeTable(table map[string]any, prefix string) (Config, map[string]any, error) {
+	var cfg Config
+	extra := map[string]any{}
+	for key, v := range table {
+		switch key {
+		case "query", "headers", "context_sizes", "pricing":
+			entries, ok := v.(map[string]any)
+			if !ok {
+				return Config{}, nil, fmt.Errorf("%s%s: expected a table", prefix, key)
+			}
+			for name, ev := range entries {
+				if err := setMapValue(&cfg, key, name, valueString(ev)); err != nil {
+					return Config{}, nil, fmt.Errorf("%s%w", prefix, err)
+				}
+			}
+		case "norm", "cluster", "summary":
+			entries, ok := v.(map[string]any)
+			if !ok {
+				return Config{}, nil, fmt.Errorf("%s%s: expected a table", prefix, key)
+			}
+			for name, ev := range entries {
+				if _, isTable := ev.(map[string]any); isTable {
+					return Config{}, nil, fmt.Errorf("%s%s.%s: expected a value", prefix, key, name)
+				}
+				setCommandValue(&cfg, key, strings.ReplaceAll(name, "-", "_"), valueString(ev))
+			}
+		case "stop":
+			list, ok := v.([]any)
+			if !ok {
+				return Config{}, nil, fmt.Errorf("%s%s: expected an array of strings", prefix, key)
+			}
+			cfg.Stop = make([]string, len(list))
+			for i, item := range list {
+				cfg.Stop[i] = valueString(item)
+			}
+		default:
+			err := SetByKey(&cfg, key, valueString(v))
+			if errors.Is(err, ErrUnknownKey) {
+				extra[key] = v
+			} else if err != nil {
+				return Config{}, nil, fmt.Errorf("%s%w", prefix, err)
+			}
+		}
+	}
+	return cfg, extra, nil
+}
+
+func valueString(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case []any:
+		// Lists feed slice flags, which take comma-separated values.
+		parts := make([]string, len(v))
+		for i, item := range v {
+			parts[i] = valueString(item)
+		}
+		return strings.Join(parts, ",")
+	}
+	return fmt.Sprint(v)
+}
+
+// Resolve returns the top-level settings overlaid with the named profile, or
+// with default_profile when name is empty.
+func (f *File) Resolve(name string) (Config, error) {
+	if name == "" {
+		name = f.DefaultProfile
+	}
+	if name == "" {
+		return f.Config, nil
+	}
+	p, ok := f.profiles[name]
+	if !ok {
+		return Config{}, fmt.Errorf("unknown profile: %s", name)
+	}
+	return Merge(f.Config, p.cfg), nil
+}
+
+func (f *File) Profile(name string) (Config, bool) {
+	p, ok := f.profiles[name]
+	if !ok {
+		return Config{}, false
+	}
+	return p.cfg, true
+}
+
+// SetProfile creates or replaces the settings of a profile, keeping its
+// unknown keys.
+func (f *File) SetProfile(name string, cfg Config) {
+	if f.profiles == nil {
+		f.profiles = map[string]*profile{}
+	}
+	if p, ok := f.profiles[name]; ok {
+		p.cfg = cfg
+		return
+	}
+	f.profiles[name] = &profile{cfg: cfg}
+}
+
+// RemoveProfile deletes a profile and clears default_profile if it pointed
+// at it.
+func (f *File) RemoveProfile(name string) bool {
+	if _, ok := f.profiles[name]; !ok {
+		return false
+	}
+	delete(f.profiles, name)
+	if f.DefaultProfile == name {
+		f.DefaultProfile = ""
+	}
+	return true
+}
+
+func (f *File) ProfileNames() []string {
+	names := make([]string, 0, len(f.profiles))
+	for name := range f.profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (f *File) Render() string {
+	var b strings.Builder
+	writeKV(&b, "default_profile", f.DefaultProfile)
+	b.WriteString(renderTOML(f.Config))
+	writeExtra(&b, "", f.extra)
+	for _, name := range f.ProfileNames() {
+		p := f.profiles[name]
+		fmt.Fprintf(&b, "\n[profiles.%s]\n", tomlKey(name))
+		b.WriteString(renderTOML(p.cfg))
+		writeExtra(&b, "", p.extra)
+	}
+	return b.String()
+}
+
+// Save writes the file readable by its owner only, since it may hold keys.
+func (f *File) Save(path string) error {
+	if err := EnsureDir(path); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, []byte(f.Render()), 0o600); err != nil {
+		return err
+	}
+	// WriteFile keeps the mode of an existing file.
+	return os.Chmod(path, 0o600)
+}
+
+// writeExtra writes unknown keys back as dotted keys, so that they stay in
+// the table they came from.
+func writeExtra(b *strings.Builder, prefix string, m map[string]any) {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if table, ok := m[key].(map[string]any); ok && len(table) > 0 {
+			writeExtra(b, prefix+tomlKey(key)+".", table)
+			continue
+		}
+		fmt.Fprintf(b, "%s%s = %s\n", prefix, tomlKey(key), formatValue(m[key]))
+	}
+}
+
+func formatValue(v any) string {
+	switch v := v.(type) {
+	case string:
+		return quote(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		switch {
+		case math.IsNaN(v):
+			return "nan"
+		case math.IsInf(v, 1):
+			return "inf"
+		case math.IsInf(v, -1):
+			return "-inf"
+		}
+		s := strconv.FormatFloat(v, 'g', -1, 64)
+		if !strings.ContainsAny(s, ".e") {
+			s += ".0"
+		}
+		return s
+	case bool:
+		return strconv.FormatBool(v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case []any:
+		parts := make([]string, len(v))
+		for i, item := range v {
+			parts[i] = formatValue(item)
+		}
+		return "[" + strings.Join(parts, ", ") + "]"
+	case []map[string]any:
+		parts := make([]string, len(v))
+		for i, item := range v {
+			parts[i] = formatValue(item)
+		}
+		return "[" + strings.Join(parts, ", ") + "]"
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		parts := make([]string, len(keys))
+		for i, key := range keys {
+			parts[i] = tomlKey(key) + " = " + formatValue(v[key])
+		}
+		if len(parts) == 0 {
+			return "{}"
+		}
+		return "{ " + strings.Join(parts, ", ") + " }"
+	}
+	return quote(fmt.Sprint(v))
+}
+
+var bareKey = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+func tomlKey(key string) string {
+	if bareKey.MatchString(key) {
+		return key
+	}
+	return quote(key)
+}
+
+// quote returns a TOML basic string.
+func quote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&b, `\u%04X`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
@@ -0,0 +1,140 @@
+package config
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func mustParse(t *testing.T, data string) Config {
+	t.Helper()
+	f, err := ParseFile([]byte(data))
+	if err != nil {
+		t.Fatalf("ParseFile error: %v\n%s", err, data)
+	}
+	return f.Config
+}
+
+const profilesTOML = `# team config
+default_profile = "local"
+model = "gpt-4o-mini"   # inline comment
+api_key = "sk-personal"
+editor.theme = "dark"
+future_flag = true
+ratio = 2.0
+
+[profiles.local]
+provider = "ollama"
+model = "llama3.1"
+num_ctx = 8192
+tags = ["a", "b"]
+
+[profiles."team gw"]
+base_url = "https://gw.example.com"
+headers."X-Team" = "infra"
+
+[profiles."team gw".extra]
+nested = 1
+`
+
+func TestParseFileProfiles(t *testing.T) {
+	f, err := ParseFile([]byte(profilesTOML))
+	if err != nil {
+		t.Fatalf("ParseFile error: %v", err)
+	}
+	if f.Config.Model != "gpt-4o-mini" || f.DefaultProfile != "local" {
+		t.Fatalf("inline comment leaked or default missing: %+v", f)
+	}
+	if got := f.ProfileNames(); len(got) != 2 || got[0] != "local" || got[1] != "team gw" {
+		t.Fatalf("unexpected profiles: %v", got)
+	}
+	cfg, err := f.Resolve("")
+	if err != nil {
+		t.Fatalf("Resolve error: %v", err)
+	}
+	if cfg.Provider != "ollama" || cfg.Model != "llama3.1" || cfg.NumCtx != 8192 || cfg.APIKey != "sk-personal" {
+		t.Fatalf("default profile not overlaid: %+v", cfg)
+	}
+	cfg, err = f.Resolve("team gw")
+	if err != nil {
+		t.Fatalf("Resolve error: %v", err)
+	}
+	if cfg.BaseURL != "https://gw.example.com" || cfg.Model != "gpt-4o-mini" || cfg.Headers["X-Team"] != "infra" {
+		t.Fatalf("unexpected team profile: %+v", cfg)
+	}
+	if _, err := f.Resolve("nope"); err == nil || !strings.Contains(err.Error(), "unknown profile: nope") {
+		t.Fatalf("expected unknown profile error, got %v", err)
+	}
+}
+
+func TestFileRenderKeepsUnknownKeys(t *testing.T) {
+	f, err := ParseFile([]byte(profilesTOML))
+	if err != nil {
+		t.Fatalf("ParseFile error: %v", err)
+	}
+	f.Config.Model = "gpt-4o"
+	f.SetProfile("new", Config{Model: "m"})
+	if !f.RemoveProfile("local") || f.DefaultProfile != "" {
+		t.Fatalf("RemoveProfile should clear default_profile: %+v", f)
+	}
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := f.Save(path); err != nil {
+		t.Fatalf("Save error: %v", err)
+	}
+	again, err := ReadFile(path)
+	if err != nil {
+		t.Fatalf("reparse error: %v\n%s", err, f.Render())
+	}
+	out := again.Render()
+	for _, want := range []string{
+		`editor.theme = "dark"`,
+		"future_flag = true",
+		"ratio = 2.0",
+		`[profiles."team gw"]`,
+		"extra.nested = 1",
+		"[profiles.new]",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("render missing %q:\n%s", want, out)
+		}
+	}
+	if again.Config.Model != "gpt-4o" || strings.Contains(out, "llama3.1") {
+		t.Fatalf("unexpected round trip:\n%s", out)
+	}
+}
+
+func TestParseFileErrors(t *testing.T) {
+	for _, data := range []string{
+		"model = \"unterminated\n",
+		"max_retries = \"many\"\n",
+		"[profiles.x]\nnum_ctx = -1\n",
+		"profiles = 1\n",
+	} {
+		if _, err := ParseFile([]byte(data)); err == nil {
+			t.Fatalf("expected error for %q", data)
+		}
+	}
+}
+
+func TestSaveKeepsProfiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	f := &File{}
+	f.SetProfile("work", Config{Model: "work-model"})
+	if err := f.Save(path); err != nil {
+		t.Fatalf("Save error: %v", err)
+	}
+	if err := Save(path, Config{Model: "top"}); err != nil {
+		t.Fatalf("Save error: %v", err)
+	}
+	cfg, err := LoadProfile(path, "work")
+	if err != nil {
+		t.Fatalf("LoadProfile error: %v", err)
+	}
+	if cfg.Model != "work-model" {
+		t.Fatalf("profile lost on Save: %+v", cfg)
+	}
+	t.Setenv("AIP_PROFILE", "missing")
+	if _, err := LoadMerged(path); err == nil {
+		t.Fatal("expected error for unknown AIP_PROFILE")
+	}
+}
@@ -0,0 +1,136 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// ResolveAPIKey fills cfg.APIKey from api_key_cmd or api_key_file when no key
+// is set directly. The command runs through the shell and its trimmed stdout
+// is the key.
+func ResolveAPIKey(ctx context.Context, cfg *Config) error {
+	if cfg.APIKey != "" {
+		return nil
+	}
+	switch {
+	case cfg.APIKeyCmd != "":
+		ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		defer cancel()
+		name, flag := "sh", "-c"
+		if runtime.GOOS == "windows" {
+			name, flag = "cmd", "/C"
+		}
+		cmd := exec.CommandContext(ctx, name, flag, cfg.APIKeyCmd)
+		cmd.Stderr = os.Stderr
+		// Children of the shell may keep stdout open after it is killed;
+		// stop waiting for them shortly after the deadline.
+		cmd.WaitDelay = time.Second
+		out, err := cmd.Output()
+		if err != nil {
+			return fmt.Errorf("api_key_cmd: %w", err)
+		}
+		cfg.APIKey = strings.TrimSpace(string(out))
+		if cfg.APIKey == "" {
+			return errors.New("api_key_cmd: empty output")
+		}
+	case cfg.APIKeyFile != "":
+		path, err := expandHome(cfg.APIKeyFile)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("api_key_file: %w", err)
+		}
+		cfg.APIKey = strings.TrimSpace(string(data))
+		if cfg.APIKey == "" {
+			return fmt.Errorf("api_key_file: %s is empty", path)
+		}
+	}
+	return nil
+}
+
+func expandHome(path string) (string, error) {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, strings.TrimPrefix(path, "~")), nil
+}
+
+// Insecure reports whether the file at path is readable by group or others.
+// Permission bits are not meaningful on Windows.
+func Insecure(path string) bool {
+	if runtime.GOOS == "windows" {
+		return false
+	}
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().Perm()&0o044 != 0
+}
+
+// IsSecretKey reports whether a config key holds a credential: api_key and
+// query or header entries whose name suggests a key, token or signature.
+func IsSecretKey(key string) bool {
+	if key == "api_key" {
+		return true
+	}
+	table, name, ok := splitMapKey(key)
+	if !ok || (table != "query" && table != "headers") {
+		return false
+	}
+	name = strings.ToLower(name)
+	for _, hint := range []string{"auth", "key", "token", "secret", "password", "cookie", "sig"} {
+		if strings.Contains(name, hint) {
+			return true
+		}
+	}
+	return false
+}
+
+// MaskSecret keeps just enough of a secret to tell keys apart.
+func MaskSecret(v string) string {
+	if len(v) <= 12 {
+		return strings.Repeat("*", len(v))
+	}
+	return v[:3] + strings.Repeat("*", 8) + v[len(v)-4:]
+}
+
+// MaskSecrets returns a copy of cfg with its secrets masked.
+func MaskSecrets(cfg Config) Config {
+	cfg.APIKey = MaskSecret(cfg.APIKey)
+	cfg.Query = maskMap("query", cfg.Query)
+	cfg.Headers = maskMap("headers", cfg.Headers)
+	return cfg
+}
+
+func maskMap(table string, m map[string]string) map[string]string {
+	if len(m) == 0 {
+		return m
+	}
+	out := make(map[string]string, len(m))
+	for name, v := range m {
+		if IsSecretKey(table + "." + quote(name)) {
+			v = MaskSecret(v)
+		}
+		out[name] = v
+	}
+	return out
+}
+
+// MaskSecrets masks the secrets of the top-level settings and every profile.
+func (f *File) MaskSecrets() {
+	f.Config = MaskSecrets(f.Config)
+	for _, p := range f.profiles {
+		p.cfg = MaskSecrets(p.cfg)
+	}
+}
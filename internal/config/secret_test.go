@@ -0,0 +1,83 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestResolveAPIKey(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	cfg := Config{APIKeyCmd: "printf 'sk-from-cmd\\n'"}
+	if err := ResolveAPIKey(context.Background(), &cfg); err != nil || cfg.APIKey != "sk-from-cmd" {
+		t.Fatalf("api_key_cmd: %q, %v", cfg.APIKey, err)
+	}
+
+	path := filepath.Join(t.TempDir(), "key")
+	if err := os.WriteFile(path, []byte("sk-from-file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg = Config{APIKeyFile: path}
+	if err := ResolveAPIKey(context.Background(), &cfg); err != nil || cfg.APIKey != "sk-from-file" {
+		t.Fatalf("api_key_file: %q, %v", cfg.APIKey, err)
+	}
+
+	cfg = Config{APIKey: "direct", APIKeyCmd: "exit 1"}
+	if err := ResolveAPIKey(context.Background(), &cfg); err != nil || cfg.APIKey != "direct" {
+		t.Fatalf("api_key should win: %q, %v", cfg.APIKey, err)
+	}
+	for _, cfg := range []Config{{APIKeyCmd: "exit 3"}, {APIKeyCmd: "true"}, {APIKeyFile: path + ".missing"}} {
+		if err := ResolveAPIKey(context.Background(), &cfg); err == nil {
+			t.Fatalf("expected error for %+v", cfg)
+		}
+	}
+}
+
+func TestMergeReplacesCredentialSource(t *testing.T) {
+	merged := Merge(Config{APIKey: "top"}, Config{APIKeyCmd: "pass show openai"})
+	if merged.APIKey != "" || merged.APIKeyCmd != "pass show openai" {
+		t.Fatalf("profile helper shadowed by top-level key: %+v", merged)
+	}
+}
+
+func TestSaveRestrictsPermissions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no permission bits")
+	}
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(path, []byte("model = \"m\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if !Insecure(path) {
+		t.Fatal("0644 file should be insecure")
+	}
+	if err := Save(path, Config{APIKey: "sk-1"}); err != nil {
+		t.Fatalf("Save error: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil || info.Mode().Perm() != 0o600 || Insecure(path) {
+		t.Fatalf("unexpected mode after Save: %v, %v", info.Mode(), err)
+	}
+}
+
+func TestMaskSecrets(t *testing.T) {
+	cfg := MaskSecrets(Config{
+		APIKey:  "sk-proj-1234567890abcd",
+		Headers: map[string]string{"X-Team": "infra", "X-Auth-Token": "tok-1234567890"},
+		Query:   map[string]string{"api-version": "2024-06-01", "sig": "abc"},
+	})
+	if cfg.APIKey != "sk-********abcd" || cfg.Headers["X-Team"] != "infra" || cfg.Query["api-version"] != "2024-06-01" {
+		t.Fatalf("unexpected masking: %+v", cfg)
+	}
+	if strings.Contains(cfg.Headers["X-Auth-Token"], "tok-") || cfg.Query["sig"] != "***" {
+		t.Fatalf("secret entries not masked: %+v", cfg)
+	}
+	if !IsSecretKey("api_key") || IsSecretKey("api_key_cmd") || !IsSecretKey(`headers."Authorization"`) {
+		t.Fatal("unexpected IsSecretKey result")
+	}
+}
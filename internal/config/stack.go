@@ -0,0 +1,281 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ProjectFile is the name of the per-repository config file.
+const ProjectFile = ".aip.toml"
+
+// Layer names, lowest precedence first.
+const (
+	LayerDefault = "default"
+	LayerUser    = "user"
+	LayerProject = "project"
+	LayerEnv     = "env"
+	LayerFlags   = "flags"
+)
+
+// Defaults are the built-in settings below every file; max_retries mirrors
+// llm.DefaultRetryPolicy.
+func Defaults() Config {
+	retries := 3
+	return Config{Provider: "openai", MaxRetries: &retries}
+}
+
+type Layer struct {
+	Name string
+	// Source says where the layer came from, e.g. a file path and profile.
+	Source string
+	// Dir is the directory of the layer's file, "" for other layers.
+	Dir    string
+	Config Config
+}
+
+// Stack is the layered configuration, lowest precedence first: defaults,
+// user file, project file, environment and flags.
+type Stack struct {
+	Layers []Layer
+	// Ignored lists the credential and endpoint keys dropped from the
+	// project file.
+	Ignored []IgnoredKey
+}
+
+// IgnoredKey is a key of the project file that was not applied.
+type IgnoredKey struct {
+	Source string
+	Key    string
+}
+
+// ProjectIgnoredKeys are never taken from a project .aip.toml: it comes with
+// the checked-out repository, and these keys run commands or decide where
+// the user's API key is sent.
+var ProjectIgnoredKeys = []string{"provider", "base_url", "path", "query", "auth_header", "auth_scheme", "api_key", "api_key_cmd", "api_key_file", "headers"}
+
+// FindProject returns the nearest .aip.toml in dir or one of its parents, or
+// "" when there is none.
+func FindProject(dir string) string {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	for {
+		path := filepath.Join(dir, ProjectFile)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// LoadStack layers the user file, the project file (if projectPath is not
+// empty) and the environment over the defaults. profile selects a profile
+// from either file; empty means the project's default_profile, then the
+// user's. Missing files are skipped.
+func LoadStack(userPath, projectPath, profile string) (Stack, error) {
+	user, err := readOptional(userPath)
+	if err != nil {
+		return Stack{}, err
+	}
+	project, err := readOptional(projectPath)
+	if err != nil {
+		return Stack{}, err
+	}
+	if profile == "" {
+		profile = user.DefaultProfile
+		if project.DefaultProfile != "" {
+			profile = project.DefaultProfile
+		}
+	}
+	if profile != "" {
+		_, inUser := user.Profile(profile)
+		_, inProject := project.Profile(profile)
+		if !inUser && !inProject {
+			return Stack{}, fmt.Errorf("unknown profile: %s", profile)
+		}
+	}
+
+	s := Stack{Layers: []Layer{{Name: LayerDefault, Config: Defaults()}}}
+	s.addFile(LayerUser, userPath, user, profile)
+	n := len(s.Layers)
+	s.addFile(LayerProject, projectPath, project, profile)
+	for i := n; i < len(s.Layers); i++ {
+		l := &s.Layers[i]
+		for _, key := range untrustedKeys(l.Config) {
+			s.Ignored = append(s.Ignored, IgnoredKey{Source: l.Source, Key: key})
+		}
+		l.Config = stripUntrusted(l.Config)
+	}
+	s.Add(LayerEnv, "AIP_*", envConfig())
+	return s, nil
+}
+
+func readOptional(path string) (*File, error) {
+	if path == "" {
+		return &File{}, nil
+	}
+	f, err := ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &File{}, nil
+	}
+	return f, err
+}
+
+func stripUntrusted(cfg Config) Config {
+	cfg.Provider = ""
+	cfg.BaseURL = ""
+	cfg.Path = ""
+	cfg.Query = nil
+	cfg.AuthHeader = ""
+	cfg.AuthScheme = ""
+	cfg.APIKey = ""
+	cfg.APIKeyCmd = ""
+	cfg.APIKeyFile = ""
+	cfg.Headers = nil
+	return cfg
+}
+
+func untrustedKeys(cfg Config) []string {
+	var keys []string
+	for _, key := range ProjectIgnoredKeys {
+		var set bool
+		switch key {
+		case "query":
+			set = len(cfg.Query) > 0
+		case "headers":
+			set = len(cfg.Headers) > 0
+		default:
+			_, set = GetByKey(cfg, key)
+		}
+		if set {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func (s *Stack) addFile(name, path string, f *File, profile string) {
+	n := len(s.Layers)
+	s.Add(name, path, f.Config)
+	if cfg, ok := f.Profile(profile); ok {
+		s.Add(name, path+" [profiles."+tomlKey(profile)+"]", cfg)
+	}
+	for i := n; i < len(s.Layers); i++ {
+		s.Layers[i].Dir = filepath.Dir(path)
+	}
+}
+
+func (s *Stack) Add(name, source string, cfg Config) {
+	s.Layers = append(s.Layers, Layer{Name: name, Source: source, Config: cfg})
+}
+
+// CommandLayer returns the layer that supplied the merged value of a command
+// key such as norm.rules.
+func (s Stack) CommandLayer(section, name string) (Layer, bool) {
+	for i := len(s.Layers) - 1; i >= 0; i-- {
+		if _, ok := s.Layers[i].Config.Commands[section][name]; ok {
+			return s.Layers[i], true
+		}
+	}
+	return Layer{}, false
+}
+
+func (s Stack) Merged() Config {
+	var cfg Config
+	for _, l := range s.Layers {
+		cfg = Merge(cfg, l.Config)
+	}
+	return cfg
+}
+
+// Origins maps each key of the merged config, as written by Render, to the
+// layer that supplied it.
+func (s Stack) Origins() map[string]Layer {
+	origins := map[string]Layer{}
+	for _, l := range s.Layers {
+		for _, key := range renderedKeys(renderTOML(l.Config)) {
+			origins[key] = l
+		}
+	}
+	return origins
+}
+
+// RenderOrigins renders cfg, normally s.Merged() or a masked copy of it,
+// with a comment naming the layer of every value.
+func (s Stack) RenderOrigins(cfg Config) string {
+	origins := s.Origins()
+	var b strings.Builder
+	for _, line := range strings.SplitAfter(renderTOML(cfg), "\n") {
+		if line == "" {
+			continue
+		}
+		l, ok := origins[lineKey(line)]
+		if !ok {
+			b.WriteString(line)
+			continue
+		}
+		origin := l.Name
+		if l.Source != "" {
+			origin += " " + l.Source
+		}
+		fmt.Fprintf(&b, "%s  # %s\n", strings.TrimSuffix(line, "\n"), origin)
+	}
+	return b.String()
+}
+
+// EnvOverride is a value from the environment that replaces one set in a
+// config file.
+type EnvOverride struct {
+	Key      string
+	Var      string
+	Replaced Layer
+}
+
+func (s Stack) EnvOverrides() []EnvOverride {
+	var out []EnvOverride
+	for _, env := range envVars {
+		name := EnvVar(env.key)
+		if name == "" {
+			continue
+		}
+		var replaced *Layer
+		for i, l := range s.Layers {
+			if l.Name != LayerUser && l.Name != LayerProject {
+				continue
+			}
+			if _, ok := GetByKey(l.Config, env.key); ok {
+				replaced = &s.Layers[i]
+			}
+		}
+		if replaced != nil {
+			out = append(out, EnvOverride{Key: env.key, Var: name, Replaced: *replaced})
+		}
+	}
+	return out
+}
+
+func renderedKeys(rendered string) []string {
+	var keys []string
+	for _, line := range strings.Split(rendered, "\n") {
+		if key := lineKey(line); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func lineKey(line string) string {
+	key, _, ok := strings.Cut(line, " = ")
+	if !ok {
+		return ""
+	}
+	return key
+}
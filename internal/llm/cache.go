@@ -0,0 +1,73 @@
+package llm
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+)
+
+type Cache interface {
+	Get(key string) ([]byte, bool)
+	Put(key string, data []byte) error
+}
+
+// cacheKey covers everything that shapes the answer: the endpoint, model,
+// messages, request parameters and any provider options outside the request,
+// such as Ollama's num_ctx. Streaming and non-streaming calls share entries.
+func cacheKey(baseURL string, req ChatRequest, options map[string]any) string {
+	req.Stream = false
+	payload, _ := json.Marshal(struct {
+		BaseURL string         `json:"base_url"`
+		Request ChatRequest    `json:"request"`
+		Options map[string]any `json:"options,omitempty"`
+	}{
+		BaseURL: strings.TrimRight(baseURL, "/"),
+		Request: req,
+		Options: options,
+	})
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:])
+}
+
+func cacheGet(c Cache, key string) (ChatResponse, bool) {
+	data, ok := c.Get(key)
+	if !ok {
+		return ChatResponse{}, false
+	}
+	var resp ChatResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return ChatResponse{}, false
+	}
+	return resp, true
+}
+
+func cachePut(c Cache, key string, resp ChatResponse) {
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return
+	}
+	_ = c.Put(key, data)
+}
+
+func completedResponse(model, content string) ChatResponse {
+	resp := ChatResponse{Model: model}
+	resp.Choices = append(resp.Choices, struct {
+		Message ChatMessage `json:"message"`
+	}{Message: ChatMessage{Role: "assistant", Content: content}})
+	return resp
+}
+
+// replay feeds a cached answer back line by line so streaming callers see the
+// same incremental output as a live response.
+func replay(content string, onDelta func(string) error) error {
+	for _, part := range strings.SplitAfter(content, "\n") {
+		if part == "" {
+			continue
+		}
+		if err := onDelta(part); err != nil {
+			return err
+		}
+	}
+	return nil
+}
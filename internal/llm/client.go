@@ -6,18 +6,34 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"strings"
+	"sync"
 	"time"
 )
 
+// Client talks to OpenAI-compatible /v1/chat/completions endpoints. Path,
+// Query and the auth settings cover gateways such as Azure OpenAI
+// (/openai/deployments/{model}/chat/completions?api-version=... with an
+// api-key header).
 type Client struct {
 	BaseURL string
 	APIKey  string
 	Model   string
-	Client  *http.Client
+	// Path replaces /v1/chat/completions; {model} expands to the request
+	// model.
+	Path  string
+	Query map[string]string
+	// AuthHeader defaults to Authorization with the Bearer scheme; a custom
+	// header carries the bare key unless AuthScheme is set.
+	AuthHeader string
+	AuthScheme string
+	Headers    map[string]string
+	Client     *http.Client
+	Cache      Cache
+	Retry      RetryPolicy
 }
 
 type ChatMessage struct {
@@ -29,6 +45,61 @@ type ChatRequest struct {
 	Model    string        `json:"model"`
 	Messages []ChatMessage `json:"messages"`
 	Stream   bool          `json:"stream,omitempty"`
+	Params
+	ResponseFormat *ResponseFormat `json:"response_format,omitempty"`
+	StreamOptions  *StreamOptions  `json:"stream_options,omitempty"`
+}
+
+// StreamOptions asks OpenAI-compatible servers to send a final chunk with the
+// token usage of a streamed reply.
+type StreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+// Params are the optional sampling parameters; unset fields are left to the
+// provider's defaults.
+type Params struct {
+	Temperature *float64 `json:"temperature,omitempty"`
+	MaxTokens   int      `json:"max_tokens,omitempty"`
+	TopP        *float64 `json:"top_p,omitempty"`
+	Seed        *int64   `json:"seed,omitempty"`
+	Stop        []string `json:"stop,omitempty"`
+}
+
+// Merge fills the fields that are unset in p from defaults.
+func (p Params) Merge(defaults Params) Params {
+	if p.Temperature == nil {
+		p.Temperature = defaults.Temperature
+	}
+	if p.MaxTokens == 0 {
+		p.MaxTokens = defaults.MaxTokens
+	}
+	if p.TopP == nil {
+		p.TopP = defaults.TopP
+	}
+	if p.Seed == nil {
+		p.Seed = defaults.Seed
+	}
+	if len(p.Stop) == 0 {
+		p.Stop = defaults.Stop
+	}
+	return p
+}
+
+type ResponseFormat struct {
+	Type       string      `json:"type"`
+	JSONSchema *JSONSchema `json:"json_schema,omitempty"`
+}
+
+type JSONSchema struct {
+	Name   string          `json:"name"`
+	Schema json.RawMessage `json:"schema"`
+	Strict bool            `json:"strict,omitempty"`
+}
+
+// SchemaFormat builds a json_schema response format.
+func SchemaFormat(name string, schema json.RawMessage) *ResponseFormat {
+	return &ResponseFormat{Type: "json_schema", JSONSchema: &JSONSchema{Name: name, Schema: schema}}
 }
 
 type ChatResponse struct {
@@ -38,6 +109,10 @@ type ChatResponse struct {
 	Choices []struct {
 		Message ChatMessage `json:"message"`
 	} `json:"choices"`
+	// Cached is set when the response came from the response cache.
+	Cached bool `json:"-"`
+	// Metrics is filled by a Meter.
+	Metrics Metrics `json:"-"`
 }
 
 type Usage struct {
@@ -46,83 +121,91 @@ type Usage struct {
 	TotalTokens      int `json:"total_tokens"`
 }
 
+func (u *Usage) Add(other Usage) {
+	u.PromptTokens += other.PromptTokens
+	u.CompletionTokens += other.CompletionTokens
+	u.TotalTokens += other.TotalTokens
+}
+
 func (c Client) Complete(ctx context.Context, req ChatRequest) (ChatResponse, error) {
+	if req.Model == "" {
+		req.Model = c.Model
+	}
 	if c.BaseURL == "" || c.APIKey == "" || req.Model == "" {
 		return ChatResponse{}, errors.New("missing base URL, API key, or model")
 	}
-	base := strings.TrimRight(c.BaseURL, "/")
-	url := base + "/v1/chat/completions"
-
 	body, err := json.Marshal(req)
 	if err != nil {
 		return ChatResponse{}, err
 	}
-	httpClient := c.Client
-	if httpClient == nil {
-		httpClient = &http.Client{Timeout: 60 * time.Second}
-	}
-	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
-	if err != nil {
-		return ChatResponse{}, err
-	}
-	httpReq.Header.Set("Content-Type", "application/json")
-	httpReq.Header.Set("Authorization", "Bearer "+c.APIKey)
-
-	resp, err := httpClient.Do(httpReq)
-	if err != nil {
-		return ChatResponse{}, err
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		msg, _ := io.ReadAll(resp.Body)
-		return ChatResponse{}, fmt.Errorf("llm error: %s", strings.TrimSpace(string(msg)))
-	}
-	var out ChatResponse
-	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
-		return ChatResponse{}, err
-	}
-	return out, nil
+	endpoint := c.endpoint(req.Model)
+	return complete(ctx, c.Cache, cacheKey(endpoint, req, nil), req, c.Retry, func() (ChatResponse, error) {
+		resp, err := c.post(ctx, endpoint, body)
+		if err != nil {
+			return ChatResponse{}, err
+		}
+		defer resp.Body.Close()
+		var out ChatResponse
+		err = json.NewDecoder(resp.Body).Decode(&out)
+		return out, err
+	})
 }
 
+// streamUsageRejected holds the endpoints that answered stream_options with
+// 400 and streamed fine without it.
+var streamUsageRejected sync.Map
+
 type chatStreamResponse struct {
 	Choices []struct {
 		Delta ChatMessage `json:"delta"`
 	} `json:"choices"`
+	Usage *Usage        `json:"usage"`
+	Error *apiErrorBody `json:"error"`
 }
 
-func (c Client) StreamComplete(ctx context.Context, req ChatRequest, onDelta func(string) error) error {
+func (c Client) StreamComplete(ctx context.Context, req ChatRequest, onDelta func(string) error) (ChatResponse, error) {
+	if req.Model == "" {
+		req.Model = c.Model
+	}
 	if c.BaseURL == "" || c.APIKey == "" || req.Model == "" {
-		return errors.New("missing base URL, API key, or model")
+		return ChatResponse{}, errors.New("missing base URL, API key, or model")
 	}
-	base := strings.TrimRight(c.BaseURL, "/")
-	url := base + "/v1/chat/completions"
 	req.Stream = true
-
-	body, err := json.Marshal(req)
-	if err != nil {
-		return err
-	}
-	httpClient := c.Client
-	if httpClient == nil {
-		httpClient = &http.Client{Timeout: 60 * time.Second}
-	}
-	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
-	if err != nil {
-		return err
+	endpoint := c.endpoint(req.Model)
+	wire := req
+	if _, rejected := streamUsageRejected.Load(endpoint); !rejected {
+		wire.StreamOptions = &StreamOptions{IncludeUsage: true}
 	}
-	httpReq.Header.Set("Content-Type", "application/json")
-	httpReq.Header.Set("Authorization", "Bearer "+c.APIKey)
-
-	resp, err := httpClient.Do(httpReq)
+	body, err := json.Marshal(wire)
 	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		msg, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("llm error: %s", strings.TrimSpace(string(msg)))
+		return ChatResponse{}, err
 	}
+	return stream(ctx, c.Cache, cacheKey(endpoint, req, nil), req, c.Retry, onDelta, func(onDelta func(string) error) (Usage, bool, error) {
+		resp, err := c.post(ctx, endpoint, body)
+		var apiErr *APIError
+		if wire.StreamOptions != nil && errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusBadRequest {
+			// Older OpenAI-compatible servers reject unknown fields; stream
+			// without usage and remember the endpoint if that works.
+			plain := wire
+			plain.StreamOptions = nil
+			if body, merr := json.Marshal(plain); merr == nil {
+				if resp, err = c.post(ctx, endpoint, body); err == nil {
+					streamUsageRejected.Store(endpoint, true)
+				}
+			}
+		}
+		if err != nil {
+			return Usage{}, false, err
+		}
+		defer resp.Body.Close()
+		return readChatStream(resp, onDelta)
+	})
+}
 
+// readChatStream forwards content deltas and returns the usage of the final
+// chunk, which servers send when stream_options.include_usage is honored.
+// done reports whether the stream ended with [DONE] rather than being cut off.
+func readChatStream(resp *http.Response, onDelta func(string) error) (usage Usage, done bool, err error) {
 	scanner := bufio.NewScanner(resp.Body)
 	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
 	for scanner.Scan() {
@@ -132,11 +215,17 @@ func (c Client) StreamComplete(ctx context.Context, req ChatRequest, onDelta fun
 		}
 		payload := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
 		if payload == "[DONE]" {
-			return nil
+			return usage, true, nil
 		}
 		var chunk chatStreamResponse
 		if err := json.Unmarshal([]byte(payload), &chunk); err != nil {
-			return err
+			return Usage{}, false, err
+		}
+		if chunk.Error != nil {
+			return Usage{}, false, chunk.Error.apiError(resp)
+		}
+		if chunk.Usage != nil {
+			usage = *chunk.Usage
 		}
 		if len(chunk.Choices) == 0 {
 			continue
@@ -146,11 +235,159 @@ func (c Client) StreamComplete(ctx context.Context, req ChatRequest, onDelta fun
 			continue
 		}
 		if err := onDelta(delta); err != nil {
-			return err
+			return Usage{}, false, err
+		}
+	}
+	return usage, false, scanner.Err()
+}
+
+func (c Client) endpoint(model string) string {
+	path := c.Path
+	if path == "" {
+		path = "/v1/chat/completions"
+	}
+	path = strings.ReplaceAll(path, "{model}", url.PathEscape(model))
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return c.withQuery(strings.TrimRight(c.BaseURL, "/") + path)
+}
+
+func (c Client) withQuery(endpoint string) string {
+	if len(c.Query) > 0 {
+		query := url.Values{}
+		for key, val := range c.Query {
+			query.Set(key, val)
+		}
+		endpoint += "?" + query.Encode()
+	}
+	return endpoint
+}
+
+func (c Client) post(ctx context.Context, endpoint string, body []byte) (*http.Response, error) {
+	return post(ctx, c.Client, endpoint, c.header(), body)
+}
+
+func (c Client) header() http.Header {
+	header := extraHeader(c.Headers)
+	name, scheme := c.AuthHeader, c.AuthScheme
+	if name == "" {
+		name = "Authorization"
+		if scheme == "" {
+			scheme = "Bearer"
 		}
 	}
-	if err := scanner.Err(); err != nil {
+	if scheme != "" {
+		header.Set(name, scheme+" "+c.APIKey)
+	} else {
+		header.Set(name, c.APIKey)
+	}
+	return header
+}
+
+// extraHeader starts a request header with the configured custom headers;
+// every provider sends them and then sets its own auth headers.
+func extraHeader(headers map[string]string) http.Header {
+	header := http.Header{}
+	for key, val := range headers {
+		header.Set(key, val)
+	}
+	return header
+}
+
+// complete wraps one non-streaming attempt with the response cache and the
+// retry policy; every HTTP provider shares it.
+func complete(ctx context.Context, cache Cache, key string, req ChatRequest, retry RetryPolicy, attempt func() (ChatResponse, error)) (ChatResponse, error) {
+	if cache != nil {
+		if resp, ok := cacheGet(cache, key); ok {
+			resp.Cached = true
+			return resp, nil
+		}
+	}
+	var out ChatResponse
+	err := retry.Do(ctx, func() error {
+		var err error
+		out, err = attempt()
 		return err
+	})
+	if err != nil {
+		return ChatResponse{}, err
+	}
+	if cache != nil && len(out.Choices) > 0 {
+		cachePut(cache, key, out)
+	}
+	return out, nil
+}
+
+// stream is the streaming counterpart of complete: cached answers are
+// replayed, and once a delta reached the caller the attempt cannot be retried.
+// The returned response carries the full content and the reported usage; it
+// is cached only when the attempt saw the provider's end-of-stream marker, so
+// a cut-off reply is never replayed as a complete one.
+func stream(ctx context.Context, cache Cache, key string, req ChatRequest, retry RetryPolicy, onDelta func(string) error, attempt func(onDelta func(string) error) (Usage, bool, error)) (ChatResponse, error) {
+	if cache != nil {
+		if resp, ok := cacheGet(cache, key); ok && len(resp.Choices) > 0 {
+			resp.Cached = true
+			return resp, replay(resp.Choices[0].Message.Content, onDelta)
+		}
+	}
+	var (
+		content strings.Builder
+		usage   Usage
+		done    bool
+	)
+	err := retry.Do(ctx, func() error {
+		var err error
+		usage, done, err = attempt(func(delta string) error {
+			content.WriteString(delta)
+			return onDelta(delta)
+		})
+		if err != nil && content.Len() > 0 {
+			return Permanent(err)
+		}
+		return err
+	})
+	if err != nil {
+		return ChatResponse{}, err
+	}
+	resp := completedResponse(req.Model, content.String())
+	resp.Usage = usage
+	if cache != nil && done && content.Len() > 0 {
+		cachePut(cache, key, resp)
+	}
+	return resp, nil
+}
+
+func post(ctx context.Context, httpClient *http.Client, url string, header http.Header, body []byte) (*http.Response, error) {
+	return send(ctx, httpClient, http.MethodPost, url, header, body)
+}
+
+func send(ctx context.Context, httpClient *http.Client, method, url string, header http.Header, body []byte) (*http.Response, error) {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 60 * time.Second}
+	}
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return nil, Permanent(err)
+	}
+	for key, values := range header {
+		httpReq.Header[key] = values
+	}
+	if body != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := httpClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		return nil, newAPIError(resp)
 	}
-	return nil
+	return resp, nil
 }
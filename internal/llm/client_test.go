@@ -79,7 +79,7 @@ func TestStreamComplete(t *testing.T) {
 		APIKey:  "test",
 		Model:   "test-model",
 	}
-	err := client.StreamComplete(context.Background(), ChatRequest{
+	_, err := client.StreamComplete(context.Background(), ChatRequest{
 		Model: client.Model,
 		Messages: []ChatMessage{
 			{Role: "user", Content: "hi"},
@@ -95,3 +95,198 @@ func TestStreamComplete(t *testing.T) {
 		t.Fatalf("unexpected output: %q", out.String())
 	}
 }
+
+type memCache map[string][]byte
+
+func (m memCache) Get(key string) ([]byte, bool) {
+	data, ok := m[key]
+	return data, ok
+}
+
+func (m memCache) Put(key string, data []byte) error {
+	m[key] = data
+	return nil
+}
+
+func TestCompleteUsesCache(t *testing.T) {
+	hits := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		resp := ChatResponse{
+			Model: "test-model",
+			Choices: []struct {
+				Message ChatMessage `json:"message"`
+			}{
+				{Message: ChatMessage{Role: "assistant", Content: "line one\nline two"}},
+			},
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(server.Close)
+
+	client := Client{
+		BaseURL: server.URL,
+		APIKey:  "test",
+		Model:   "test-model",
+		Cache:   memCache{},
+	}
+	req := ChatRequest{
+		Model:    client.Model,
+		Messages: []ChatMessage{{Role: "user", Content: "hi"}},
+	}
+	for i := 0; i < 2; i++ {
+		resp, err := client.Complete(context.Background(), req)
+		if err != nil {
+			t.Fatalf("Complete error: %v", err)
+		}
+		if resp.Choices[0].Message.Content != "line one\nline two" {
+			t.Fatalf("unexpected response: %+v", resp)
+		}
+	}
+	if hits != 1 {
+		t.Fatalf("expected 1 upstream call, got %d", hits)
+	}
+
+	var deltas []string
+	_, err := client.StreamComplete(context.Background(), req, func(delta string) error {
+		deltas = append(deltas, delta)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("StreamComplete error: %v", err)
+	}
+	if hits != 1 || len(deltas) != 2 || deltas[0] != "line one\n" {
+		t.Fatalf("expected cached replay, hits=%d deltas=%q", hits, deltas)
+	}
+}
+
+func TestStreamCacheNeedsDone(t *testing.T) {
+	done := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte("data: {\"choices\":[{\"delta\":{\"content\":\"partial\"}}]}\n\n"))
+		if done {
+			_, _ = w.Write([]byte("data: [DONE]\n\n"))
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	cache := memCache{}
+	client := Client{BaseURL: server.URL, APIKey: "test", Model: "m", Cache: cache}
+	req := ChatRequest{Messages: []ChatMessage{{Role: "user", Content: "hi"}}}
+	if _, err := client.StreamComplete(context.Background(), req, func(string) error { return nil }); err != nil {
+		t.Fatalf("StreamComplete error: %v", err)
+	}
+	if len(cache) != 0 {
+		t.Fatalf("cut-off stream was cached: %d entries", len(cache))
+	}
+	done = true
+	if _, err := client.StreamComplete(context.Background(), req, func(string) error { return nil }); err != nil {
+		t.Fatalf("StreamComplete error: %v", err)
+	}
+	if len(cache) != 1 {
+		t.Fatalf("complete stream was not cached: %d entries", len(cache))
+	}
+}
+
+func TestClientDeploymentPathAndAuthHeader(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/openai/deployments/gpt4o/chat/completions" || r.URL.Query().Get("api-version") != "2024-06-01" {
+			t.Errorf("unexpected url %s", r.URL)
+		}
+		if r.Header.Get("api-key") != "secret" || r.Header.Get("Authorization") != "" || r.Header.Get("X-Team") != "infra" {
+			t.Errorf("unexpected headers %v", r.Header)
+		}
+		_ = json.NewEncoder(w).Encode(completedResponse("gpt4o", "ok"))
+	}))
+	t.Cleanup(server.Close)
+
+	client := Client{
+		BaseURL:    server.URL,
+		APIKey:     "secret",
+		Model:      "gpt4o",
+		Path:       "/openai/deployments/{model}/chat/completions",
+		Query:      map[string]string{"api-version": "2024-06-01"},
+		AuthHeader: "api-key",
+		Headers:    map[string]string{"X-Team": "infra"},
+	}
+	resp, err := client.Complete(context.Background(), ChatRequest{})
+	if err != nil {
+		t.Fatalf("Complete error: %v", err)
+	}
+	if resp.Choices[0].Message.Content != "ok" {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+}
+
+func TestClientAuthScheme(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Auth") != "Token secret" {
+			t.Errorf("unexpected auth header %q", r.Header.Get("X-Auth"))
+		}
+		_ = json.NewEncoder(w).Encode(completedResponse("m", "ok"))
+	}))
+	t.Cleanup(server.Close)
+
+	client := Client{BaseURL: server.URL, APIKey: "secret", Model: "m", AuthHeader: "X-Auth", AuthScheme: "Token"}
+	if _, err := client.Complete(context.Background(), ChatRequest{}); err != nil {
+		t.Fatalf("Complete error: %v", err)
+	}
+}
+
+func TestStreamCompleteUsage(t *testing.T) {
+	var got map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte("data: {\"model\":\"gpt-4o-mini\",\"choices\":[{\"delta\":{\"content\":\"ok\"}}]}\n\n"))
+		_, _ = w.Write([]byte("data: {\"choices\":[],\"usage\":{\"prompt_tokens\":10,\"completion_tokens\":4,\"total_tokens\":14}}\n\n"))
+		_, _ = w.Write([]byte("data: [DONE]\n\n"))
+	}))
+	t.Cleanup(server.Close)
+
+	client := Client{BaseURL: server.URL, APIKey: "test", Model: "gpt-4o-mini"}
+	resp, err := client.StreamComplete(context.Background(), ChatRequest{
+		Messages: []ChatMessage{{Role: "user", Content: "hi"}},
+	}, func(string) error { return nil })
+	if err != nil {
+		t.Fatalf("StreamComplete error: %v", err)
+	}
+	if opts, _ := got["stream_options"].(map[string]any); opts["include_usage"] != true {
+		t.Fatalf("include_usage not requested: %v", got)
+	}
+	if resp.Usage.PromptTokens != 10 || resp.Usage.CompletionTokens != 4 || resp.Usage.TotalTokens != 14 {
+		t.Fatalf("unexpected usage: %+v", resp.Usage)
+	}
+}
+
+func TestStreamCompleteWithoutStreamOptions(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		var got map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		if _, ok := got["stream_options"]; ok {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":{"message":"extra fields not permitted"}}`))
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte("data: {\"choices\":[{\"delta\":{\"content\":\"ok\"}}]}\n\ndata: [DONE]\n\n"))
+	}))
+	t.Cleanup(server.Close)
+
+	client := Client{BaseURL: server.URL, APIKey: "test", Model: "m"}
+	for i := 0; i < 2; i++ {
+		resp, err := client.StreamComplete(context.Background(), ChatRequest{}, func(string) error { return nil })
+		if err != nil {
+			t.Fatalf("StreamComplete error: %v", err)
+		}
+		if resp.Choices[0].Message.Content != "ok" {
+			t.Fatalf("unexpected response: %+v", resp)
+		}
+	}
+	if calls != 3 {
+		t.Fatalf("expected the rejection to be remembered, got %d calls", calls)
+	}
+}
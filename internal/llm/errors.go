@@ -0,0 +1,134 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type APIError struct {
+	StatusCode int
+	Type       string
+	Code       string
+	Message    string
+	RequestID  string
+	RetryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "llm error: status %d", e.StatusCode)
+	if e.Type != "" {
+		fmt.Fprintf(&b, " (%s)", e.Type)
+	}
+	if e.Message != "" {
+		fmt.Fprintf(&b, ": %s", e.Message)
+	}
+	if e.RequestID != "" {
+		fmt.Fprintf(&b, " [request_id=%s]", e.RequestID)
+	}
+	return b.String()
+}
+
+func (e *APIError) Retryable() bool {
+	switch e.StatusCode {
+	case http.StatusRequestTimeout, http.StatusConflict, http.StatusTooManyRequests,
+		http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout, 529:
+		return true
+	}
+	return false
+}
+
+func (e *APIError) IsAuth() bool {
+	return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+}
+
+func (e *APIError) IsRateLimit() bool {
+	return e.StatusCode == http.StatusTooManyRequests
+}
+
+// apiErrorBody covers the OpenAI-style {"error": {...}} envelope; code may be
+// a string or a number depending on the provider.
+type apiErrorBody struct {
+	Message string          `json:"message"`
+	Type    string          `json:"type"`
+	Code    json.RawMessage `json:"code"`
+}
+
+func (b *apiErrorBody) apiError(resp *http.Response) *APIError {
+	e := &APIError{
+		StatusCode: resp.StatusCode,
+		Type:       b.Type,
+		Message:    b.Message,
+		RequestID:  requestID(resp.Header),
+	}
+	if len(b.Code) > 0 && string(b.Code) != "null" {
+		var code string
+		if err := json.Unmarshal(b.Code, &code); err != nil {
+			code = string(b.Code)
+		}
+		e.Code = code
+	}
+	return e
+}
+
+func newAPIError(resp *http.Response) *APIError {
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	var envelope struct {
+		Error json.RawMessage `json:"error"`
+	}
+	e := &APIError{StatusCode: resp.StatusCode}
+	if err := json.Unmarshal(raw, &envelope); err == nil && len(envelope.Error) > 0 {
+		var body apiErrorBody
+		if err := json.Unmarshal(envelope.Error, &body); err == nil {
+			e = body.apiError(resp)
+		} else {
+			var msg string
+			_ = json.Unmarshal(envelope.Error, &msg)
+			e.Message = msg
+		}
+	}
+	if e.Message == "" {
+		e.Message = strings.TrimSpace(string(raw))
+	}
+	e.RequestID = requestID(resp.Header)
+	e.RetryAfter = parseRetryAfter(resp.Header, time.Now())
+	return e
+}
+
+func requestID(h http.Header) string {
+	for _, key := range []string{"X-Request-Id", "Request-Id", "Apim-Request-Id", "X-Ms-Request-Id"} {
+		if v := h.Get(key); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func parseRetryAfter(h http.Header, now time.Time) time.Duration {
+	if v := h.Get("Retry-After-Ms"); v != "" {
+		var ms float64
+		if _, err := fmt.Sscanf(v, "%g", &ms); err == nil && ms > 0 {
+			return time.Duration(ms * float64(time.Millisecond))
+		}
+	}
+	v := strings.TrimSpace(h.Get("Retry-After"))
+	if v == "" {
+		return 0
+	}
+	var secs float64
+	if _, err := fmt.Sscanf(v, "%g", &secs); err == nil {
+		if secs <= 0 {
+			return 0
+		}
+		return time.Duration(secs * float64(time.Second))
+	}
+	if at, err := http.ParseTime(v); err == nil && at.After(now) {
+		return at.Sub(now)
+	}
+	return 0
+}
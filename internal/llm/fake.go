@@ -0,0 +1,56 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/yjhatfdu/aip/internal/tokens"
+)
+
+const ProviderFake = "fake"
+
+// Fake is a deterministic offline provider for tests and dry runs. It answers
+// with Reply, or with a short description of the last user message.
+type Fake struct {
+	Model string
+	Reply string
+}
+
+func (f Fake) Complete(ctx context.Context, req ChatRequest) (ChatResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return ChatResponse{}, err
+	}
+	model := req.Model
+	if model == "" {
+		model = f.Model
+	}
+	if model == "" {
+		model = ProviderFake
+	}
+	reply := f.Reply
+	prompt := 0
+	last := ""
+	for _, msg := range req.Messages {
+		prompt += tokens.Estimate(msg.Content)
+		if msg.Role == "user" {
+			last = msg.Content
+		}
+	}
+	if reply == "" {
+		first, _, _ := strings.Cut(strings.TrimSpace(last), "\n")
+		reply = fmt.Sprintf("fake response to %d lines: %s", strings.Count(last, "\n")+1, first)
+	}
+	resp := completedResponse(model, reply)
+	completion := tokens.Estimate(reply)
+	resp.Usage = Usage{PromptTokens: prompt, CompletionTokens: completion, TotalTokens: prompt + completion}
+	return resp, nil
+}
+
+func (f Fake) StreamComplete(ctx context.Context, req ChatRequest, onDelta func(string) error) (ChatResponse, error) {
+	resp, err := f.Complete(ctx, req)
+	if err != nil {
+		return ChatResponse{}, err
+	}
+	return resp, replay(resp.Choices[0].Message.Content, onDelta)
+}
@@ -0,0 +1,124 @@
+package llm
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Metrics describes one call, or the total of all calls seen by a Meter.
+type Metrics struct {
+	Latency time.Duration
+	// TTFT is the time to the first streamed delta; for non-streaming calls
+	// it equals Latency.
+	TTFT time.Duration
+	// CostUSD is nil when the model has no known price.
+	CostUSD *float64
+}
+
+type MeterTotals struct {
+	Calls  int
+	Cached int
+	Usage  Usage
+	Metrics
+}
+
+// Meter wraps a provider and records latency, time to first token, usage and
+// cost of every call. Cached responses cost nothing.
+type Meter struct {
+	Provider
+	Prices map[string]Price
+	// Model is the configured model, priced when neither the response nor
+	// the request names a priced model.
+	Model string
+
+	mu     sync.Mutex
+	totals MeterTotals
+}
+
+func NewMeter(p Provider, prices map[string]Price) *Meter {
+	return &Meter{Provider: p, Prices: prices}
+}
+
+func (m *Meter) Complete(ctx context.Context, req ChatRequest) (ChatResponse, error) {
+	start := time.Now()
+	resp, err := m.Provider.Complete(ctx, req)
+	if err != nil {
+		return resp, err
+	}
+	latency := time.Since(start)
+	m.record(&resp, req.Model, latency, latency)
+	return resp, nil
+}
+
+func (m *Meter) StreamComplete(ctx context.Context, req ChatRequest, onDelta func(string) error) (ChatResponse, error) {
+	start := time.Now()
+	var ttft time.Duration
+	resp, err := m.Provider.StreamComplete(ctx, req, func(delta string) error {
+		if ttft == 0 {
+			ttft = time.Since(start)
+		}
+		return onDelta(delta)
+	})
+	if err != nil {
+		return resp, err
+	}
+	latency := time.Since(start)
+	if ttft == 0 {
+		ttft = latency
+	}
+	m.record(&resp, req.Model, latency, ttft)
+	return resp, nil
+}
+
+// record fills in the metrics of resp. The price is looked up by the model the
+// provider reported, then by the requested or configured one, which may be a
+// deployment or alias name the gateway reports differently.
+func (m *Meter) record(resp *ChatResponse, requested string, latency, ttft time.Duration) {
+	resp.Metrics = Metrics{Latency: latency, TTFT: ttft}
+	cost, priced := 0.0, resp.Cached
+	if !resp.Cached {
+		if requested == "" {
+			requested = m.Model
+		}
+		var price Price
+		price, priced = LookupPrice(resp.Model, m.Prices)
+		if !priced && requested != "" {
+			price, priced = LookupPrice(requested, m.Prices)
+		}
+		if priced {
+			cost = price.Cost(resp.Usage)
+		}
+	}
+	if priced {
+		resp.Metrics.CostUSD = &cost
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t := &m.totals
+	if t.Calls == 0 {
+		t.TTFT = ttft
+	}
+	t.Calls++
+	if resp.Cached {
+		t.Cached++
+	}
+	t.Usage.Add(resp.Usage)
+	t.Latency += latency
+	if priced {
+		total := cost
+		if t.CostUSD != nil {
+			total += *t.CostUSD
+		}
+		t.CostUSD = &total
+	}
+}
+
+// Totals sums all recorded calls; Latency is the summed call time and TTFT
+// that of the first call.
+func (m *Meter) Totals() MeterTotals {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.totals
+}
@@ -0,0 +1,97 @@
+package llm
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+func TestLookupPrice(t *testing.T) {
+	if p, ok := LookupPrice("gpt-4o-mini-2024-07-18", nil); !ok || p.Input != 0.15 {
+		t.Fatalf("expected gpt-4o-mini price, got %+v %v", p, ok)
+	}
+	if p, ok := LookupPrice("openai/gpt-4o", nil); !ok || p.Input != 2.50 {
+		t.Fatalf("expected gpt-4o price, got %+v %v", p, ok)
+	}
+	overrides := map[string]Price{"gpt-4o": {Input: 1, Output: 2}}
+	if p, _ := LookupPrice("gpt-4o-2024-08-06", overrides); p.Input != 1 {
+		t.Fatalf("override should win, got %+v", p)
+	}
+	if p, _ := LookupPrice("gpt-4o-mini", overrides); p.Input != 0.15 {
+		t.Fatalf("shorter override should not shadow gpt-4o-mini, got %+v", p)
+	}
+	if _, ok := LookupPrice("my-local-model", nil); ok {
+		t.Fatal("unknown model should have no price")
+	}
+}
+
+func TestMeterRecordsCostAndTTFT(t *testing.T) {
+	m := NewMeter(Fake{Model: "gpt-4o-mini"}, nil)
+	req := ChatRequest{Messages: []ChatMessage{{Role: "user", Content: "hello world"}}}
+	resp, err := m.StreamComplete(context.Background(), req, func(string) error { return nil })
+	if err != nil {
+		t.Fatalf("StreamComplete error: %v", err)
+	}
+	if resp.Metrics.CostUSD == nil || resp.Metrics.TTFT <= 0 || resp.Metrics.TTFT > resp.Metrics.Latency {
+		t.Fatalf("unexpected metrics: %+v", resp.Metrics)
+	}
+	want := DefaultPrices["gpt-4o-mini"].Cost(resp.Usage)
+	if math.Abs(*resp.Metrics.CostUSD-want) > 1e-12 {
+		t.Fatalf("cost %v, want %v", *resp.Metrics.CostUSD, want)
+	}
+	if _, err := m.Complete(context.Background(), req); err != nil {
+		t.Fatalf("Complete error: %v", err)
+	}
+	totals := m.Totals()
+	if totals.Calls != 2 || totals.TTFT != resp.Metrics.TTFT || math.Abs(*totals.CostUSD-2*want) > 1e-12 {
+		t.Fatalf("unexpected totals: %+v", totals)
+	}
+}
+
+func TestMeterUnknownPriceAndCache(t *testing.T) {
+	m := NewMeter(cachedProvider{Fake{Model: "local"}}, nil)
+	resp, err := m.Complete(context.Background(), ChatRequest{})
+	if err != nil {
+		t.Fatalf("Complete error: %v", err)
+	}
+	if resp.Metrics.CostUSD == nil || *resp.Metrics.CostUSD != 0 || m.Totals().Cached != 1 {
+		t.Fatalf("cached call should cost 0: %+v %+v", resp.Metrics, m.Totals())
+	}
+	m = NewMeter(Fake{Model: "local"}, nil)
+	if resp, _ = m.Complete(context.Background(), ChatRequest{}); resp.Metrics.CostUSD != nil {
+		t.Fatalf("unpriced model should have nil cost, got %v", *resp.Metrics.CostUSD)
+	}
+}
+
+func TestMeterPricesRequestedModel(t *testing.T) {
+	prices := map[string]Price{"my-deployment": {Input: 1, Output: 1}}
+	m := NewMeter(renamedProvider{Fake{}}, prices)
+	resp, err := m.Complete(context.Background(), ChatRequest{Model: "my-deployment"})
+	if err != nil {
+		t.Fatalf("Complete error: %v", err)
+	}
+	if resp.Model != "served-model" || resp.Metrics.CostUSD == nil {
+		t.Fatalf("requested model price should apply: %q %+v", resp.Model, resp.Metrics)
+	}
+	m = NewMeter(renamedProvider{Fake{}}, prices)
+	m.Model = "my-deployment"
+	if resp, _ = m.Complete(context.Background(), ChatRequest{}); resp.Metrics.CostUSD == nil {
+		t.Fatalf("configured model price should apply: %+v", resp.Metrics)
+	}
+}
+
+type renamedProvider struct{ Fake }
+
+func (p renamedProvider) Complete(ctx context.Context, req ChatRequest) (ChatResponse, error) {
+	resp, err := p.Fake.Complete(ctx, req)
+	resp.Model = "served-model"
+	return resp, err
+}
+
+type cachedProvider struct{ Fake }
+
+func (p cachedProvider) Complete(ctx context.Context, req ChatRequest) (ChatResponse, error) {
+	resp, err := p.Fake.Complete(ctx, req)
+	resp.Cached = true
+	return resp, err
+}
@@ -0,0 +1,107 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// ErrModelsUnsupported is returned by ListModels when the endpoint has no
+// model listing, e.g. Azure deployment paths.
+var ErrModelsUnsupported = errors.New("model listing not supported by this endpoint")
+
+// ModelLister is implemented by providers that can list the models available
+// to the configured key.
+type ModelLister interface {
+	ListModels(ctx context.Context) ([]string, error)
+}
+
+type modelList struct {
+	Data []struct {
+		ID string `json:"id"`
+	} `json:"data"`
+}
+
+func (l modelList) ids() []string {
+	ids := make([]string, len(l.Data))
+	for i, m := range l.Data {
+		ids[i] = m.ID
+	}
+	return ids
+}
+
+func getJSON(ctx context.Context, httpClient *http.Client, url string, header http.Header, out any) error {
+	resp, err := send(ctx, httpClient, http.MethodGet, url, header, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// ListModels calls /v1/models, or the models path next to a custom
+// .../chat/completions path.
+func (c Client) ListModels(ctx context.Context) ([]string, error) {
+	path := c.Path
+	if path == "" {
+		path = "/v1/chat/completions"
+	}
+	if strings.Contains(path, "{model}") || !strings.HasSuffix(path, "/chat/completions") {
+		return nil, ErrModelsUnsupported
+	}
+	path = strings.TrimSuffix(path, "/chat/completions") + "/models"
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	var list modelList
+	if err := getJSON(ctx, c.Client, c.withQuery(strings.TrimRight(c.BaseURL, "/")+path), c.header(), &list); err != nil {
+		return nil, err
+	}
+	return list.ids(), nil
+}
+
+func (a Anthropic) ListModels(ctx context.Context) ([]string, error) {
+	var list modelList
+	if err := getJSON(ctx, a.Client, strings.TrimRight(a.BaseURL, "/")+"/v1/models?limit=1000", a.header(), &list); err != nil {
+		return nil, err
+	}
+	return list.ids(), nil
+}
+
+// ListModels returns the locally pulled models from /api/tags.
+func (o Ollama) ListModels(ctx context.Context) ([]string, error) {
+	var tags struct {
+		Models []struct {
+			Name string `json:"name"`
+		} `json:"models"`
+	}
+	if err := getJSON(ctx, o.Client, strings.TrimRight(o.BaseURL, "/")+"/api/tags", extraHeader(o.Headers), &tags); err != nil {
+		return nil, err
+	}
+	names := make([]string, len(tags.Models))
+	for i, m := range tags.Models {
+		names[i] = m.Name
+	}
+	return names, nil
+}
+
+func (f Fake) ListModels(ctx context.Context) ([]string, error) {
+	model := f.Model
+	if model == "" {
+		model = ProviderFake
+	}
+	return []string{model}, nil
+}
+
+// HasModel reports whether model is in models; Ollama's implicit :latest tag
+// is accepted.
+func HasModel(models []string, model string) bool {
+	for _, m := range models {
+		if m == model || m == model+":latest" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,56 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestClientListModels(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/gw/v1/models" || r.URL.Query().Get("team") != "infra" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		}
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			t.Errorf("unexpected auth header %q", r.Header.Get("Authorization"))
+		}
+		_, _ = w.Write([]byte(`{"data":[{"id":"gpt-4o"},{"id":"gpt-4o-mini"}]}`))
+	}))
+	t.Cleanup(server.Close)
+
+	client := Client{BaseURL: server.URL, APIKey: "secret", Path: "/gw/v1/chat/completions", Query: map[string]string{"team": "infra"}}
+	models, err := client.ListModels(context.Background())
+	if err != nil {
+		t.Fatalf("ListModels error: %v", err)
+	}
+	if !reflect.DeepEqual(models, []string{"gpt-4o", "gpt-4o-mini"}) {
+		t.Fatalf("models = %v", models)
+	}
+
+	client.Path = "/openai/deployments/{model}/chat/completions"
+	if _, err := client.ListModels(context.Background()); !errors.Is(err, ErrModelsUnsupported) {
+		t.Fatalf("deployment path error = %v", err)
+	}
+}
+
+func TestOllamaListModels(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/tags" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"models": []map[string]string{{"name": "llama3.1:latest"}}})
+	}))
+	t.Cleanup(server.Close)
+
+	models, err := Ollama{BaseURL: server.URL}.ListModels(context.Background())
+	if err != nil {
+		t.Fatalf("ListModels error: %v", err)
+	}
+	if !HasModel(models, "llama3.1") || HasModel(models, "llama3") {
+		t.Fatalf("HasModel on %v", models)
+	}
+}
@@ -0,0 +1,176 @@
+package llm
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const (
+	ProviderOllama = "ollama"
+
+	OllamaBaseURL = "http://localhost:11434"
+)
+
+// Ollama talks to the native /api/chat endpoint of a local Ollama server. No
+// API key is needed; NumCtx and KeepAlive are passed through when set.
+type Ollama struct {
+	BaseURL   string
+	Model     string
+	NumCtx    int
+	KeepAlive string
+	Headers   map[string]string
+	Client    *http.Client
+	Cache     Cache
+	Retry     RetryPolicy
+}
+
+type ollamaRequest struct {
+	Model     string         `json:"model"`
+	Messages  []ChatMessage  `json:"messages"`
+	Stream    bool           `json:"stream"`
+	Options   map[string]any `json:"options,omitempty"`
+	KeepAlive string         `json:"keep_alive,omitempty"`
+	// Format holds a JSON schema for structured output.
+	Format json.RawMessage `json:"format,omitempty"`
+}
+
+type ollamaResponse struct {
+	Model           string      `json:"model"`
+	Message         ChatMessage `json:"message"`
+	Done            bool        `json:"done"`
+	PromptEvalCount int         `json:"prompt_eval_count"`
+	EvalCount       int         `json:"eval_count"`
+	Error           string      `json:"error"`
+}
+
+func (r ollamaResponse) usage() Usage {
+	return Usage{
+		PromptTokens:     r.PromptEvalCount,
+		CompletionTokens: r.EvalCount,
+		TotalTokens:      r.PromptEvalCount + r.EvalCount,
+	}
+}
+
+func (o Ollama) Complete(ctx context.Context, req ChatRequest) (ChatResponse, error) {
+	if req.Model == "" {
+		req.Model = o.Model
+	}
+	if o.BaseURL == "" || req.Model == "" {
+		return ChatResponse{}, errors.New("missing base URL or model")
+	}
+	wire := o.request(req, false)
+	body, err := json.Marshal(wire)
+	if err != nil {
+		return ChatResponse{}, err
+	}
+	return complete(ctx, o.Cache, cacheKey(o.BaseURL, req, wire.Options), req, o.Retry, func() (ChatResponse, error) {
+		resp, err := o.post(ctx, body)
+		if err != nil {
+			return ChatResponse{}, err
+		}
+		defer resp.Body.Close()
+		var raw ollamaResponse
+		if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+			return ChatResponse{}, err
+		}
+		if raw.Error != "" {
+			return ChatResponse{}, &APIError{StatusCode: resp.StatusCode, Message: raw.Error}
+		}
+		out := completedResponse(raw.Model, raw.Message.Content)
+		out.Usage = raw.usage()
+		return out, nil
+	})
+}
+
+func (o Ollama) StreamComplete(ctx context.Context, req ChatRequest, onDelta func(string) error) (ChatResponse, error) {
+	if req.Model == "" {
+		req.Model = o.Model
+	}
+	if o.BaseURL == "" || req.Model == "" {
+		return ChatResponse{}, errors.New("missing base URL or model")
+	}
+	wire := o.request(req, true)
+	body, err := json.Marshal(wire)
+	if err != nil {
+		return ChatResponse{}, err
+	}
+	return stream(ctx, o.Cache, cacheKey(o.BaseURL, req, wire.Options), req, o.Retry, onDelta, func(onDelta func(string) error) (Usage, bool, error) {
+		resp, err := o.post(ctx, body)
+		if err != nil {
+			return Usage{}, false, err
+		}
+		defer resp.Body.Close()
+		return readOllamaStream(resp, onDelta)
+	})
+}
+
+func (o Ollama) request(req ChatRequest, stream bool) ollamaRequest {
+	out := ollamaRequest{Model: req.Model, Messages: req.Messages, Stream: stream, KeepAlive: o.KeepAlive}
+	options := map[string]any{}
+	if o.NumCtx > 0 {
+		options["num_ctx"] = o.NumCtx
+	}
+	if req.Temperature != nil {
+		options["temperature"] = *req.Temperature
+	}
+	if req.TopP != nil {
+		options["top_p"] = *req.TopP
+	}
+	if req.Seed != nil {
+		options["seed"] = *req.Seed
+	}
+	if req.MaxTokens > 0 {
+		options["num_predict"] = req.MaxTokens
+	}
+	if len(req.Stop) > 0 {
+		options["stop"] = req.Stop
+	}
+	if len(options) > 0 {
+		out.Options = options
+	}
+	if f := req.ResponseFormat; f != nil && f.JSONSchema != nil {
+		out.Format = f.JSONSchema.Schema
+	}
+	return out
+}
+
+func (o Ollama) post(ctx context.Context, body []byte) (*http.Response, error) {
+	return post(ctx, o.Client, strings.TrimRight(o.BaseURL, "/")+"/api/chat", extraHeader(o.Headers), body)
+}
+
+// readOllamaStream parses newline-delimited JSON objects; the final object
+// has done=true and carries the token counts.
+func readOllamaStream(resp *http.Response, onDelta func(string) error) (Usage, bool, error) {
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var chunk ollamaResponse
+		if err := json.Unmarshal([]byte(line), &chunk); err != nil {
+			return Usage{}, false, err
+		}
+		if chunk.Error != "" {
+			return Usage{}, false, &APIError{StatusCode: resp.StatusCode, Message: chunk.Error}
+		}
+		if chunk.Message.Content != "" {
+			if err := onDelta(chunk.Message.Content); err != nil {
+				return Usage{}, false, err
+			}
+		}
+		if chunk.Done {
+			return chunk.usage(), true, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return Usage{}, false, err
+	}
+	return Usage{}, false, io.ErrUnexpectedEOF
+}
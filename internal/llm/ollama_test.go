@@ -0,0 +1,105 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestOllamaComplete(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/chat" || r.Header.Get("Authorization") != "" || r.Header.Get("X-Team") != "infra" {
+			t.Errorf("unexpected request %s %v", r.URL.Path, r.Header)
+		}
+		var req ollamaRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode: %v", err)
+		}
+		if req.Stream || req.KeepAlive != "10m" || req.Options["num_ctx"] != float64(8192) {
+			t.Errorf("unexpected request: %+v", req)
+		}
+		_, _ = w.Write([]byte(`{"model":"llama3","message":{"role":"assistant","content":"offline"},"done":true,"prompt_eval_count":12,"eval_count":4}`))
+	}))
+	t.Cleanup(server.Close)
+
+	client := Ollama{BaseURL: server.URL, Model: "llama3", NumCtx: 8192, KeepAlive: "10m", Headers: map[string]string{"X-Team": "infra"}}
+	resp, err := client.Complete(context.Background(), ChatRequest{Messages: []ChatMessage{{Role: "user", Content: "hi"}}})
+	if err != nil {
+		t.Fatalf("Complete error: %v", err)
+	}
+	if resp.Choices[0].Message.Content != "offline" || resp.Usage.TotalTokens != 16 {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+}
+
+func TestOllamaStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		_, _ = w.Write([]byte(`{"model":"llama3","message":{"role":"assistant","content":"air"},"done":false}` + "\n"))
+		_, _ = w.Write([]byte(`{"model":"llama3","message":{"role":"assistant","content":"-gapped"},"done":false}` + "\n"))
+		_, _ = w.Write([]byte(`{"model":"llama3","message":{"role":"assistant","content":""},"done":true,"prompt_eval_count":3,"eval_count":2}` + "\n"))
+	}))
+	t.Cleanup(server.Close)
+
+	client := Ollama{BaseURL: server.URL, Model: "llama3"}
+	var out strings.Builder
+	_, err := client.StreamComplete(context.Background(), ChatRequest{}, func(delta string) error {
+		out.WriteString(delta)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("StreamComplete error: %v", err)
+	}
+	if out.String() != "air-gapped" {
+		t.Fatalf("unexpected output %q", out.String())
+	}
+}
+
+func TestOllamaCacheKeyCoversNumCtx(t *testing.T) {
+	hits := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		_, _ = w.Write([]byte(`{"model":"llama3","message":{"role":"assistant","content":"ok"},"done":true}`))
+	}))
+	t.Cleanup(server.Close)
+
+	cache := memCache{}
+	req := ChatRequest{Messages: []ChatMessage{{Role: "user", Content: "hi"}}}
+	for _, numCtx := range []int{4096, 4096, 32768} {
+		client := Ollama{BaseURL: server.URL, Model: "llama3", NumCtx: numCtx, Cache: cache}
+		if _, err := client.Complete(context.Background(), req); err != nil {
+			t.Fatalf("Complete error: %v", err)
+		}
+	}
+	if hits != 2 {
+		t.Fatalf("expected a new num_ctx to bypass the cache, got %d upstream calls", hits)
+	}
+}
+
+func TestOllamaErrorBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"error":"model \"nope\" not found, try pulling it first"}`))
+	}))
+	t.Cleanup(server.Close)
+
+	client := Ollama{BaseURL: server.URL, Model: "nope"}
+	_, err := client.Complete(context.Background(), ChatRequest{})
+	apiErr, ok := err.(*APIError)
+	if !ok || apiErr.StatusCode != http.StatusNotFound || !strings.Contains(apiErr.Message, "not found") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestNewOllamaNeedsNoAPIKey(t *testing.T) {
+	p, err := New(Options{Provider: ProviderOllama, Model: "llama3"})
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+	if o, ok := p.(Ollama); !ok || o.BaseURL != OllamaBaseURL {
+		t.Fatalf("unexpected provider: %#v", p)
+	}
+}
@@ -0,0 +1,67 @@
+package llm
+
+import (
+	"strings"
+)
+
+// Price is in USD per million tokens.
+type Price struct {
+	Input  float64
+	Output float64
+}
+
+func (p Price) Cost(u Usage) float64 {
+	return (float64(u.PromptTokens)*p.Input + float64(u.CompletionTokens)*p.Output) / 1e6
+}
+
+// DefaultPrices holds list prices of common models; config entries override
+// and extend it. Keys match model names by prefix.
+var DefaultPrices = map[string]Price{
+	"gpt-4o-mini":       {Input: 0.15, Output: 0.60},
+	"gpt-4o":            {Input: 2.50, Output: 10.00},
+	"gpt-4.1-nano":      {Input: 0.10, Output: 0.40},
+	"gpt-4.1-mini":      {Input: 0.40, Output: 1.60},
+	"gpt-4.1":           {Input: 2.00, Output: 8.00},
+	"gpt-4-turbo":       {Input: 10.00, Output: 30.00},
+	"gpt-3.5-turbo":     {Input: 0.50, Output: 1.50},
+	"o1-mini":           {Input: 1.10, Output: 4.40},
+	"o1":                {Input: 15.00, Output: 60.00},
+	"o3-mini":           {Input: 1.10, Output: 4.40},
+	"o4-mini":           {Input: 1.10, Output: 4.40},
+	"claude-3-5-haiku":  {Input: 0.80, Output: 4.00},
+	"claude-3-5-sonnet": {Input: 3.00, Output: 15.00},
+	"claude-3-7-sonnet": {Input: 3.00, Output: 15.00},
+	"claude-3-haiku":    {Input: 0.25, Output: 1.25},
+	"claude-3-opus":     {Input: 15.00, Output: 75.00},
+	"claude-sonnet-4":   {Input: 3.00, Output: 15.00},
+	"claude-opus-4":     {Input: 15.00, Output: 75.00},
+	"deepseek-chat":     {Input: 0.27, Output: 1.10},
+	"deepseek-reasoner": {Input: 0.55, Output: 2.19},
+}
+
+// LookupPrice finds the price of model, preferring an exact entry in
+// overrides, then the longest matching prefix across overrides and the
+// defaults.
+func LookupPrice(model string, overrides map[string]Price) (Price, bool) {
+	if p, ok := overrides[model]; ok {
+		return p, true
+	}
+	name := strings.ToLower(model)
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	best := -1
+	var price Price
+	for prefix, p := range DefaultPrices {
+		if strings.HasPrefix(name, strings.ToLower(prefix)) && len(prefix) > best {
+			best, price = len(prefix), p
+		}
+	}
+	// An override wins over a default prefix of the same length.
+	for prefix, p := range overrides {
+		if strings.HasPrefix(name, strings.ToLower(prefix)) && len(prefix) >= best {
+			best, price = len(prefix), p
+		}
+	}
+	return price, best >= 0
+}
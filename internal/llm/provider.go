@@ -0,0 +1,173 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Provider is implemented by every LLM backend. A request without a model
+// uses the provider's configured model.
+type Provider interface {
+	Complete(ctx context.Context, req ChatRequest) (ChatResponse, error)
+	// StreamComplete forwards the reply as it arrives and returns it in full
+	// together with the usage the backend reported.
+	StreamComplete(ctx context.Context, req ChatRequest, onDelta func(string) error) (ChatResponse, error)
+}
+
+type Options struct {
+	Provider   string
+	BaseURL    string
+	APIKey     string
+	Model      string
+	NumCtx     int
+	KeepAlive  string
+	Path       string
+	Query      map[string]string
+	AuthHeader string
+	AuthScheme string
+	Headers    map[string]string
+	HTTPClient *http.Client
+	Cache      Cache
+	Retry      RetryPolicy
+}
+
+// WithParams returns a provider that fills unset sampling parameters of every
+// request from defaults.
+func WithParams(p Provider, defaults Params) Provider {
+	return paramsProvider{Provider: p, defaults: defaults}
+}
+
+type paramsProvider struct {
+	Provider
+	defaults Params
+}
+
+func (p paramsProvider) Complete(ctx context.Context, req ChatRequest) (ChatResponse, error) {
+	req.Params = req.Params.Merge(p.defaults)
+	return p.Provider.Complete(ctx, req)
+}
+
+func (p paramsProvider) StreamComplete(ctx context.Context, req ChatRequest, onDelta func(string) error) (ChatResponse, error) {
+	req.Params = req.Params.Merge(p.defaults)
+	return p.Provider.StreamComplete(ctx, req, onDelta)
+}
+
+type Factory func(opts Options) (Provider, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Factory{}
+)
+
+func Register(name string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[name] = factory
+}
+
+// New builds the provider named by opts.Provider; an empty name selects the
+// OpenAI-compatible client.
+func New(opts Options) (Provider, error) {
+	name := opts.Provider
+	if name == "" {
+		name = ProviderOpenAI
+	}
+	registryMu.RLock()
+	factory, ok := registry[name]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown provider: %s (available: %s)", name, strings.Join(Providers(), ", "))
+	}
+	return factory(opts)
+}
+
+func Providers() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func init() {
+	Register(ProviderOpenAI, func(opts Options) (Provider, error) {
+		if err := requireOptions(opts, true); err != nil {
+			return nil, err
+		}
+		return Client{
+			BaseURL:    opts.BaseURL,
+			APIKey:     opts.APIKey,
+			Model:      opts.Model,
+			Path:       opts.Path,
+			Query:      opts.Query,
+			AuthHeader: opts.AuthHeader,
+			AuthScheme: opts.AuthScheme,
+			Headers:    opts.Headers,
+			Client:     opts.HTTPClient,
+			Cache:      opts.Cache,
+			Retry:      opts.Retry,
+		}, nil
+	})
+	Register(ProviderAnthropic, func(opts Options) (Provider, error) {
+		if opts.BaseURL == "" {
+			opts.BaseURL = AnthropicBaseURL
+		}
+		if err := requireOptions(opts, true); err != nil {
+			return nil, err
+		}
+		return Anthropic{
+			BaseURL: opts.BaseURL,
+			APIKey:  opts.APIKey,
+			Model:   opts.Model,
+			Headers: opts.Headers,
+			Client:  opts.HTTPClient,
+			Cache:   opts.Cache,
+			Retry:   opts.Retry,
+		}, nil
+	})
+	Register(ProviderOllama, func(opts Options) (Provider, error) {
+		if opts.BaseURL == "" {
+			opts.BaseURL = OllamaBaseURL
+		}
+		if err := requireOptions(opts, false); err != nil {
+			return nil, err
+		}
+		return Ollama{
+			BaseURL:   opts.BaseURL,
+			Model:     opts.Model,
+			NumCtx:    opts.NumCtx,
+			KeepAlive: opts.KeepAlive,
+			Headers:   opts.Headers,
+			Client:    opts.HTTPClient,
+			Cache:     opts.Cache,
+			Retry:     opts.Retry,
+		}, nil
+	})
+	Register(ProviderFake, func(opts Options) (Provider, error) {
+		return Fake{Model: opts.Model}, nil
+	})
+}
+
+func requireOptions(opts Options, apiKey bool) error {
+	var missing []string
+	if opts.BaseURL == "" {
+		missing = append(missing, "base_url")
+	}
+	if apiKey && opts.APIKey == "" {
+		missing = append(missing, "api_key")
+	}
+	if opts.Model == "" {
+		missing = append(missing, "model")
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing %s (set env, config, or flags)", strings.Join(missing, "/"))
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package llm
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestNewSelectsProvider(t *testing.T) {
+	p, err := New(Options{BaseURL: "http://x", APIKey: "k", Model: "m"})
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+	if _, ok := p.(Client); !ok {
+		t.Fatalf("default provider should be Client, got %T", p)
+	}
+	p, err = New(Options{Provider: ProviderAnthropic, APIKey: "k", Model: "m"})
+	if err != nil {
+		t.Fatalf("New anthropic error: %v", err)
+	}
+	if a, ok := p.(Anthropic); !ok || a.BaseURL != AnthropicBaseURL {
+		t.Fatalf("unexpected anthropic provider: %#v", p)
+	}
+	if _, err := New(Options{Provider: "nope"}); err == nil || !strings.Contains(err.Error(), "unknown provider") {
+		t.Fatalf("expected unknown provider error, got %v", err)
+	}
+	if _, err := New(Options{BaseURL: "http://x", Model: "m"}); err == nil || !strings.Contains(err.Error(), "api_key") {
+		t.Fatalf("expected missing api_key error, got %v", err)
+	}
+}
+
+func TestRegisterCustomProvider(t *testing.T) {
+	Register("test-echo", func(opts Options) (Provider, error) {
+		return Fake{Model: opts.Model, Reply: "echo"}, nil
+	})
+	p, err := New(Options{Provider: "test-echo", Model: "m"})
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+	resp, err := p.Complete(context.Background(), ChatRequest{})
+	if err != nil || resp.Choices[0].Message.Content != "echo" || resp.Model != "m" {
+		t.Fatalf("unexpected response %+v, %v", resp, err)
+	}
+}
+
+func TestFakeIsDeterministic(t *testing.T) {
+	req := ChatRequest{Messages: []ChatMessage{
+		{Role: "system", Content: "sys"},
+		{Role: "user", Content: "first line\nsecond line"},
+	}}
+	var fake Fake
+	a, err := fake.Complete(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Complete error: %v", err)
+	}
+	b, _ := fake.Complete(context.Background(), req)
+	if a.Choices[0].Message.Content != b.Choices[0].Message.Content || a.Usage != b.Usage {
+		t.Fatalf("fake responses differ: %+v vs %+v", a, b)
+	}
+	if a.Choices[0].Message.Content != "fake response to 2 lines: first line" || a.Usage.TotalTokens == 0 {
+		t.Fatalf("unexpected fake response: %+v", a)
+	}
+	var streamed strings.Builder
+	_, err = fake.StreamComplete(context.Background(), req, func(delta string) error {
+		streamed.WriteString(delta)
+		return nil
+	})
+	if err != nil || streamed.String() != a.Choices[0].Message.Content {
+		t.Fatalf("unexpected stream %q, %v", streamed.String(), err)
+	}
+}
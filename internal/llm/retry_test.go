@@ -0,0 +1,112 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var fastRetry = RetryPolicy{MaxRetries: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+
+func TestCompleteRetriesServerErrors(t *testing.T) {
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) < 3 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte(`{"error":{"message":"overloaded","type":"server_error"}}`))
+			return
+		}
+		resp := completedResponse("m", "ok")
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(server.Close)
+
+	client := Client{BaseURL: server.URL, APIKey: "k", Model: "m", Retry: fastRetry}
+	resp, err := client.Complete(context.Background(), ChatRequest{Model: "m"})
+	if err != nil {
+		t.Fatalf("Complete error: %v", err)
+	}
+	if resp.Choices[0].Message.Content != "ok" || atomic.LoadInt32(&attempts) != 3 {
+		t.Fatalf("unexpected result after %d attempts: %+v", attempts, resp)
+	}
+}
+
+func TestCompleteTypedAuthErrorNotRetried(t *testing.T) {
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.Header().Set("X-Request-Id", "req-123")
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"error":{"message":"bad key","type":"invalid_request_error","code":"invalid_api_key"}}`))
+	}))
+	t.Cleanup(server.Close)
+
+	client := Client{BaseURL: server.URL, APIKey: "k", Model: "m", Retry: fastRetry}
+	_, err := client.Complete(context.Background(), ChatRequest{Model: "m"})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected *APIError, got %T: %v", err, err)
+	}
+	if !apiErr.IsAuth() || apiErr.Type != "invalid_request_error" || apiErr.Code != "invalid_api_key" || apiErr.RequestID != "req-123" {
+		t.Fatalf("unexpected api error: %+v", apiErr)
+	}
+	if atomic.LoadInt32(&attempts) != 1 {
+		t.Fatalf("auth errors must not be retried, got %d attempts", attempts)
+	}
+}
+
+func TestStreamRetriesBeforeFirstDeltaOnly(t *testing.T) {
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch atomic.AddInt32(&attempts, 1) {
+		case 1:
+			w.WriteHeader(http.StatusTooManyRequests)
+		case 2:
+			_, _ = w.Write([]byte("data: {\"choices\":[{\"delta\":{\"content\":\"par\"}}]}\n\n"))
+			w.(http.Flusher).Flush()
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err == nil {
+				conn.Close()
+			}
+		default:
+			_, _ = w.Write([]byte("data: {\"choices\":[{\"delta\":{\"content\":\"full\"}}]}\n\ndata: [DONE]\n\n"))
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	client := Client{BaseURL: server.URL, APIKey: "k", Model: "m", Retry: fastRetry}
+	var out strings.Builder
+	_, err := client.StreamComplete(context.Background(), ChatRequest{Model: "m"}, func(delta string) error {
+		out.WriteString(delta)
+		return nil
+	})
+	if err == nil {
+		t.Fatal("expected error after a partial stream")
+	}
+	if out.String() != "par" || atomic.LoadInt32(&attempts) != 2 {
+		t.Fatalf("unexpected stream result %q after %d attempts", out.String(), attempts)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	h := http.Header{}
+	h.Set("Retry-After", "2")
+	if got := parseRetryAfter(h, now); got != 2*time.Second {
+		t.Fatalf("seconds: got %v", got)
+	}
+	h.Set("Retry-After", now.Add(5*time.Second).Format(http.TimeFormat))
+	if got := parseRetryAfter(h, now); got != 5*time.Second {
+		t.Fatalf("http date: got %v", got)
+	}
+	if got := (RetryPolicy{BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}).delay(3, time.Second); got != time.Second {
+		t.Fatalf("retry-after should be the minimum delay, got %v", got)
+	}
+}
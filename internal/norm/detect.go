@@ -0,0 +1,206 @@
+package norm
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ProfileScore is how well an embedded profile fits a sample of lines.
+// TSHits counts lines its ts rule matches, Covered adds the continuation
+// lines of its multiline rules, and Score weighs ts hits over other rules.
+type ProfileScore struct {
+	Name     string
+	Score    int
+	TSHits   int
+	RuleHits int
+	Covered  int
+}
+
+// Detection is the profile chosen for a sample: a single Profile, or a Mix
+// to choose from per line by timestamp format.
+type Detection struct {
+	Profile string
+	Mix     []string
+	Lines   int
+	Scores  []ProfileScore
+}
+
+func (d Detection) String() string {
+	if len(d.Mix) > 0 {
+		return fmt.Sprintf("per-line mix of %s (%d lines sampled)", strings.Join(d.Mix, ", "), d.Lines)
+	}
+	for _, s := range d.Scores {
+		if s.Name == d.Profile {
+			return fmt.Sprintf("%s (timestamps on %d of %d lines sampled)", d.Profile, s.TSHits, d.Lines)
+		}
+	}
+	return fmt.Sprintf("%s (no profile matched the %d lines sampled)", d.Profile, d.Lines)
+}
+
+type profileMatcher struct {
+	ts        *regexp.Regexp
+	rules     []compiledRule
+	multiline *compiledMultiline
+}
+
+func loadMatcher(name string) (profileMatcher, error) {
+	rf, err := loadProfile(name)
+	if err != nil {
+		return profileMatcher{}, err
+	}
+	var m profileMatcher
+	if m.rules, err = compileRules(rf.Rules); err != nil {
+		return profileMatcher{}, fmt.Errorf("profile %s: %w", name, err)
+	}
+	for _, rule := range m.rules {
+		if rule.isTS {
+			m.ts = rule.re
+			break
+		}
+	}
+	if m.multiline, err = compileMultiline(rf.Multiline); err != nil {
+		return profileMatcher{}, fmt.Errorf("profile %s: %w", name, err)
+	}
+	return m, nil
+}
+
+// match applies the profile's own rules in order, as Normalize does, so
+// rules written against earlier placeholders count too.
+func (m profileMatcher) match(line string) (ts bool, rules int) {
+	for _, rule := range m.rules {
+		if !rule.re.MatchString(line) {
+			continue
+		}
+		if rule.re == m.ts {
+			ts = true
+		} else {
+			rules++
+		}
+		line = rule.re.ReplaceAllLiteralString(line, rule.replace)
+	}
+	return ts, rules
+}
+
+// DetectProfile scores every embedded profile on lines; profiles need a ts
+// hit and another rule hit to count. The best profile is
+// used when it covers nearly all lines; when several profiles each claim a
+// share of the timestamps, as in merged files, they are mixed per line; and
+// when nothing covers half the lines the generic rules are used.
+func DetectProfile(lines []string) (Detection, error) {
+	var sample []string
+	for _, line := range lines {
+		if strings.TrimSpace(line) != "" {
+			sample = append(sample, strings.TrimRight(line, "\r\n"))
+		}
+	}
+	d := Detection{Profile: "generic", Lines: len(sample)}
+	hits := map[string][]bool{}
+	for _, name := range Profiles() {
+		m, err := loadMatcher(name)
+		if err != nil {
+			return Detection{}, err
+		}
+		s := ProfileScore{Name: name}
+		tsLines := make([]bool, len(sample))
+		for i, line := range sample {
+			ts, rules := m.match(line)
+			switch {
+			case ts:
+				tsLines[i] = true
+				s.TSHits++
+				s.Covered++
+			case s.TSHits > 0 && m.multiline != nil && m.multiline.continues(line):
+				s.Covered++
+			}
+			s.RuleHits += rules
+		}
+		// A timestamp alone is no evidence: ISO timestamps fit several
+		// profiles equally well.
+		if s.TSHits == 0 || s.RuleHits == 0 {
+			continue
+		}
+		s.Score = 3*s.TSHits + s.RuleHits
+		d.Scores = append(d.Scores, s)
+		hits[name] = tsLines
+	}
+	sort.SliceStable(d.Scores, func(i, j int) bool { return d.Scores[i].Score > d.Scores[j].Score })
+	if len(d.Scores) == 0 {
+		return d, nil
+	}
+
+	best := d.Scores[0]
+	n := len(sample)
+	if best.Covered*10 >= n*9 {
+		d.Profile = best.Name
+		return d, nil
+	}
+	// Add profiles in score order while each still claims lines of its own.
+	claimed := make([]bool, n)
+	covered := 0
+	var mix []string
+	for _, s := range d.Scores {
+		added := 0
+		for i, hit := range hits[s.Name] {
+			if hit && !claimed[i] {
+				claimed[i] = true
+				added++
+			}
+		}
+		if added*20 >= n {
+			mix = append(mix, s.Name)
+			covered += added
+		}
+	}
+	if len(mix) > 1 && (covered-best.TSHits)*10 >= n {
+		d.Mix = mix
+		return d, nil
+	}
+	if best.Covered*2 >= n {
+		d.Profile = best.Name
+	}
+	return d, nil
+}
+
+// ProfileDescription returns the description of an embedded profile.
+func ProfileDescription(name string) (string, error) {
+	if name == "" || name == "generic" {
+		return "Built-in timestamp, uuid, ip, mac, path, hex and number rules", nil
+	}
+	rf, err := loadProfile(name)
+	if err != nil {
+		return "", err
+	}
+	return rf.Description, nil
+}
+
+type mixEntry struct {
+	ts *regexp.Regexp
+	n  *Normalizer
+}
+
+// NewDetected returns the normalizer for a detection. A mix normalizes each
+// line with the first of its profiles whose timestamp the line carries and
+// falls back to the generic rules.
+func NewDetected(d Detection, ruleFilePath string, bucket string) (*Normalizer, error) {
+	if len(d.Mix) == 0 {
+		return New(d.Profile, ruleFilePath, bucket)
+	}
+	n, err := New("generic", ruleFilePath, bucket)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range d.Mix {
+		sub, err := New(name, ruleFilePath, bucket)
+		if err != nil {
+			return nil, err
+		}
+		m, err := loadMatcher(name)
+		if err != nil {
+			return nil, err
+		}
+		n.mix = append(n.mix, mixEntry{ts: m.ts, n: sub})
+	}
+	return n, nil
+}
@@ -0,0 +1,84 @@
+package norm
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDetectProfileSamples(t *testing.T) {
+	for file, want := range map[string]string{
+		"postgresql.log": "postgres",
+		"linux.log":      "kernel",
+		"nginx.log":      "nginx",
+		"mysql.log":      "mysql",
+		"redis.log":      "redis",
+		"java.log":       "java",
+		"k8s.log":        "k8s",
+		"syslog.log":     "syslog",
+	} {
+		d, err := DetectProfile(loadSample(t, file))
+		if err != nil {
+			t.Fatalf("%s: %v", file, err)
+		}
+		if d.Profile != want || d.Mix != nil {
+			t.Errorf("%s: detected %s, want %s; scores %+v", file, d, want, d.Scores)
+		}
+	}
+}
+
+func TestDetectProfileGenericAndMix(t *testing.T) {
+	d, err := DetectProfile([]string{"2024-01-02T03:04:05Z started", "2024-01-02T03:04:06Z stopped", "hello"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Profile != "generic" || d.Mix != nil {
+		t.Fatalf("plain ISO lines detected as %s", d)
+	}
+
+	lines := append(loadSample(t, "nginx.log"), loadSample(t, "redis.log")...)
+	d, err = DetectProfile(lines)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(d.Mix, []string{"redis", "nginx"}) {
+		t.Fatalf("merged file detected as %s; scores %+v", d, d.Scores)
+	}
+	n, err := NewDetected(d, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec := n.Normalize(lines[0], Source{}); rec.TS != "17/May/2015:08:05:32 +0000" || !strings.Contains(rec.Sig, "<status>") {
+		t.Fatalf("nginx line in mix: %+v", rec)
+	}
+	if rec := n.Normalize(lines[len(lines)-1], Source{}); !strings.HasPrefix(rec.Sig, "<pid>:<role> <ts>") {
+		t.Fatalf("redis line in mix: %+v", rec)
+	}
+	if rec := n.Normalize("user 42 logged in", Source{}); rec.Sig != "user <number> logged in" {
+		t.Fatalf("fallback line in mix: %+v", rec)
+	}
+}
+
+func TestDetectedMixMultilineAndInput(t *testing.T) {
+	n, err := NewDetected(Detection{Mix: []string{"java", "redis"}}, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := assemble(n.Assembler(), []string{
+		"2023-10-10 13:56:03.442 [exec-7] ERROR com.example.OrderController - Request failed",
+		"java.lang.IllegalStateException: order 981 is already shipped",
+		"\tat com.example.OrderService.cancel(OrderService.java:88)",
+		"1:M 10 Oct 2023 13:56:04.000 * Background saving started",
+		"2023-10-10 13:56:05.000 [main] INFO  com.example.Application - ok",
+	})
+	if len(events) != 3 || events[0].Lines != 3 || events[1].Line != 4 || events[2].Line != 5 {
+		t.Fatalf("events = %+v", events)
+	}
+
+	if err := n.SetInput(InputOptions{Format: "auto"}); err != nil {
+		t.Fatal(err)
+	}
+	if rec := n.Normalize(`{"level":"warn","msg":"disk 91% full"}`, Source{}); rec.Level != "warn" || rec.Sig != "warn disk <number>% full" {
+		t.Fatalf("json line in mix: %+v", rec)
+	}
+}
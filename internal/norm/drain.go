@@ -0,0 +1,253 @@
+package norm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Wildcard marks template positions that vary between lines.
+const Wildcard = "<*>"
+
+// DrainOptions follow Drain3: Depth counts the root and token-count levels,
+// so the default of 4 routes by the first token.
+type DrainOptions struct {
+	Depth       int
+	Sim         float64
+	MaxChildren int
+}
+
+func (o DrainOptions) withDefaults() DrainOptions {
+	if o.Depth < 3 {
+		o.Depth = 4
+	}
+	if o.Sim <= 0 {
+		o.Sim = 0.4
+	}
+	if o.MaxChildren <= 0 {
+		o.MaxChildren = 100
+	}
+	return o
+}
+
+type drainTemplate struct {
+	id     string
+	tokens []string
+	count  int
+}
+
+type drainNode struct {
+	children  map[string]*drainNode
+	templates []*drainTemplate
+}
+
+// Drain learns templates online from the masked signatures, following the
+// fixed-depth parse tree of Drain (He et al., ICWS 2017): lines are routed
+// by token count and their first tokens, then matched against the templates
+// of the leaf by token similarity.
+type Drain struct {
+	opts      DrainOptions
+	root      map[int]*drainNode
+	templates map[string]*drainTemplate
+}
+
+func NewDrain(opts DrainOptions) *Drain {
+	return &Drain{
+		opts:      opts.withDefaults(),
+		root:      map[int]*drainNode{},
+		templates: map[string]*drainTemplate{},
+	}
+}
+
+// Mine replaces the record's sig with its template, sets the template ID and
+// adds the tokens under wildcards to Vars["param"]. Tokens already masked by
+// a rule are left out, since their values are in Vars under the rule name.
+func (d *Drain) Mine(rec Record) Record {
+	tokens := strings.Fields(rec.Sig)
+	t := d.match(tokens)
+	rec.Sig = strings.Join(t.tokens, " ")
+	rec.TemplateID = t.id
+	for i, tok := range t.tokens {
+		if tok != Wildcard || i >= len(tokens) || isPlaceholder(tokens[i]) {
+			continue
+		}
+		if rec.Vars == nil {
+			rec.Vars = map[string][]string{}
+		}
+		rec.Vars["param"] = append(rec.Vars["param"], tokens[i])
+	}
+	return rec
+}
+
+func (d *Drain) match(tokens []string) *drainTemplate {
+	leaf := d.leaf(tokens)
+	var best *drainTemplate
+	bestSim, bestWild := -1.0, -1
+	for _, t := range leaf.templates {
+		sim, wild := similarity(t.tokens, tokens)
+		if sim > bestSim || (sim == bestSim && wild > bestWild) {
+			best, bestSim, bestWild = t, sim, wild
+		}
+	}
+	if best != nil && bestSim >= d.opts.Sim {
+		for i, tok := range tokens {
+			if best.tokens[i] != tok {
+				best.tokens[i] = Wildcard
+			}
+		}
+		best.count++
+		return best
+	}
+	t := &drainTemplate{id: d.newID(tokens), tokens: append([]string(nil), tokens...), count: 1}
+	leaf.templates = append(leaf.templates, t)
+	d.templates[t.id] = t
+	return t
+}
+
+// leaf walks the tree by token count and the first Depth-3 tokens, creating
+// nodes as needed.
+func (d *Drain) leaf(tokens []string) *drainNode {
+	node, ok := d.root[len(tokens)]
+	if !ok {
+		node = &drainNode{children: map[string]*drainNode{}}
+		d.root[len(tokens)] = node
+	}
+	for i := 0; i < d.opts.Depth-3 && i < len(tokens); i++ {
+		key := tokens[i]
+		if hasDigit(key) {
+			key = Wildcard
+		}
+		child, ok := node.children[key]
+		if !ok {
+			if len(node.children) >= d.opts.MaxChildren-1 && key != Wildcard {
+				key = Wildcard
+				child = node.children[key]
+			}
+			if child == nil {
+				child = &drainNode{children: map[string]*drainNode{}}
+				node.children[key] = child
+			}
+		}
+		node = child
+	}
+	return node
+}
+
+func similarity(template, tokens []string) (float64, int) {
+	if len(tokens) == 0 {
+		return 1, 0
+	}
+	same, wild := 0, 0
+	for i, tok := range template {
+		switch {
+		case tok == Wildcard:
+			wild++
+		case tok == tokens[i]:
+			same++
+		}
+	}
+	return float64(same) / float64(len(tokens)), wild
+}
+
+// templateID hashes the line a template was created from, so the ID stays
+// the same while the template generalizes and across runs.
+func templateID(tokens []string) string {
+	h := fnv.New64a()
+	h.Write([]byte(strings.Join(tokens, " ")))
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+// newID is templateID, rehashed with a counter while the ID is taken: the
+// same line can seed a second template once the first has generalized past
+// the similarity threshold, and 64-bit hashes can collide.
+func (d *Drain) newID(tokens []string) string {
+	id := templateID(tokens)
+	for n := 1; d.templates[id] != nil; n++ {
+		id = templateID(append(tokens[:len(tokens):len(tokens)], fmt.Sprintf("#%d", n)))
+	}
+	return id
+}
+
+func hasDigit(s string) bool {
+	return strings.IndexFunc(s, unicode.IsDigit) >= 0
+}
+
+func isPlaceholder(tok string) bool {
+	return len(tok) > 2 && strings.HasPrefix(tok, "<") && strings.HasSuffix(tok, ">")
+}
+
+type drainState struct {
+	Version   int                  `json:"version"`
+	Templates []drainStateTemplate `json:"templates"`
+}
+
+type drainStateTemplate struct {
+	ID       string `json:"id"`
+	Template string `json:"template"`
+	Count    int    `json:"count"`
+}
+
+// LoadDrain returns a Drain seeded with the templates saved at path, or an
+// empty one when the file does not exist yet.
+func LoadDrain(path string, opts DrainOptions) (*Drain, error) {
+	d := NewDrain(opts)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return d, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var state drainState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if state.Version != 1 {
+		return nil, fmt.Errorf("%s: unsupported drain state version: %d", path, state.Version)
+	}
+	for _, st := range state.Templates {
+		t := &drainTemplate{id: st.ID, tokens: strings.Fields(st.Template), count: st.Count}
+		if t.id == "" || d.templates[t.id] != nil {
+			t.id = d.newID(t.tokens)
+		}
+		leaf := d.leaf(t.tokens)
+		leaf.templates = append(leaf.templates, t)
+		d.templates[t.id] = t
+	}
+	return d, nil
+}
+
+// Save writes the templates through a temp file in the same directory, so an
+// interrupted run never leaves a truncated state; the file is created 0600.
+func (d *Drain) Save(path string) error {
+	state := drainState{Version: 1, Templates: make([]drainStateTemplate, 0, len(d.templates))}
+	for _, t := range d.templates {
+		state.Templates = append(state.Templates, drainStateTemplate{ID: t.id, Template: strings.Join(t.tokens, " "), Count: t.count})
+	}
+	sort.Slice(state.Templates, func(i, j int) bool { return state.Templates[i].ID < state.Templates[j].ID })
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".drain-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,106 @@
+package norm
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestDrainLearnsTemplates(t *testing.T) {
+	n, err := New("generic", "", "")
+	if err != nil {
+		t.Fatalf("new normalizer: %v", err)
+	}
+	d := NewDrain(DrainOptions{})
+	lines := []string{
+		"user alice logged in from 10.0.0.1",
+		"user bob logged in from 10.0.0.2",
+		"queue orders is full",
+		"user carol logged in from 10.0.0.3",
+		"queue billing is full",
+	}
+	var recs []Record
+	for _, line := range lines {
+		recs = append(recs, d.Mine(n.Normalize(line, Source{})))
+	}
+
+	if recs[0].TemplateID != recs[1].TemplateID || recs[1].TemplateID != recs[3].TemplateID {
+		t.Fatalf("login lines got different templates: %+v", recs)
+	}
+	if recs[2].TemplateID != recs[4].TemplateID || recs[2].TemplateID == recs[0].TemplateID {
+		t.Fatalf("queue lines not grouped: %+v", recs)
+	}
+	if recs[3].Sig != "user <*> logged in from <ip>" || recs[4].Sig != "queue <*> is full" {
+		t.Fatalf("templates = %q, %q", recs[3].Sig, recs[4].Sig)
+	}
+	if got := recs[3].Vars["param"]; !reflect.DeepEqual(got, []string{"carol"}) {
+		t.Fatalf("params = %v", got)
+	}
+	if got := recs[3].Vars["ip"]; !reflect.DeepEqual(got, []string{"10.0.0.3"}) {
+		t.Fatalf("ip vars = %v", got)
+	}
+	if recs[0].Vars["param"] != nil {
+		t.Fatalf("first line has params: %v", recs[0].Vars)
+	}
+}
+
+func TestDrainStateRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "drain.json")
+	d, err := LoadDrain(path, DrainOptions{})
+	if err != nil {
+		t.Fatalf("load missing state: %v", err)
+	}
+	first := d.Mine(Record{Sig: "job a done"})
+	d.Mine(Record{Sig: "job b done"})
+	if err := d.Save(path); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	d, err = LoadDrain(path, DrainOptions{})
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	rec := d.Mine(Record{Sig: "job c done"})
+	if rec.TemplateID != first.TemplateID || rec.Sig != "job <*> done" {
+		t.Fatalf("after reload: %+v, want id %s", rec, first.TemplateID)
+	}
+	if d.templates[rec.TemplateID].count != 3 {
+		t.Fatalf("count = %d", d.templates[rec.TemplateID].count)
+	}
+}
+
+func TestDrainTemplateIDCollisions(t *testing.T) {
+	d := NewDrain(DrainOptions{})
+	taken := templateID([]string{"job", "a", "done"})
+	d.templates[taken] = &drainTemplate{id: taken, tokens: []string{"other"}}
+	if rec := d.Mine(Record{Sig: "job a done"}); rec.TemplateID == taken || rec.TemplateID == "" {
+		t.Fatalf("colliding id reused: %s", rec.TemplateID)
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "drain.json")
+	state := `{"version":1,"templates":[{"id":"x","template":"job <*> done","count":2},{"id":"x","template":"disk full","count":1}]}`
+	if err := os.WriteFile(path, []byte(state), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	d, err := LoadDrain(path, DrainOptions{})
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if len(d.templates) != 2 || d.templates["x"] == nil {
+		t.Fatalf("templates after load: %v", d.templates)
+	}
+	if err := d.Save(path); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if runtime.GOOS != "windows" {
+		if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o600 {
+			t.Fatalf("state mode = %v, %v", info.Mode(), err)
+		}
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Fatalf("temp files left behind: %v", entries)
+	}
+}
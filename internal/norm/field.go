@@ -0,0 +1,39 @@
+package norm
+
+import (
+	"strconv"
+	"strings"
+)
+
+func (r Record) Field(key string) (string, bool) {
+	switch key {
+	case "raw":
+		return r.Raw, true
+	case "sig":
+		return r.Sig, true
+	case "template_id":
+		return r.TemplateID, true
+	case "ts":
+		return r.TS, true
+	case "level":
+		return r.Level, true
+	case "bucket":
+		return r.Bucket, true
+	case "src.file":
+		return r.Src.File, true
+	case "src.line":
+		if r.Src.Line == 0 {
+			return "", true
+		}
+		return strconv.Itoa(r.Src.Line), true
+	case "src.host":
+		return r.Src.Host, true
+	}
+	if name, ok := strings.CutPrefix(key, "vars."); ok && name != "" {
+		return strings.Join(r.Vars[name], ","), true
+	}
+	if name, ok := strings.CutPrefix(key, "fields."); ok && name != "" {
+		return r.Fields[name], true
+	}
+	return "", false
+}
@@ -0,0 +1,176 @@
+package norm
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+const (
+	DefaultMaxLines = 500
+	DefaultMaxBytes = 256 * 1024
+)
+
+type compiledMultiline struct {
+	start    *regexp.Regexp
+	cont     *regexp.Regexp
+	partial  *regexp.Regexp
+	header   *regexp.Regexp
+	maxLines int
+	maxBytes int
+}
+
+func compileMultiline(m *Multiline) (*compiledMultiline, error) {
+	if m == nil || (m.Start == "" && m.Continuation == "" && m.Partial == "") {
+		return nil, nil
+	}
+	if m.MaxLines < 0 || m.MaxBytes < 0 {
+		return nil, errors.New("multiline max_lines and max_bytes must not be negative")
+	}
+	c := &compiledMultiline{maxLines: m.MaxLines, maxBytes: m.MaxBytes}
+	if c.maxLines == 0 {
+		c.maxLines = DefaultMaxLines
+	}
+	if c.maxBytes == 0 {
+		c.maxBytes = DefaultMaxBytes
+	}
+	var err error
+	if m.Start != "" {
+		if c.start, err = regexp.Compile(m.Start); err != nil {
+			return nil, err
+		}
+	}
+	if m.Continuation != "" {
+		if c.cont, err = regexp.Compile(m.Continuation); err != nil {
+			return nil, err
+		}
+	}
+	if m.Partial != "" {
+		if c.partial, err = regexp.Compile(m.Partial); err != nil {
+			return nil, err
+		}
+	}
+	if m.PartialHeader != "" {
+		if c.header, err = regexp.Compile(m.PartialHeader); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func (c *compiledMultiline) continues(line string) bool {
+	if c.cont != nil && c.cont.MatchString(line) {
+		return true
+	}
+	return c.start != nil && !c.start.MatchString(line)
+}
+
+// Event is one logical log entry; Line is the line it starts on.
+type Event struct {
+	Text  string
+	Line  int
+	Lines int
+}
+
+// Assembler joins continuation lines onto the line that starts their event.
+// A nil Assembler passes every line through as its own event.
+//
+// For a per-line mix of profiles, each event follows the multiline rules of
+// the profile its first line is routed to, and a line carrying another
+// profile's timestamp always starts a new event.
+type Assembler struct {
+	base  *compiledMultiline
+	mix   []mixEntry
+	ml    *compiledMultiline
+	cur   int
+	buf   []string
+	bytes int
+	line  int
+	lines int
+	// partial is set while the last line was split and continues in the
+	// next one.
+	partial bool
+}
+
+// Assembler returns a new assembler for the multiline rules of the profile
+// and rules file, or of the mixed profiles, or nil when they define none.
+func (n *Normalizer) Assembler() *Assembler {
+	found := n.multiline != nil
+	for _, e := range n.mix {
+		found = found || e.n.multiline != nil
+	}
+	if !found {
+		return nil
+	}
+	return &Assembler{base: n.multiline, mix: n.mix, cur: -1}
+}
+
+// route returns the index of the mixed profile whose timestamp line carries,
+// or -1 for the base rules.
+func (a *Assembler) route(line string) int {
+	for i, e := range a.mix {
+		if e.ts.MatchString(line) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Add feeds line number no and returns the event it completes, if any. An
+// event that would grow past max_lines or max_bytes is cut there and the
+// line starts a new one.
+func (a *Assembler) Add(line string, no int) (Event, bool) {
+	line = strings.TrimRight(line, "\r\n")
+	if a == nil {
+		return Event{Text: line, Line: no, Lines: 1}, true
+	}
+	route := a.route(line)
+	if ml := a.ml; ml != nil && len(a.buf) > 0 && (route < 0 || route == a.cur) {
+		partial := ml.partial != nil && ml.partial.MatchString(line)
+		if a.partial {
+			piece := line
+			if ml.header != nil {
+				if loc := ml.header.FindStringIndex(piece); loc != nil && loc[0] == 0 {
+					piece = piece[loc[1]:]
+				}
+			}
+			if a.bytes+len(piece) <= ml.maxBytes {
+				a.buf[len(a.buf)-1] += piece
+				a.bytes += len(piece)
+				a.lines++
+				a.partial = partial
+				return Event{}, false
+			}
+		}
+		if ml.continues(line) && len(a.buf) < ml.maxLines && a.bytes+1+len(line) <= ml.maxBytes {
+			a.buf = append(a.buf, line)
+			a.bytes += 1 + len(line)
+			a.lines++
+			a.partial = partial
+			return Event{}, false
+		}
+	}
+	ev, ok := a.Flush()
+	a.cur, a.ml = route, a.base
+	if route >= 0 {
+		a.ml = a.mix[route].n.multiline
+	}
+	a.partial = a.ml != nil && a.ml.partial != nil && a.ml.partial.MatchString(line)
+	a.buf = append(a.buf, line)
+	a.bytes = len(line)
+	a.line = no
+	a.lines = 1
+	return ev, ok
+}
+
+// Flush returns the pending event, if any.
+func (a *Assembler) Flush() (Event, bool) {
+	if a == nil || len(a.buf) == 0 {
+		return Event{}, false
+	}
+	ev := Event{Text: strings.Join(a.buf, "\n"), Line: a.line, Lines: a.lines}
+	a.buf = a.buf[:0]
+	a.bytes = 0
+	a.lines = 0
+	return ev, true
+}
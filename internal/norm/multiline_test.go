@@ -0,0 +1,125 @@
+package norm
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func assemble(a *Assembler, lines []string) []Event {
+	var events []Event
+	for i, line := range lines {
+		if ev, ok := a.Add(line, i+1); ok {
+			events = append(events, ev)
+		}
+	}
+	if ev, ok := a.Flush(); ok {
+		events = append(events, ev)
+	}
+	return events
+}
+
+func TestAssemblerStackTrace(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "rules.yaml")
+	data := strings.Join([]string{
+		"version: 1",
+		"rules: []",
+		"multiline:",
+		"  start: '^\\d{4}-\\d{2}-\\d{2}'",
+		"  max_lines: 3",
+	}, "\n")
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatalf("write rules: %v", err)
+	}
+	n, err := New("generic", path, "")
+	if err != nil {
+		t.Fatalf("new normalizer: %v", err)
+	}
+	events := assemble(n.Assembler(), []string{
+		"2024-01-02 03:04:05 ERROR request failed",
+		"java.lang.IllegalStateException: boom",
+		"\tat com.example.Foo.bar(Foo.java:12)",
+		"\tat com.example.Foo.main(Foo.java:3)",
+		"2024-01-02 03:04:06 INFO recovered",
+	})
+	if len(events) != 3 {
+		t.Fatalf("events = %+v", events)
+	}
+	if events[0].Line != 1 || events[0].Lines != 3 || !strings.HasSuffix(events[0].Text, "(Foo.java:12)") {
+		t.Fatalf("first event = %+v", events[0])
+	}
+	if events[1].Line != 4 || events[2].Line != 5 {
+		t.Fatalf("max_lines split = %+v", events)
+	}
+
+	rec := n.Normalize(events[0].Text, Source{Line: events[0].Line})
+	if rec.TS != "2024-01-02 03:04:05" || !strings.Contains(rec.Sig, "\n\tat com.example.Foo.bar(Foo.java:<number>)") {
+		t.Fatalf("record = %+v", rec)
+	}
+}
+
+func TestAssemblerPostgres(t *testing.T) {
+	n, err := New("postgres", "", "")
+	if err != nil {
+		t.Fatalf("new normalizer: %v", err)
+	}
+	lines := loadSample(t, "postgresql.log")
+	events := assemble(n.Assembler(), lines)
+	if len(events) == 0 || len(events) >= len(lines) {
+		t.Fatalf("%d events from %d lines", len(events), len(lines))
+	}
+	total := 0
+	for _, ev := range events {
+		total += ev.Lines
+		if strings.Contains(strings.SplitN(ev.Text, "\n", 2)[0], "DETAIL:  ") {
+			t.Fatalf("event starts with a continuation: %q", ev.Text)
+		}
+	}
+	if total != len(lines) {
+		t.Fatalf("events cover %d of %d lines", total, len(lines))
+	}
+	if events[0].Line != 1 || events[0].Lines != 2 || events[1].Line != 3 {
+		t.Fatalf("unexpected grouping: %+v", events[:2])
+	}
+}
+
+func TestAssemblerMaxBytesAndNil(t *testing.T) {
+	ml, err := compileMultiline(&Multiline{Continuation: `^\s`, MaxBytes: 10})
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	events := assemble(&Assembler{base: ml, cur: -1}, []string{"start", " abc", " defgh"})
+	if len(events) != 2 || events[0].Text != "start\n abc" || events[1].Line != 3 {
+		t.Fatalf("events = %+v", events)
+	}
+
+	var none *Assembler
+	events = assemble(none, []string{"a\r", " b"})
+	if len(events) != 2 || events[0].Text != "a" || events[1].Line != 2 {
+		t.Fatalf("nil assembler events = %+v", events)
+	}
+}
+
+func TestAssemblerCRIPartial(t *testing.T) {
+	n, err := New("k8s", "", "")
+	if err != nil {
+		t.Fatalf("new normalizer: %v", err)
+	}
+	events := assemble(n.Assembler(), []string{
+		"2023-10-10T13:55:41Z stdout P first ",
+		"2023-10-10T13:55:41Z stdout P second ",
+		"2023-10-10T13:55:41Z stdout F third",
+		"2023-10-10T13:55:42Z stderr F next",
+	})
+	if len(events) != 2 {
+		t.Fatalf("events = %+v", events)
+	}
+	if events[0].Text != "2023-10-10T13:55:41Z stdout P first second third" || events[0].Line != 1 || events[0].Lines != 3 {
+		t.Fatalf("joined event = %+v", events[0])
+	}
+	if events[1].Text != "2023-10-10T13:55:42Z stderr F next" || events[1].Line != 4 || events[1].Lines != 1 {
+		t.Fatalf("next event = %+v", events[1])
+	}
+}
@@ -2,14 +2,18 @@ package norm
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 )
 
 type Normalizer struct {
-	rules    []compiledRule
-	preserve map[string]struct{}
-	bucket   time.Duration
+	rules     []compiledRule
+	preserve  map[string]struct{}
+	bucket    time.Duration
+	multiline *compiledMultiline
+	input     *InputOptions
+	mix       []mixEntry
 }
 
 func New(profile string, ruleFilePath string, bucket string) (*Normalizer, error) {
@@ -21,7 +25,7 @@ func New(profile string, ruleFilePath string, bucket string) (*Normalizer, error
 		}
 		extra = &rf
 	}
-	rules, preserve, err := buildRules(profile, extra)
+	rules, preserve, multiline, err := buildRules(profile, extra)
 	if err != nil {
 		return nil, err
 	}
@@ -29,6 +33,10 @@ func New(profile string, ruleFilePath string, bucket string) (*Normalizer, error
 	if err != nil {
 		return nil, err
 	}
+	compiledML, err := compileMultiline(multiline)
+	if err != nil {
+		return nil, err
+	}
 	var bucketDur time.Duration
 	if bucket != "" {
 		if d, err := time.ParseDuration(bucket); err == nil {
@@ -38,18 +46,41 @@ func New(profile string, ruleFilePath string, bucket string) (*Normalizer, error
 		}
 	}
 	return &Normalizer{
-		rules:    compiled,
-		preserve: preserve,
-		bucket:   bucketDur,
+		rules:     compiled,
+		preserve:  preserve,
+		bucket:    bucketDur,
+		multiline: compiledML,
 	}, nil
 }
 
 func (n *Normalizer) Normalize(line string, src Source) Record {
 	raw := strings.TrimRight(line, "\r\n")
-	sig := raw
+	for _, e := range n.mix {
+		if e.ts.MatchString(raw) {
+			return e.n.Normalize(raw, src)
+		}
+	}
+	if n.input != nil {
+		if fields, ok := n.input.parse(raw); ok {
+			return n.normalizeFields(raw, fields, src)
+		}
+	}
 	vars := map[string][]string{}
-	ts := ""
+	sig, ts := n.mask(raw, vars)
+	return n.finish(Record{
+		Raw:  raw,
+		Sig:  sig,
+		TS:   ts,
+		Vars: vars,
+		Src:  src,
+	})
+}
 
+// mask applies the rules to text, collecting the masked values into vars
+// and returning the first timestamp found.
+func (n *Normalizer) mask(text string, vars map[string][]string) (string, string) {
+	sig := text
+	ts := ""
 	for _, rule := range n.rules {
 		if !rule.re.MatchString(sig) {
 			continue
@@ -65,17 +96,12 @@ func (n *Normalizer) Normalize(line string, src Source) Record {
 			return rule.replace
 		})
 	}
+	return sig, ts
+}
 
-	record := Record{
-		Raw:  raw,
-		Sig:  sig,
-		TS:   ts,
-		Vars: vars,
-		Src:  src,
-	}
-
-	if n.bucket > 0 && ts != "" {
-		if parsed, err := parseTime(ts); err == nil {
+func (n *Normalizer) finish(record Record) Record {
+	if n.bucket > 0 && record.TS != "" {
+		if parsed, err := parseTime(record.TS); err == nil {
 			record.Bucket = parsed.Truncate(n.bucket).Format(time.RFC3339)
 		}
 	}
@@ -85,12 +111,23 @@ func (n *Normalizer) Normalize(line string, src Source) Record {
 	return record
 }
 
+func ParseTime(value string) (time.Time, error) {
+	return parseTime(value)
+}
+
 func parseTime(value string) (time.Time, error) {
+	// A fraction after the seconds, with a dot or comma, parses with any of
+	// these.
 	layouts := []string{
 		time.RFC3339Nano,
 		time.RFC3339,
 		"2006-01-02 15:04:05",
 		"2006-01-02 15:04:05.000",
+		"2006-01-02T15:04:05",
+		"2006-01-02 15:04:05 MST",
+		"02/Jan/2006:15:04:05 -0700",
+		"2006/01/02 15:04:05",
+		"2 Jan 2006 15:04:05",
 	}
 	for _, layout := range layouts {
 		if parsed, err := time.Parse(layout, value); err == nil {
@@ -99,3 +136,33 @@ func parseTime(value string) (time.Time, error) {
 	}
 	return time.Time{}, fmt.Errorf("unsupported time: %s", value)
 }
+
+// Epochs outside these years are more likely counters or IDs than times.
+var (
+	epochMin = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	epochMax = time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC)
+)
+
+// parseEpoch reads the Unix epochs in seconds, ms, µs or ns that structured
+// logs often carry in their time field. It is not part of parseTime, where a
+// bare number masked as a timestamp would become a date in 1970.
+func parseEpoch(value string) (time.Time, bool) {
+	epoch, err := strconv.ParseFloat(value, 64)
+	if err != nil || epoch <= 0 {
+		return time.Time{}, false
+	}
+	scale := float64(time.Second)
+	switch {
+	case epoch >= 1e17:
+		scale = 1
+	case epoch >= 1e14:
+		scale = float64(time.Microsecond)
+	case epoch >= 1e11:
+		scale = float64(time.Millisecond)
+	}
+	at := time.Unix(0, int64(epoch*scale)).UTC()
+	if at.Before(epochMin) || !at.Before(epochMax) {
+		return time.Time{}, false
+	}
+	return at, true
+}
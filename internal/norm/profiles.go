@@ -3,6 +3,7 @@ package norm
 import (
 	"embed"
 	"fmt"
+	"sort"
 )
 
 //go:embed profiles/*.yaml
@@ -11,6 +12,22 @@ var profilesFS embed.FS
 var profileFiles = map[string]string{
 	"postgres": "profiles/postgres.yaml",
 	"kernel":   "profiles/kernel.yaml",
+	"nginx":    "profiles/nginx.yaml",
+	"mysql":    "profiles/mysql.yaml",
+	"redis":    "profiles/redis.yaml",
+	"java":     "profiles/java.yaml",
+	"k8s":      "profiles/k8s.yaml",
+	"syslog":   "profiles/syslog.yaml",
+}
+
+// Profiles returns the names of the embedded profiles, sorted.
+func Profiles() []string {
+	names := make([]string, 0, len(profileFiles))
+	for name := range profileFiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
 }
 
 func loadProfile(profile string) (*RuleFile, error) {
@@ -0,0 +1,51 @@
+package norm
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files under testdata")
+
+// TestProfileGolden normalizes testdata/<profile>.log and compares the
+// events with testdata/<profile>.golden; run with -update after changing a
+// profile and review the diff.
+func TestProfileGolden(t *testing.T) {
+	for _, profile := range []string{"nginx", "mysql", "redis", "java", "k8s", "syslog"} {
+		t.Run(profile, func(t *testing.T) {
+			n, err := New(profile, "", "")
+			if err != nil {
+				t.Fatalf("new normalizer: %v", err)
+			}
+			var (
+				b      strings.Builder
+				events = assemble(n.Assembler(), loadSample(t, profile+".log"))
+			)
+			for _, ev := range events {
+				rec := n.Normalize(ev.Text, Source{Line: ev.Line})
+				if _, err := ParseTime(rec.TS); err != nil {
+					t.Errorf("line %d: ts %q: %v", ev.Line, rec.TS, err)
+				}
+				fmt.Fprintf(&b, "%d\t%s\t%s\n", rec.Src.Line, rec.TS, strings.ReplaceAll(rec.Sig, "\n", `\n`))
+			}
+
+			path := filepath.Join("testdata", profile+".golden")
+			if *update {
+				if err := os.WriteFile(path, []byte(b.String()), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("read golden: %v", err)
+			}
+			if got := b.String(); got != string(want) {
+				t.Fatalf("%s mismatch:\n--- got\n%s--- want\n%s", path, got, want)
+			}
+		})
+	}
+}
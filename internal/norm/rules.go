@@ -51,8 +51,11 @@ func LoadRuleFile(path string) (RuleFile, error) {
 	return rf, nil
 }
 
-func buildRules(profile string, extra *RuleFile) ([]Rule, map[string]struct{}, error) {
-	var base []Rule
+func buildRules(profile string, extra *RuleFile) ([]Rule, map[string]struct{}, *Multiline, error) {
+	var (
+		base      []Rule
+		multiline *Multiline
+	)
 	preserve := map[string]struct{}{}
 	switch profile {
 	case "", "generic":
@@ -60,9 +63,10 @@ func buildRules(profile string, extra *RuleFile) ([]Rule, map[string]struct{}, e
 	default:
 		profileRules, err := loadProfile(profile)
 		if err != nil {
-			return nil, nil, err
+			return nil, nil, nil, err
 		}
 		base = append(base, profileRules.Rules...)
+		multiline = profileRules.Multiline
 		for _, item := range profileRules.Preserve {
 			if item != "" {
 				preserve[item] = struct{}{}
@@ -77,8 +81,11 @@ func buildRules(profile string, extra *RuleFile) ([]Rule, map[string]struct{}, e
 			}
 		}
 		base = append(extra.Rules, base...)
+		if extra.Multiline != nil {
+			multiline = extra.Multiline
+		}
 	}
-	return base, preserve, nil
+	return base, preserve, multiline, nil
 }
 
 func compileRules(rules []Rule) ([]compiledRule, error) {
@@ -0,0 +1,233 @@
+package norm
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// InputOptions select how lines are parsed before normalization. Field
+// lists are tried in order; the first present field wins.
+type InputOptions struct {
+	Format      string
+	TSFields    []string
+	LevelFields []string
+	MsgFields   []string
+	SigFields   []string
+}
+
+var (
+	defaultTSFields    = []string{"ts", "time", "timestamp", "@timestamp", "t"}
+	defaultLevelFields = []string{"level", "lvl", "severity", "loglevel"}
+	defaultMsgFields   = []string{"msg", "message", "@message", "log"}
+)
+
+// SetInput makes Normalize parse json or logfmt lines into fields. Lines
+// that do not parse are normalized as plain text.
+func (n *Normalizer) SetInput(opts InputOptions) error {
+	for _, e := range n.mix {
+		if err := e.n.SetInput(opts); err != nil {
+			return err
+		}
+	}
+	switch opts.Format {
+	case "", "text":
+		n.input = nil
+		return nil
+	case "json", "logfmt", "auto":
+	default:
+		return fmt.Errorf("unknown input: %s", opts.Format)
+	}
+	if len(opts.TSFields) == 0 {
+		opts.TSFields = defaultTSFields
+	}
+	if len(opts.LevelFields) == 0 {
+		opts.LevelFields = defaultLevelFields
+	}
+	if len(opts.MsgFields) == 0 {
+		opts.MsgFields = defaultMsgFields
+	}
+	n.input = &opts
+	return nil
+}
+
+func (o *InputOptions) parse(line string) (map[string]string, bool) {
+	trimmed := strings.TrimSpace(line)
+	switch o.Format {
+	case "json":
+		return parseJSONFields(trimmed)
+	case "logfmt":
+		return parseLogfmt(trimmed, 1)
+	}
+	if strings.HasPrefix(trimmed, "{") {
+		return parseJSONFields(trimmed)
+	}
+	// A single key=value pair is too common in plain text to count.
+	return parseLogfmt(trimmed, 2)
+}
+
+// normalizeFields builds the record of a structured line: the sig is the
+// level, the masked message and the selected static fields; everything but
+// the timestamp, level and message is kept in Fields.
+func (n *Normalizer) normalizeFields(raw string, fields map[string]string, src Source) Record {
+	ts := takeField(fields, n.input.TSFields)
+	fieldTS := ts
+	level := takeField(fields, n.input.LevelFields)
+	msg := takeField(fields, n.input.MsgFields)
+
+	vars := map[string][]string{}
+	var parts []string
+	if level != "" {
+		parts = append(parts, level)
+	}
+	if msg != "" {
+		sig, msgTS := n.mask(msg, vars)
+		if ts == "" {
+			ts = msgTS
+		}
+		parts = append(parts, sig)
+	}
+	static := map[string]bool{}
+	for _, key := range n.input.SigFields {
+		if v, ok := fields[key]; ok {
+			parts = append(parts, key+"="+v)
+			static[key] = true
+		}
+	}
+	if msg == "" {
+		// Without a message the shape of the line is its remaining keys.
+		for _, key := range sortedKeys(fields) {
+			if !static[key] {
+				sig, _ := n.mask(fields[key], vars)
+				parts = append(parts, key+"="+sig)
+			}
+		}
+	}
+	if len(fields) == 0 {
+		fields = nil
+	}
+	record := n.finish(Record{
+		Raw:    raw,
+		Sig:    strings.Join(parts, " "),
+		TS:     ts,
+		Level:  level,
+		Vars:   vars,
+		Fields: fields,
+		Src:    src,
+	})
+	if at, ok := parseEpoch(fieldTS); ok && n.bucket > 0 {
+		record.Bucket = at.Truncate(n.bucket).Format(time.RFC3339)
+	}
+	return record
+}
+
+func takeField(fields map[string]string, keys []string) string {
+	for _, key := range keys {
+		if v, ok := fields[key]; ok {
+			delete(fields, key)
+			return v
+		}
+	}
+	return ""
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// parseJSONFields flattens a JSON object; nested objects become dotted keys
+// and arrays stay as compact JSON.
+func parseJSONFields(line string) (map[string]string, bool) {
+	if !strings.HasPrefix(line, "{") {
+		return nil, false
+	}
+	dec := json.NewDecoder(strings.NewReader(line))
+	dec.UseNumber()
+	var obj map[string]any
+	if err := dec.Decode(&obj); err != nil {
+		return nil, false
+	}
+	fields := map[string]string{}
+	flattenJSON(fields, "", obj)
+	return fields, true
+}
+
+func flattenJSON(fields map[string]string, prefix string, obj map[string]any) {
+	for key, v := range obj {
+		switch v := v.(type) {
+		case map[string]any:
+			flattenJSON(fields, prefix+key+".", v)
+		case string:
+			fields[prefix+key] = v
+		case nil:
+			fields[prefix+key] = ""
+		case []any:
+			var buf bytes.Buffer
+			enc := json.NewEncoder(&buf)
+			enc.SetEscapeHTML(false)
+			_ = enc.Encode(v)
+			fields[prefix+key] = strings.TrimSpace(buf.String())
+		default:
+			fields[prefix+key] = fmt.Sprint(v)
+		}
+	}
+}
+
+// parseLogfmt parses key=value pairs with optionally quoted values. It fails
+// on anything else, so plain text falls through to the rules.
+func parseLogfmt(line string, minPairs int) (map[string]string, bool) {
+	fields := map[string]string{}
+	for i := 0; i < len(line); {
+		if line[i] == ' ' || line[i] == '\t' {
+			i++
+			continue
+		}
+		eq := i
+		for eq < len(line) && line[eq] != '=' && line[eq] != ' ' && line[eq] != '"' {
+			eq++
+		}
+		if eq == i || eq == len(line) || line[eq] != '=' {
+			return nil, false
+		}
+		key := line[i:eq]
+		i = eq + 1
+		if i < len(line) && line[i] == '"' {
+			end := i + 1
+			for end < len(line) && line[end] != '"' {
+				if line[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(line) {
+				return nil, false
+			}
+			value, err := strconv.Unquote(line[i : end+1])
+			if err != nil {
+				return nil, false
+			}
+			fields[key] = value
+			i = end + 1
+			continue
+		}
+		end := i
+		for end < len(line) && line[end] != ' ' && line[end] != '\t' {
+			end++
+		}
+		fields[key] = line[i:end]
+		i = end
+	}
+	if len(fields) < minPairs {
+		return nil, false
+	}
+	return fields, true
+}
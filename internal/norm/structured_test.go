@@ -0,0 +1,93 @@
+package norm
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeJSON(t *testing.T) {
+	n, err := New("generic", "", "1h")
+	if err != nil {
+		t.Fatalf("new normalizer: %v", err)
+	}
+	if err := n.SetInput(InputOptions{Format: "json", SigFields: []string{"service"}}); err != nil {
+		t.Fatalf("set input: %v", err)
+	}
+	line := `{"time":"2024-01-02T03:04:05Z","level":"error","msg":"dial 10.0.0.1 failed after 3 tries","service":"api","http":{"status2xx":0,"path":"/v1/x"},"tags":["a","b"]}`
+	rec := n.Normalize(line, Source{Line: 7})
+
+	if rec.Sig != "error dial <ip> failed after <number> tries service=api" {
+		t.Fatalf("sig = %q", rec.Sig)
+	}
+	if rec.TS != "2024-01-02T03:04:05Z" || rec.Bucket != "2024-01-02T03:00:00Z" || rec.Level != "error" {
+		t.Fatalf("ts/bucket/level = %q %q %q", rec.TS, rec.Bucket, rec.Level)
+	}
+	want := map[string]string{"service": "api", "http.status2xx": "0", "http.path": "/v1/x", "tags": `["a","b"]`}
+	if !reflect.DeepEqual(rec.Fields, want) {
+		t.Fatalf("fields = %v", rec.Fields)
+	}
+	if v, _ := rec.Field("fields.http.path"); v != "/v1/x" {
+		t.Fatalf("Field(fields.http.path) = %q", v)
+	}
+	if rec.Raw != line || rec.Src.Line != 7 {
+		t.Fatalf("raw/src = %q %+v", rec.Raw, rec.Src)
+	}
+}
+
+func TestNormalizeLogfmtAndAuto(t *testing.T) {
+	n, err := New("generic", "", "")
+	if err != nil {
+		t.Fatalf("new normalizer: %v", err)
+	}
+	if err := n.SetInput(InputOptions{Format: "auto", MsgFields: []string{"event"}}); err != nil {
+		t.Fatalf("set input: %v", err)
+	}
+
+	rec := n.Normalize(`ts=1704164645 lvl=warn event="cache miss for user 42" shard=s1`, Source{})
+	if rec.Sig != "warn cache miss for user <number>" || rec.Fields["shard"] != "s1" || rec.TS != "1704164645" {
+		t.Fatalf("logfmt record = %+v", rec)
+	}
+
+	rec = n.Normalize(`{"status":503,"path":"/api/7"}`, Source{})
+	if rec.Sig != "path=<path> status=<number>" || rec.Vars["number"][0] != "503" {
+		t.Fatalf("message-less record = %+v", rec)
+	}
+
+	rec = n.Normalize("retry=3 failed for job 12", Source{})
+	if rec.Fields != nil || rec.Sig != "retry=<number> failed for job <number>" {
+		t.Fatalf("plain text record = %+v", rec)
+	}
+
+	if err := n.SetInput(InputOptions{Format: "xml"}); err == nil {
+		t.Fatal("expected unknown input error")
+	}
+}
+
+func TestStructuredEpochTime(t *testing.T) {
+	n, err := New("generic", "", "1h")
+	if err != nil {
+		t.Fatalf("new normalizer: %v", err)
+	}
+	if err := n.SetInput(InputOptions{Format: "auto"}); err != nil {
+		t.Fatalf("set input: %v", err)
+	}
+	for _, line := range []string{
+		`{"ts":1704164645,"msg":"up"}`,
+		`{"ts":1704164645000,"msg":"up"}`,
+		`ts=1704164645.0 msg=up`,
+	} {
+		if rec := n.Normalize(line, Source{}); rec.Bucket != "2024-01-02T03:00:00Z" {
+			t.Fatalf("%s: bucket = %q", line, rec.Bucket)
+		}
+	}
+	for _, line := range []string{`{"ts":42,"msg":"up"}`, `ts=99999999999999999999 msg=up`, `{"msg":"retry 1704164645"}`} {
+		if rec := n.Normalize(line, Source{}); rec.Bucket != "" {
+			t.Fatalf("%s: implausible epoch bucketed as %q", line, rec.Bucket)
+		}
+	}
+	for _, value := range []string{"42", "1704164645"} {
+		if got, err := ParseTime(value); err == nil {
+			t.Fatalf("ParseTime(%q) = %v, want an error", value, got)
+		}
+	}
+}
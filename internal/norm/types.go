@@ -7,12 +7,15 @@ type Source struct {
 }
 
 type Record struct {
-	Raw    string              `json:"raw"`
-	Sig    string              `json:"sig"`
-	TS     string              `json:"ts,omitempty"`
-	Bucket string              `json:"bucket,omitempty"`
-	Vars   map[string][]string `json:"vars,omitempty"`
-	Src    Source              `json:"src,omitempty"`
+	Raw        string              `json:"raw"`
+	Sig        string              `json:"sig"`
+	TemplateID string              `json:"template_id,omitempty"`
+	TS         string              `json:"ts,omitempty"`
+	Level      string              `json:"level,omitempty"`
+	Bucket     string              `json:"bucket,omitempty"`
+	Vars       map[string][]string `json:"vars,omitempty"`
+	Fields     map[string]string   `json:"fields,omitempty"`
+	Src        Source              `json:"src,omitempty"`
 }
 
 type Rule struct {
@@ -24,8 +27,25 @@ type Rule struct {
 }
 
 type RuleFile struct {
-	Version     int      `yaml:"version"`
-	Description string   `yaml:"description,omitempty"`
-	Rules       []Rule   `yaml:"rules"`
-	Preserve    []string `yaml:"preserve"`
+	Version     int        `yaml:"version"`
+	Description string     `yaml:"description,omitempty"`
+	Rules       []Rule     `yaml:"rules"`
+	Preserve    []string   `yaml:"preserve"`
+	Multiline   *Multiline `yaml:"multiline,omitempty"`
+}
+
+// Multiline groups physical lines into one event. A line continues the
+// current event when it matches Continuation or, if Start is set, when it
+// does not match Start.
+//
+// Partial matches a line its writer split for length, such as a CRI "P"
+// record: the next line is appended to it without a newline and without the
+// prefix PartialHeader matches, until a line that is not partial.
+type Multiline struct {
+	Start         string `yaml:"start,omitempty"`
+	Continuation  string `yaml:"continuation,omitempty"`
+	Partial       string `yaml:"partial,omitempty"`
+	PartialHeader string `yaml:"partial_header,omitempty"`
+	MaxLines      int    `yaml:"max_lines,omitempty"`
+	MaxBytes      int    `yaml:"max_bytes,omitempty"`
 }
@@ -0,0 +1,116 @@
+package reduce
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+
+	"github.com/yjhatfdu/aip/internal/norm"
+	"github.com/yjhatfdu/aip/internal/sampling"
+)
+
+type Group struct {
+	Key     map[string]string `json:"key"`
+	Count   int               `json:"count"`
+	FirstTS string            `json:"first_ts,omitempty"`
+	LastTS  string            `json:"last_ts,omitempty"`
+	Samples []sampling.Item   `json:"samples,omitempty"`
+}
+
+type groupAgg struct {
+	values    []string
+	count     int
+	firstTS   string
+	lastTS    string
+	reservoir *sampling.Reservoir
+}
+
+type Reducer struct {
+	by      []string
+	samples int
+	rng     *rand.Rand
+	groups  map[string]*groupAgg
+}
+
+func New(by []string, samples int, seed int64) (*Reducer, error) {
+	if len(by) == 0 {
+		return nil, fmt.Errorf("at least one key is required")
+	}
+	for _, key := range by {
+		if _, ok := (norm.Record{}).Field(key); !ok {
+			return nil, fmt.Errorf("unknown key: %s", key)
+		}
+	}
+	return &Reducer{
+		by:      by,
+		samples: samples,
+		rng:     rand.New(rand.NewSource(seed)),
+		groups:  map[string]*groupAgg{},
+	}, nil
+}
+
+func (r *Reducer) Add(rec norm.Record) {
+	values := make([]string, len(r.by))
+	for i, key := range r.by {
+		values[i], _ = rec.Field(key)
+	}
+	id := strings.Join(values, "\x00")
+	g, ok := r.groups[id]
+	if !ok {
+		g = &groupAgg{values: values, reservoir: sampling.NewReservoir(r.samples, r.rng)}
+		r.groups[id] = g
+	}
+	g.count++
+	if rec.TS != "" {
+		if g.firstTS == "" || rec.TS < g.firstTS {
+			g.firstTS = rec.TS
+		}
+		if g.lastTS == "" || rec.TS > g.lastTS {
+			g.lastTS = rec.TS
+		}
+	}
+	g.reservoir.Add(sampling.Item{TS: rec.TS, Raw: rec.Raw, File: rec.Src.File, Line: rec.Src.Line})
+}
+
+func (r *Reducer) Keys() []string {
+	return append([]string(nil), r.by...)
+}
+
+func (r *Reducer) Len() int {
+	return len(r.groups)
+}
+
+// Top returns the k largest groups by count; k <= 0 returns all of them.
+func (r *Reducer) Top(k int) []Group {
+	aggs := make([]*groupAgg, 0, len(r.groups))
+	for _, g := range r.groups {
+		aggs = append(aggs, g)
+	}
+	sort.Slice(aggs, func(i, j int) bool {
+		if aggs[i].count == aggs[j].count {
+			return strings.Join(aggs[i].values, "\x00") < strings.Join(aggs[j].values, "\x00")
+		}
+		return aggs[i].count > aggs[j].count
+	})
+	if k > 0 && len(aggs) > k {
+		aggs = aggs[:k]
+	}
+	out := make([]Group, 0, len(aggs))
+	for _, g := range aggs {
+		key := make(map[string]string, len(r.by))
+		for i, name := range r.by {
+			key[name] = g.values[i]
+		}
+		samples := g.reservoir.Items()
+		sampling.SortByPosition(samples)
+		out = append(out, Group{
+			Key:     key,
+			Count:   g.count,
+			FirstTS: g.firstTS,
+			LastTS:  g.lastTS,
+			Samples: samples,
+		})
+	}
+	return out
+}
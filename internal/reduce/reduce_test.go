@@ -0,0 +1,46 @@
+package reduce
+
+import (
+	"testing"
+
+	"github.com/yjhatfdu/aip/internal/norm"
+)
+
+func TestReducerGroupsAndRanks(t *testing.T) {
+	r, err := New([]string{"sig", "vars.user"}, 2, 1)
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+	records := []norm.Record{
+		{Sig: "login <user>", Raw: "login bob", TS: "2024-01-01T00:00:02Z", Vars: map[string][]string{"user": {"bob"}}, Src: norm.Source{Line: 1}},
+		{Sig: "login <user>", Raw: "login bob", TS: "2024-01-01T00:00:01Z", Vars: map[string][]string{"user": {"bob"}}, Src: norm.Source{Line: 2}},
+		{Sig: "login <user>", Raw: "login bob", TS: "2024-01-01T00:00:03Z", Vars: map[string][]string{"user": {"bob"}}, Src: norm.Source{Line: 3}},
+		{Sig: "login <user>", Raw: "login amy", Vars: map[string][]string{"user": {"amy"}}, Src: norm.Source{Line: 4}},
+	}
+	for _, rec := range records {
+		r.Add(rec)
+	}
+	groups := r.Top(0)
+	if len(groups) != 2 {
+		t.Fatalf("expected 2 groups, got %d", len(groups))
+	}
+	g := groups[0]
+	if g.Key["vars.user"] != "bob" || g.Count != 3 {
+		t.Fatalf("unexpected top group: %+v", g)
+	}
+	if g.FirstTS != "2024-01-01T00:00:01Z" || g.LastTS != "2024-01-01T00:00:03Z" {
+		t.Fatalf("unexpected time range: %+v", g)
+	}
+	if len(g.Samples) != 2 {
+		t.Fatalf("expected 2 samples, got %d", len(g.Samples))
+	}
+	if top := r.Top(1); len(top) != 1 {
+		t.Fatalf("Top(1) returned %d groups", len(top))
+	}
+}
+
+func TestNewRejectsUnknownKey(t *testing.T) {
+	if _, err := New([]string{"nope"}, 0, 1); err == nil {
+		t.Fatal("expected error for unknown key")
+	}
+}
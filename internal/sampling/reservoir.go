@@ -0,0 +1,45 @@
+package sampling
+
+import "math/rand"
+
+type Item struct {
+	TS   string `json:"ts,omitempty"`
+	Raw  string `json:"raw"`
+	File string `json:"file,omitempty"`
+	Line int    `json:"line,omitempty"`
+}
+
+// Reservoir keeps a uniform sample of up to n items from a stream of unknown
+// length (Algorithm R).
+type Reservoir struct {
+	n     int
+	seen  int
+	items []Item
+	rng   *rand.Rand
+}
+
+func NewReservoir(n int, rng *rand.Rand) *Reservoir {
+	return &Reservoir{n: n, rng: rng}
+}
+
+func (r *Reservoir) Add(item Item) {
+	r.seen++
+	if r.n <= 0 {
+		return
+	}
+	if len(r.items) < r.n {
+		r.items = append(r.items, item)
+		return
+	}
+	if j := r.rng.Intn(r.seen); j < r.n {
+		r.items[j] = item
+	}
+}
+
+func (r *Reservoir) Seen() int {
+	return r.seen
+}
+
+func (r *Reservoir) Items() []Item {
+	return append([]Item(nil), r.items...)
+}
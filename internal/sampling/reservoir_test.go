@@ -0,0 +1,37 @@
+package sampling
+
+import (
+	"math/rand"
+	"strconv"
+	"testing"
+)
+
+func TestReservoirKeepsAtMostN(t *testing.T) {
+	r := NewReservoir(3, rand.New(rand.NewSource(1)))
+	for i := 0; i < 100; i++ {
+		r.Add(Item{Raw: strconv.Itoa(i)})
+	}
+	if r.Seen() != 100 {
+		t.Fatalf("seen = %d", r.Seen())
+	}
+	if got := len(r.Items()); got != 3 {
+		t.Fatalf("items = %d", got)
+	}
+}
+
+func TestReservoirUniform(t *testing.T) {
+	rng := rand.New(rand.NewSource(7))
+	hits := make([]int, 10)
+	for round := 0; round < 2000; round++ {
+		r := NewReservoir(1, rng)
+		for i := 0; i < 10; i++ {
+			r.Add(Item{Line: i})
+		}
+		hits[r.Items()[0].Line]++
+	}
+	for i, h := range hits {
+		if h < 120 || h > 280 {
+			t.Fatalf("item %d picked %d times out of 2000", i, h)
+		}
+	}
+}
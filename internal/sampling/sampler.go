@@ -0,0 +1,197 @@
+package sampling
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+const (
+	MethodReservoir = "reservoir"
+	MethodTime      = "time"
+)
+
+// Time-stratified sampling draws from a larger reservoir so that the final
+// picks can be spread across the group's time range.
+const timeOversample = 10
+
+type Group struct {
+	Sig     string `json:"sig"`
+	Count   int    `json:"count"`
+	Matched int    `json:"matched,omitempty"`
+	Samples []Item `json:"samples"`
+}
+
+type groupState struct {
+	sig       string
+	count     int
+	reservoir *Reservoir
+}
+
+type Sampler struct {
+	per       int
+	method    string
+	rng       *rand.Rand
+	parseTime func(string) (time.Time, error)
+	groups    map[string]*groupState
+	order     []string
+}
+
+func NewSampler(per int, method string, seed int64, parseTime func(string) (time.Time, error)) (*Sampler, error) {
+	if method == "" {
+		method = MethodReservoir
+	}
+	if method != MethodReservoir && method != MethodTime {
+		return nil, fmt.Errorf("unknown sampling method: %s", method)
+	}
+	return &Sampler{
+		per:       per,
+		method:    method,
+		rng:       rand.New(rand.NewSource(seed)),
+		parseTime: parseTime,
+		groups:    map[string]*groupState{},
+	}, nil
+}
+
+func (s *Sampler) Add(sig string, item Item) {
+	g, ok := s.groups[sig]
+	if !ok {
+		size := s.per
+		if s.method == MethodTime {
+			size = s.per * timeOversample
+		}
+		g = &groupState{sig: sig, reservoir: NewReservoir(size, s.rng)}
+		s.groups[sig] = g
+		s.order = append(s.order, sig)
+	}
+	g.count++
+	g.reservoir.Add(item)
+}
+
+func (s *Sampler) Group(sig string) Group {
+	g, ok := s.groups[sig]
+	if !ok {
+		return Group{Sig: sig}
+	}
+	items := g.reservoir.Items()
+	if s.method == MethodTime {
+		items = Stratify(items, s.per, s.parseTime)
+	} else {
+		SortByPosition(items)
+	}
+	return Group{Sig: g.sig, Count: g.count, Samples: items}
+}
+
+// Top returns the k groups with the most items; k <= 0 returns all of them.
+func (s *Sampler) Top(k int) []Group {
+	sigs := append([]string(nil), s.order...)
+	sort.SliceStable(sigs, func(i, j int) bool {
+		return s.groups[sigs[i]].count > s.groups[sigs[j]].count
+	})
+	if k > 0 && len(sigs) > k {
+		sigs = sigs[:k]
+	}
+	out := make([]Group, 0, len(sigs))
+	for _, sig := range sigs {
+		out = append(out, s.Group(sig))
+	}
+	return out
+}
+
+// Stratify picks up to n items spread evenly over the items' time range.
+// Items whose timestamps cannot be parsed are ordered by source position and
+// split into equal-sized strata instead.
+func Stratify(items []Item, n int, parseTime func(string) (time.Time, error)) []Item {
+	if n <= 0 {
+		return nil
+	}
+	if len(items) <= n {
+		out := append([]Item(nil), items...)
+		SortByPosition(out)
+		return out
+	}
+
+	times := make([]time.Time, len(items))
+	timed := parseTime != nil
+	for i, it := range items {
+		if !timed {
+			break
+		}
+		t, err := parseTime(it.TS)
+		if err != nil {
+			timed = false
+			break
+		}
+		times[i] = t
+	}
+
+	idx := make([]int, len(items))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(a, b int) bool {
+		ia, ib := items[idx[a]], items[idx[b]]
+		if timed && !times[idx[a]].Equal(times[idx[b]]) {
+			return times[idx[a]].Before(times[idx[b]])
+		}
+		if ia.File != ib.File {
+			return ia.File < ib.File
+		}
+		return ia.Line < ib.Line
+	})
+
+	strata := make([][]int, n)
+	first, last := times[idx[0]], times[idx[len(idx)-1]]
+	span := last.Sub(first)
+	for rank, i := range idx {
+		bin := rank * n / len(idx)
+		if timed && span > 0 {
+			bin = int(int64(times[i].Sub(first)) * int64(n) / int64(span))
+			if bin >= n {
+				bin = n - 1
+			}
+		}
+		strata[bin] = append(strata[bin], i)
+	}
+
+	picked := make([]Item, 0, n)
+	used := map[int]bool{}
+	for _, stratum := range strata {
+		if len(stratum) == 0 {
+			continue
+		}
+		i := stratum[len(stratum)/2]
+		used[i] = true
+		picked = append(picked, items[i])
+	}
+	// Empty strata leave room for more picks; fill it in time order.
+	for _, i := range idx {
+		if len(picked) >= n {
+			break
+		}
+		if !used[i] {
+			used[i] = true
+			picked = append(picked, items[i])
+		}
+	}
+	if timed {
+		sort.SliceStable(picked, func(a, b int) bool {
+			ta, _ := parseTime(picked[a].TS)
+			tb, _ := parseTime(picked[b].TS)
+			return ta.Before(tb)
+		})
+	} else {
+		SortByPosition(picked)
+	}
+	return picked
+}
+
+func SortByPosition(items []Item) {
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].File != items[j].File {
+			return items[i].File < items[j].File
+		}
+		return items[i].Line < items[j].Line
+	})
+}
@@ -0,0 +1,68 @@
+package sampling
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func parseRFC3339(v string) (time.Time, error) {
+	return time.Parse(time.RFC3339, v)
+}
+
+func TestStratifySpreadsOverTimeRange(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var items []Item
+	// A burst of 90 items in the first minute and 10 spread over the next hours.
+	for i := 0; i < 90; i++ {
+		items = append(items, Item{TS: base.Add(time.Duration(i) * time.Second / 2).Format(time.RFC3339), Line: i})
+	}
+	for i := 0; i < 10; i++ {
+		items = append(items, Item{TS: base.Add(time.Duration(i+1) * 20 * time.Minute).Format(time.RFC3339), Line: 90 + i})
+	}
+	got := Stratify(items, 4, parseRFC3339)
+	if len(got) != 4 {
+		t.Fatalf("expected 4 items, got %d", len(got))
+	}
+	late := 0
+	for _, it := range got {
+		if it.Line >= 90 {
+			late++
+		}
+	}
+	if late < 3 {
+		t.Fatalf("expected picks spread beyond the burst, got %+v", got)
+	}
+}
+
+func TestStratifyWithoutTimestampsUsesPosition(t *testing.T) {
+	var items []Item
+	for i := 0; i < 20; i++ {
+		items = append(items, Item{Raw: fmt.Sprint(i), Line: i})
+	}
+	got := Stratify(items, 4, parseRFC3339)
+	want := []int{2, 7, 12, 17}
+	for i, it := range got {
+		if it.Line != want[i] {
+			t.Fatalf("unexpected picks: %+v", got)
+		}
+	}
+}
+
+func TestSamplerTop(t *testing.T) {
+	s, err := NewSampler(2, MethodReservoir, 1, nil)
+	if err != nil {
+		t.Fatalf("NewSampler error: %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		s.Add("a", Item{Line: i})
+	}
+	s.Add("b", Item{Line: 10})
+	top := s.Top(1)
+	if len(top) != 1 || top[0].Sig != "a" || top[0].Count != 5 || len(top[0].Samples) != 2 {
+		t.Fatalf("unexpected top: %+v", top)
+	}
+	if _, err := NewSampler(1, "bogus", 1, nil); err == nil {
+		t.Fatal("expected error for unknown method")
+	}
+}
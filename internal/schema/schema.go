@@ -0,0 +1,404 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Schema is the subset of JSON Schema that structured output relies on:
+// type, properties, required, additionalProperties, items, enum, const,
+// length, count and range limits, pattern, anyOf/oneOf/allOf and local $ref.
+type Schema struct {
+	Raw json.RawMessage
+	doc map[string]any
+}
+
+func Load(path string) (*Schema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return s, nil
+}
+
+func Parse(data []byte) (*Schema, error) {
+	var doc map[string]any
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, data); err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	if err := checkPatterns(doc, ""); err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	return &Schema{Raw: compact.Bytes(), doc: doc}, nil
+}
+
+// checkPatterns compiles every pattern keyword so a broken regex is reported
+// up front instead of letting every value pass. Literal values under enum,
+// const, default and examples are not schemas and are skipped.
+func checkPatterns(node any, path string) error {
+	switch node := node.(type) {
+	case map[string]any:
+		if pattern, ok := node["pattern"].(string); ok {
+			if _, err := regexp.Compile(pattern); err != nil {
+				return fmt.Errorf("%s/pattern: %w", path, err)
+			}
+		}
+		for _, key := range sortedKeys(node) {
+			switch key {
+			case "enum", "const", "default", "examples":
+				continue
+			}
+			if err := checkPatterns(node[key], path+"/"+escapePointer(key)); err != nil {
+				return err
+			}
+		}
+	case []any:
+		for i, item := range node {
+			if err := checkPatterns(item, fmt.Sprintf("%s/%d", path, i)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Validate checks a JSON document and returns one message per violation,
+// each prefixed with the JSON pointer of the offending value.
+func (s *Schema) Validate(data []byte) []string {
+	var value any
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&value); err != nil {
+		return []string{fmt.Sprintf("invalid JSON: %v", err)}
+	}
+	if dec.More() {
+		return []string{"invalid JSON: trailing data after the value"}
+	}
+	v := validator{root: s.doc}
+	v.check(s.doc, value, "")
+	return v.errs
+}
+
+type validator struct {
+	root map[string]any
+	errs []string
+}
+
+func (v *validator) fail(path, format string, args ...any) {
+	if path == "" {
+		path = "/"
+	}
+	v.errs = append(v.errs, path+": "+fmt.Sprintf(format, args...))
+}
+
+func (v *validator) check(schema map[string]any, value any, path string) {
+	if ref, ok := schema["$ref"].(string); ok {
+		target, err := v.resolve(ref)
+		if err != nil {
+			v.fail(path, "%v", err)
+			return
+		}
+		schema = target
+	}
+	if types, ok := schemaTypes(schema["type"]); ok && !matchesAny(types, value) {
+		v.fail(path, "expected %s, got %s", strings.Join(types, " or "), typeOf(value))
+		return
+	}
+	if enum, ok := schema["enum"].([]any); ok {
+		found := false
+		for _, candidate := range enum {
+			if equal(candidate, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			v.fail(path, "value %s is not one of the allowed values", short(value))
+		}
+	}
+	if c, ok := schema["const"]; ok && !equal(c, value) {
+		v.fail(path, "value %s must equal %s", short(value), short(c))
+	}
+	v.checkComposition(schema, value, path)
+
+	switch val := value.(type) {
+	case map[string]any:
+		v.checkObject(schema, val, path)
+	case []any:
+		v.checkArray(schema, val, path)
+	case string:
+		n := utf8.RuneCountInString(val)
+		if limit, ok := number(schema["minLength"]); ok && float64(n) < limit {
+			v.fail(path, "string shorter than %v characters", limit)
+		}
+		if limit, ok := number(schema["maxLength"]); ok && float64(n) > limit {
+			v.fail(path, "string longer than %v characters", limit)
+		}
+		if pattern, ok := schema["pattern"].(string); ok {
+			re, err := regexp.Compile(pattern)
+			switch {
+			case err != nil:
+				v.fail(path, "invalid pattern %q in schema: %v", pattern, err)
+			case !re.MatchString(val):
+				v.fail(path, "string does not match pattern %q", pattern)
+			}
+		}
+	case json.Number:
+		f, _ := val.Float64()
+		if limit, ok := number(schema["minimum"]); ok && f < limit {
+			v.fail(path, "%v is less than minimum %v", val, limit)
+		}
+		if limit, ok := number(schema["maximum"]); ok && f > limit {
+			v.fail(path, "%v is greater than maximum %v", val, limit)
+		}
+		if limit, ok := number(schema["exclusiveMinimum"]); ok && f <= limit {
+			v.fail(path, "%v must be greater than %v", val, limit)
+		}
+		if limit, ok := number(schema["exclusiveMaximum"]); ok && f >= limit {
+			v.fail(path, "%v must be less than %v", val, limit)
+		}
+	}
+}
+
+func (v *validator) checkObject(schema map[string]any, obj map[string]any, path string) {
+	if required, ok := schema["required"].([]any); ok {
+		for _, name := range required {
+			if key, ok := name.(string); ok {
+				if _, present := obj[key]; !present {
+					v.fail(path, "missing required property %q", key)
+				}
+			}
+		}
+	}
+	props, _ := schema["properties"].(map[string]any)
+	for _, key := range sortedKeys(obj) {
+		child := path + "/" + escapePointer(key)
+		if sub, ok := props[key].(map[string]any); ok {
+			v.check(sub, obj[key], child)
+			continue
+		}
+		switch extra := schema["additionalProperties"].(type) {
+		case bool:
+			if !extra {
+				v.fail(path, "unexpected property %q", key)
+			}
+		case map[string]any:
+			v.check(extra, obj[key], child)
+		}
+	}
+	if limit, ok := number(schema["minProperties"]); ok && float64(len(obj)) < limit {
+		v.fail(path, "object has fewer than %v properties", limit)
+	}
+	if limit, ok := number(schema["maxProperties"]); ok && float64(len(obj)) > limit {
+		v.fail(path, "object has more than %v properties", limit)
+	}
+}
+
+func (v *validator) checkArray(schema map[string]any, arr []any, path string) {
+	if items, ok := schema["items"].(map[string]any); ok {
+		for i, item := range arr {
+			v.check(items, item, fmt.Sprintf("%s/%d", path, i))
+		}
+	}
+	if limit, ok := number(schema["minItems"]); ok && float64(len(arr)) < limit {
+		v.fail(path, "array has fewer than %v items", limit)
+	}
+	if limit, ok := number(schema["maxItems"]); ok && float64(len(arr)) > limit {
+		v.fail(path, "array has more than %v items", limit)
+	}
+	if unique, _ := schema["uniqueItems"].(bool); unique {
+		for i := range arr {
+			for j := i + 1; j < len(arr); j++ {
+				if equal(arr[i], arr[j]) {
+					v.fail(path, "items %d and %d are equal", i, j)
+				}
+			}
+		}
+	}
+}
+
+func (v *validator) checkComposition(schema map[string]any, value any, path string) {
+	if all, ok := schema["allOf"].([]any); ok {
+		for _, sub := range all {
+			if s, ok := sub.(map[string]any); ok {
+				v.check(s, value, path)
+			}
+		}
+	}
+	for _, keyword := range []string{"anyOf", "oneOf"} {
+		options, ok := schema[keyword].([]any)
+		if !ok {
+			continue
+		}
+		matched := 0
+		for _, sub := range options {
+			s, ok := sub.(map[string]any)
+			if !ok {
+				continue
+			}
+			trial := validator{root: v.root}
+			trial.check(s, value, path)
+			if len(trial.errs) == 0 {
+				matched++
+			}
+		}
+		if matched == 0 || (keyword == "oneOf" && matched > 1) {
+			v.fail(path, "value does not match %s", keyword)
+		}
+	}
+}
+
+// resolve follows local references such as #/$defs/item.
+func (v *validator) resolve(ref string) (map[string]any, error) {
+	if !strings.HasPrefix(ref, "#") {
+		return nil, fmt.Errorf("unsupported $ref %q", ref)
+	}
+	var node any = v.root
+	for _, part := range strings.Split(strings.TrimPrefix(ref, "#"), "/") {
+		if part == "" {
+			continue
+		}
+		part = strings.NewReplacer("~1", "/", "~0", "~").Replace(part)
+		obj, ok := node.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("unresolved $ref %q", ref)
+		}
+		node = obj[part]
+	}
+	target, ok := node.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("unresolved $ref %q", ref)
+	}
+	return target, nil
+}
+
+func schemaTypes(raw any) ([]string, bool) {
+	switch t := raw.(type) {
+	case string:
+		return []string{t}, true
+	case []any:
+		var types []string
+		for _, item := range t {
+			if s, ok := item.(string); ok {
+				types = append(types, s)
+			}
+		}
+		return types, len(types) > 0
+	}
+	return nil, false
+}
+
+func matchesAny(types []string, value any) bool {
+	actual := typeOf(value)
+	for _, t := range types {
+		if t == actual || (t == "number" && actual == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+func typeOf(value any) string {
+	switch val := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	case json.Number:
+		if f, err := val.Float64(); err == nil && f == math.Trunc(f) && !strings.ContainsAny(val.String(), ".eE") {
+			return "integer"
+		}
+		return "number"
+	}
+	return "unknown"
+}
+
+func number(raw any) (float64, bool) {
+	n, ok := raw.(json.Number)
+	if !ok {
+		return 0, false
+	}
+	f, err := n.Float64()
+	return f, err == nil
+}
+
+func equal(a, b any) bool {
+	if na, ok := a.(json.Number); ok {
+		nb, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		fa, errA := na.Float64()
+		fb, errB := nb.Float64()
+		return errA == nil && errB == nil && fa == fb
+	}
+	ja, errA := json.Marshal(a)
+	jb, errB := json.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(ja, jb)
+}
+
+func short(value any) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "?"
+	}
+	if len(data) > 60 {
+		return string(data[:57]) + "..."
+	}
+	return string(data)
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func escapePointer(key string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(key)
+}
+
+// Extract returns the JSON value in a model reply, tolerating surrounding
+// whitespace and a Markdown code fence.
+func Extract(reply string) ([]byte, error) {
+	text := strings.TrimSpace(reply)
+	if strings.HasPrefix(text, "```") {
+		text = strings.TrimPrefix(text, "```")
+		if nl := strings.IndexByte(text, '\n'); nl >= 0 {
+			text = text[nl+1:]
+		}
+		text = strings.TrimSuffix(strings.TrimSpace(text), "```")
+		text = strings.TrimSpace(text)
+	}
+	if text == "" {
+		return nil, errors.New("empty reply")
+	}
+	return []byte(text), nil
+}
@@ -0,0 +1,73 @@
+package schema
+
+import (
+	"strings"
+	"testing"
+)
+
+const reportSchema = `{
+  "type": "object",
+  "required": ["summary", "severity", "items"],
+  "additionalProperties": false,
+  "properties": {
+    "summary": {"type": "string", "minLength": 1},
+    "severity": {"enum": ["low", "medium", "high"]},
+    "count": {"type": "integer", "minimum": 0},
+    "items": {"type": "array", "maxItems": 2, "items": {"$ref": "#/$defs/item"}}
+  },
+  "$defs": {
+    "item": {"type": "object", "required": ["line"], "properties": {"line": {"type": "integer"}}}
+  }
+}`
+
+func TestValidate(t *testing.T) {
+	s, err := Parse([]byte(reportSchema))
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	if errs := s.Validate([]byte(`{"summary":"ok","severity":"low","count":3,"items":[{"line":1}]}`)); len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+
+	cases := map[string]string{
+		`{"severity":"low","items":[]}`:                                               `missing required property "summary"`,
+		`{"summary":"x","severity":"urgent","items":[]}`:                              `/severity: value "urgent" is not one of the allowed values`,
+		`{"summary":"x","severity":"low","count":1.5,"items":[]}`:                     `/count: expected integer, got number`,
+		`{"summary":"x","severity":"low","items":[{"line":"a"}]}`:                     `/items/0/line: expected integer, got string`,
+		`{"summary":"x","severity":"low","items":[],"extra":true}`:                    `unexpected property "extra"`,
+		`{"summary":"x","severity":"low","items":[{"line":1},{"line":2},{"line":3}]}`: `/items: array has more than 2 items`,
+		`[1]`:             `expected object, got array`,
+		`{"summary": "x"`: `invalid JSON`,
+	}
+	for doc, want := range cases {
+		errs := s.Validate([]byte(doc))
+		if !strings.Contains(strings.Join(errs, "\n"), want) {
+			t.Errorf("Validate(%s) = %v, want %q", doc, errs, want)
+		}
+	}
+}
+
+func TestParseRejectsBadPattern(t *testing.T) {
+	_, err := Parse([]byte(`{"type":"object","properties":{"id":{"type":"string","pattern":"([a-z"}}}`))
+	if err == nil || !strings.Contains(err.Error(), "/properties/id/pattern") {
+		t.Fatalf("expected a pattern error, got %v", err)
+	}
+	if _, err := Parse([]byte(`{"enum":[{"pattern":"("}],"properties":{"pattern":{"type":"string"}}}`)); err != nil {
+		t.Fatalf("literal values and a property named pattern are not patterns: %v", err)
+	}
+
+	s := &Schema{doc: map[string]any{"type": "string", "pattern": "("}}
+	if errs := s.Validate([]byte(`"x"`)); len(errs) != 1 || !strings.Contains(errs[0], "invalid pattern") {
+		t.Fatalf("expected an invalid pattern failure, got %v", errs)
+	}
+}
+
+func TestExtract(t *testing.T) {
+	data, err := Extract("```json\n{\"a\": 1}\n```\n")
+	if err != nil || string(data) != `{"a": 1}` {
+		t.Fatalf("Extract = %q, %v", data, err)
+	}
+	if _, err := Extract("   "); err == nil {
+		t.Fatal("expected error for empty reply")
+	}
+}
@@ -4,31 +4,63 @@ import (
 	"errors"
 	"io"
 	"strings"
+	"unicode/utf8"
+
+	"github.com/yjhatfdu/aip/internal/tokens"
 )
 
 type InputOptions struct {
 	MaxChars    int
 	IncludeHead int
 	IncludeTail int
+	// MaxTokens budgets the input in estimated tokens of Encoding; when set
+	// without MaxChars, no character limit applies.
+	MaxTokens int
+	Encoding  tokens.Encoding
+}
+
+// InputStats describes how much of the input survived truncation.
+type InputStats struct {
+	Bytes      int
+	KeptBytes  int
+	Lines      int
+	KeptLines  int
+	Tokens     int
+	KeptTokens int
+}
+
+func (s InputStats) Truncated() bool {
+	return s.KeptBytes < s.Bytes
 }
 
 func ReadInput(r io.Reader, opts InputOptions) (string, error) {
-	if opts.MaxChars <= 0 {
+	text, _, err := ReadInputStats(r, opts)
+	return text, err
+}
+
+// ReadInputStats reads and truncates the input like ReadInput. Cuts fall on
+// line boundaries where possible and never split a UTF-8 character.
+func ReadInputStats(r io.Reader, opts InputOptions) (string, InputStats, error) {
+	if opts.MaxChars <= 0 && opts.MaxTokens <= 0 {
 		opts.MaxChars = 40000
 	}
+	if opts.Encoding.LettersPerToken == 0 {
+		opts.Encoding = tokens.Default
+	}
 	data, err := io.ReadAll(r)
 	if err != nil {
-		return "", err
+		return "", InputStats{}, err
 	}
 	text := string(data)
+	stats := InputStats{Bytes: len(text), Lines: countLines(text), Tokens: opts.Encoding.Count(text)}
 	if opts.IncludeHead > 0 || opts.IncludeTail > 0 {
 		head := text
 		tail := ""
 		if opts.IncludeHead > 0 && len(text) > opts.IncludeHead {
-			head = text[:opts.IncludeHead]
+			head = cutHead(text, opts.IncludeHead)
 		}
 		if opts.IncludeTail > 0 && len(text) > opts.IncludeTail {
-			tail = text[len(text)-opts.IncludeTail:]
+			tail = cutTail(text, opts.IncludeTail)
 		}
 		if tail != "" {
 			text = head + "\n...\n" + tail
@@ -36,11 +68,62 @@ func ReadInput(r io.Reader, opts InputOptions) (string, error) {
 			text = head
 		}
 	}
-	if len(text) > opts.MaxChars {
-		text = text[:opts.MaxChars]
+	if opts.MaxChars > 0 && len(text) > opts.MaxChars {
+		text = cutHead(text, opts.MaxChars)
+	}
+	if opts.MaxTokens > 0 {
+		text, _ = opts.Encoding.Truncate(text, opts.MaxTokens)
 	}
 	if strings.TrimSpace(text) == "" {
-		return "", errors.New("empty input")
+		return "", stats, errors.New("empty input")
+	}
+	stats.KeptBytes = len(text)
+	stats.KeptLines = countLines(text)
+	stats.KeptTokens = stats.Tokens
+	if text != string(data) {
+		stats.KeptTokens = opts.Encoding.Count(text)
+	}
+	return text, stats, nil
+}
+
+// cutHead keeps at most max bytes, ending after the last complete line when
+// one fits and otherwise at a rune boundary.
+func cutHead(text string, max int) string {
+	if len(text) <= max {
+		return text
+	}
+	if nl := strings.LastIndexByte(text[:max], '\n'); nl >= 0 {
+		return text[:nl+1]
+	}
+	for max > 0 && !utf8.RuneStart(text[max]) {
+		max--
+	}
+	return text[:max]
+}
+
+// cutTail keeps at most max trailing bytes, starting at a line boundary when
+// possible.
+func cutTail(text string, max int) string {
+	if len(text) <= max {
+		return text
+	}
+	start := len(text) - max
+	if nl := strings.IndexByte(text[start:], '\n'); nl >= 0 && start+nl+1 < len(text) {
+		return text[start+nl+1:]
+	}
+	for start < len(text) && !utf8.RuneStart(text[start]) {
+		start++
+	}
+	return text[start:]
+}
+
+func countLines(text string) int {
+	if text == "" {
+		return 0
+	}
+	n := strings.Count(text, "\n")
+	if !strings.HasSuffix(text, "\n") {
+		n++
 	}
-	return text, nil
+	return n
 }
@@ -4,6 +4,8 @@ import (
 	"os"
 	"strings"
 	"testing"
+
+	"github.com/yjhatfdu/aip/internal/tokens"
 )
 
 func TestReadInputMaxChars(t *testing.T) {
@@ -45,3 +47,38 @@ func TestLoadPromptFromFile(t *testing.T) {
 		t.Fatalf("got %q", got)
 	}
 }
+
+func TestReadInputKeepsUTF8AndLines(t *testing.T) {
+	got, err := ReadInput(strings.NewReader("数据库连接失败"), InputOptions{MaxChars: 7})
+	if err != nil {
+		t.Fatalf("ReadInput error: %v", err)
+	}
+	if got != "数据" {
+		t.Fatalf("got %q, want two whole characters", got)
+	}
+	got, err = ReadInput(strings.NewReader("line one\nline two\nline three\n"), InputOptions{MaxChars: 20})
+	if err != nil {
+		t.Fatalf("ReadInput error: %v", err)
+	}
+	if got != "line one\nline two\n" {
+		t.Fatalf("got %q, want whole lines", got)
+	}
+}
+
+func TestReadInputStatsTokenBudget(t *testing.T) {
+	input := strings.Repeat("2024-01-01 ERROR connection refused\n", 50)
+	got, stats, err := ReadInputStats(strings.NewReader(input), InputOptions{MaxTokens: 40, Encoding: tokens.CL100k})
+	if err != nil {
+		t.Fatalf("ReadInputStats error: %v", err)
+	}
+	if !stats.Truncated() || stats.KeptTokens > 40 || stats.Lines != 50 || stats.KeptLines == 0 || stats.KeptLines >= 50 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+	if !strings.HasSuffix(got, "\n") || len(got) != stats.KeptBytes {
+		t.Fatalf("cut not on a line boundary: %q", got)
+	}
+	_, stats, err = ReadInputStats(strings.NewReader("short\n"), InputOptions{MaxTokens: 40})
+	if err != nil || stats.Truncated() {
+		t.Fatalf("short input should not be truncated: %+v, %v", stats, err)
+	}
+}
@@ -0,0 +1,330 @@
+package summary
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/yjhatfdu/aip/internal/chunk"
+	"github.com/yjhatfdu/aip/internal/llm"
+	"github.com/yjhatfdu/aip/internal/tokens"
+)
+
+const (
+	StrategySingle    = "single"
+	StrategyMapReduce = "map-reduce"
+	StrategyRefine    = "refine"
+)
+
+type Completer func(ctx context.Context, system, user string) (string, llm.Usage, error)
+
+type StrategyOptions struct {
+	MaxChars int
+	// MaxTokens caps the estimated tokens of each chunk next to MaxChars;
+	// 0 leaves only MaxChars.
+	MaxTokens int
+	// ReplyTokens is the room refine keeps in each request for the running
+	// answer; 0 reserves a quarter of the limits.
+	ReplyTokens int
+	Concurrency int
+}
+
+type StrategyResult struct {
+	Output string
+	Depth  int
+	Chunks int
+	Calls  int
+	Usage  llm.Usage
+}
+
+func ReadFull(r io.Reader) (string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	text := string(data)
+	if strings.TrimSpace(text) == "" {
+		return "", errors.New("empty input")
+	}
+	return text, nil
+}
+
+// MapReduce summarizes each chunk with the user prompt, then combines the
+// partial results level by level until they fit into a single request.
+func MapReduce(ctx context.Context, complete Completer, system, userPrompt, input string, opts StrategyOptions) (StrategyResult, error) {
+	opts = normalizeStrategyOptions(opts)
+	chunks, err := splitInput(input, opts.MaxChars, opts.MaxTokens)
+	if err != nil {
+		return StrategyResult{}, err
+	}
+	if len(chunks) == 0 {
+		return StrategyResult{}, errors.New("empty input")
+	}
+	result := StrategyResult{Chunks: len(chunks)}
+	var tally usageTally
+
+	partials := make([]string, len(chunks))
+	err = runParallel(ctx, len(chunks), opts.Concurrency, func(ctx context.Context, i int) error {
+		user := BuildChunkPrompt(userPrompt, chunks[i].Text, i+1, len(chunks))
+		if len(chunks) == 1 {
+			user = BuildUserPrompt(userPrompt, chunks[i].Text)
+		}
+		out, usage, err := complete(ctx, system, user)
+		if err != nil {
+			return fmt.Errorf("chunk %d: %w", i+1, err)
+		}
+		partials[i] = out
+		tally.add(usage)
+		return nil
+	})
+	if err != nil {
+		return StrategyResult{}, err
+	}
+	result.Depth = 1
+
+	for len(partials) > 1 {
+		groups := groupPartials(partials, opts.MaxChars, opts.MaxTokens)
+		next := make([]string, len(groups))
+		err := runParallel(ctx, len(groups), opts.Concurrency, func(ctx context.Context, i int) error {
+			if len(groups[i]) == 1 {
+				next[i] = groups[i][0]
+				return nil
+			}
+			out, usage, err := complete(ctx, system, BuildCombinePrompt(userPrompt, groups[i]))
+			if err != nil {
+				return fmt.Errorf("combine level %d: %w", result.Depth+1, err)
+			}
+			next[i] = out
+			tally.add(usage)
+			return nil
+		})
+		if err != nil {
+			return StrategyResult{}, err
+		}
+		partials = next
+		result.Depth++
+	}
+	result.Output = partials[0]
+	result.Usage = tally.usage
+	result.Calls = tally.calls
+	return result, nil
+}
+
+// Refine walks the chunks in order, carrying the running answer forward and
+// asking the model to update it with each new chunk.
+func Refine(ctx context.Context, complete Completer, system, userPrompt, input string, opts StrategyOptions) (StrategyResult, error) {
+	opts = normalizeStrategyOptions(opts)
+	maxChars, maxTokens := refineLimits(opts)
+	chunks, err := splitInput(input, maxChars, maxTokens)
+	if err != nil {
+		return StrategyResult{}, err
+	}
+	if len(chunks) == 0 {
+		return StrategyResult{}, errors.New("empty input")
+	}
+	result := StrategyResult{Chunks: len(chunks)}
+	current := ""
+	for i, c := range chunks {
+		user := BuildUserPrompt(userPrompt, c.Text)
+		if i > 0 {
+			user = BuildRefinePrompt(userPrompt, current, c.Text, i+1, len(chunks))
+		}
+		out, usage, err := complete(ctx, system, user)
+		if err != nil {
+			return StrategyResult{}, fmt.Errorf("chunk %d: %w", i+1, err)
+		}
+		current = out
+		result.Usage.Add(usage)
+		result.Calls++
+		result.Depth++
+	}
+	result.Output = current
+	return result, nil
+}
+
+func BuildChunkPrompt(userPrompt, input string, part, total int) string {
+	return fmt.Sprintf("%s\n\nThis is part %d of %d of the input; answer for this part only.\n\nINPUT:\n%s", userPrompt, part, total, input)
+}
+
+func BuildCombinePrompt(userPrompt string, partials []string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "The input was too large for one request, so it was split into parts and each part was answered separately.\n")
+	fmt.Fprintf(&b, "Merge the partial results below into one answer to the original instruction, removing duplicates.\n\n")
+	fmt.Fprintf(&b, "ORIGINAL INSTRUCTION:\n%s\n\nPARTIAL RESULTS:\n", userPrompt)
+	for i, p := range partials {
+		fmt.Fprintf(&b, "--- part %d ---\n%s\n", i+1, strings.TrimSpace(p))
+	}
+	return b.String()
+}
+
+func BuildRefinePrompt(userPrompt, current, input string, part, total int) string {
+	return fmt.Sprintf("%s\n\nAn answer based on the earlier parts of the input already exists. Update it using part %d of %d and return the full revised answer.\n\nCURRENT ANSWER:\n%s\n\nINPUT:\n%s", userPrompt, part, total, current, input)
+}
+
+// splitInput chunks input within both limits: by tokens when a budget is
+// set, then by chars for any chunk still over maxChars.
+func splitInput(input string, maxChars, maxTokens int) ([]chunk.Chunk, error) {
+	if maxTokens <= 0 {
+		return chunk.SplitString(input, chunk.Options{MaxChars: maxChars})
+	}
+	chunks, err := chunk.SplitString(input, chunk.Options{MaxTokens: maxTokens})
+	if err != nil || maxChars <= 0 {
+		return chunks, err
+	}
+	var out []chunk.Chunk
+	for _, c := range chunks {
+		if len(c.Text) <= maxChars {
+			out = append(out, c)
+			continue
+		}
+		parts, err := chunk.SplitString(c.Text, chunk.Options{MaxChars: maxChars})
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, parts...)
+	}
+	return out, nil
+}
+
+// refineLimits leaves room in each refine request for the running answer:
+// ReplyTokens (about four chars each), or a quarter of a limit when that is
+// unknown, and never more than half of it.
+func refineLimits(opts StrategyOptions) (int, int) {
+	reserve := func(limit, want int) int {
+		if limit <= 0 {
+			return limit
+		}
+		if want <= 0 {
+			want = limit / 4
+		}
+		return limit - min(want, limit/2)
+	}
+	return reserve(opts.MaxChars, 4*opts.ReplyTokens), reserve(opts.MaxTokens, opts.ReplyTokens)
+}
+
+// groupPartials packs neighbouring partials into combine requests within
+// the limits; a group of one is carried to the next level as it is. When no
+// two neighbours fit, partials over half a limit are re-chunked on line
+// boundaries so the pieces pair up, and only pieces that cannot be split,
+// such as one very long line, are paired over the limit.
+func groupPartials(partials []string, maxChars, maxTokens int) [][]string {
+	if groups := packPartials(partials, maxChars, maxTokens); len(groups) < len(partials) {
+		return groups
+	}
+	halfChars, halfTokens := halfLimit(maxChars), halfLimit(maxTokens)
+	var pieces []string
+	for _, p := range partials {
+		if !fitsLimits(len(p), tokens.Estimate(p), halfChars, halfTokens) {
+			if chunks, err := splitInput(p, halfChars, halfTokens); err == nil && len(chunks) > 1 {
+				for _, c := range chunks {
+					pieces = append(pieces, c.Text)
+				}
+				continue
+			}
+		}
+		pieces = append(pieces, p)
+	}
+	if groups := packPartials(pieces, maxChars, maxTokens); len(groups) < len(pieces) {
+		return groups
+	}
+	var groups [][]string
+	for i := 0; i < len(pieces); i += 2 {
+		groups = append(groups, pieces[i:min(i+2, len(pieces))])
+	}
+	return groups
+}
+
+func packPartials(partials []string, maxChars, maxTokens int) [][]string {
+	var (
+		groups      [][]string
+		cur         []string
+		chars, toks int
+	)
+	for _, p := range partials {
+		n, t := len(p), tokens.Estimate(p)
+		if len(cur) > 0 && !fitsLimits(chars+n, toks+t, maxChars, maxTokens) {
+			groups = append(groups, cur)
+			cur, chars, toks = nil, 0, 0
+		}
+		cur = append(cur, p)
+		chars += n
+		toks += t
+	}
+	if len(cur) > 0 {
+		groups = append(groups, cur)
+	}
+	return groups
+}
+
+func fitsLimits(chars, toks, maxChars, maxTokens int) bool {
+	return (maxChars <= 0 || chars <= maxChars) && (maxTokens <= 0 || toks <= maxTokens)
+}
+
+func halfLimit(limit int) int {
+	if limit <= 0 {
+		return 0
+	}
+	return max(limit/2, 1)
+}
+
+func normalizeStrategyOptions(opts StrategyOptions) StrategyOptions {
+	if opts.MaxChars <= 0 && opts.MaxTokens <= 0 {
+		opts.MaxChars = 40000
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 1
+	}
+	return opts
+}
+
+type usageTally struct {
+	mu    sync.Mutex
+	usage llm.Usage
+	calls int
+}
+
+func (t *usageTally) add(u llm.Usage) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.usage.Add(u)
+	t.calls++
+}
+
+func runParallel(ctx context.Context, n, concurrency int, fn func(ctx context.Context, i int) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+	sem := make(chan struct{}, concurrency)
+	for i := 0; i < n; i++ {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if err := fn(ctx, i); err != nil {
+				once.Do(func() {
+					firstErr = err
+					cancel()
+				})
+			}
+		}(i)
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,127 @@
+package summary
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/yjhatfdu/aip/internal/llm"
+)
+
+func TestMapReduceCombinesUntilSingle(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		calls []string
+	)
+	complete := func(ctx context.Context, system, user string) (string, llm.Usage, error) {
+		mu.Lock()
+		calls = append(calls, user)
+		mu.Unlock()
+		return "partial", llm.Usage{PromptTokens: 2, CompletionTokens: 1, TotalTokens: 3}, nil
+	}
+	input := strings.Repeat("0123456789\n", 8)
+	res, err := MapReduce(context.Background(), complete, "sys", "summarize", input, StrategyOptions{MaxChars: 22, Concurrency: 2})
+	if err != nil {
+		t.Fatalf("MapReduce error: %v", err)
+	}
+	if res.Chunks != 4 {
+		t.Fatalf("chunks = %d", res.Chunks)
+	}
+	if res.Depth < 2 {
+		t.Fatalf("expected combine level, depth = %d", res.Depth)
+	}
+	if res.Calls != len(calls) || res.Usage.TotalTokens != 3*len(calls) {
+		t.Fatalf("usage not aggregated: %+v (calls %d)", res, len(calls))
+	}
+	if res.Output != "partial" {
+		t.Fatalf("output = %q", res.Output)
+	}
+}
+
+func TestMapReduceSingleChunk(t *testing.T) {
+	complete := func(ctx context.Context, system, user string) (string, llm.Usage, error) {
+		if strings.Contains(user, "part 1 of") {
+			t.Fatalf("single chunk should use plain prompt: %q", user)
+		}
+		return "done", llm.Usage{TotalTokens: 1}, nil
+	}
+	res, err := MapReduce(context.Background(), complete, "sys", "summarize", "short input\n", StrategyOptions{})
+	if err != nil {
+		t.Fatalf("MapReduce error: %v", err)
+	}
+	if res.Depth != 1 || res.Calls != 1 || res.Output != "done" {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+}
+
+func TestRefineCarriesAnswer(t *testing.T) {
+	n := 0
+	complete := func(ctx context.Context, system, user string) (string, llm.Usage, error) {
+		n++
+		if n > 1 && !strings.Contains(user, "CURRENT ANSWER:\nanswer") {
+			t.Fatalf("previous answer not carried: %q", user)
+		}
+		return "answer", llm.Usage{TotalTokens: 1}, nil
+	}
+	res, err := Refine(context.Background(), complete, "sys", "summarize", "aaaa\nbbbb\ncccc\n", StrategyOptions{MaxChars: 5})
+	if err != nil {
+		t.Fatalf("Refine error: %v", err)
+	}
+	if res.Chunks != 3 || res.Calls != 3 || res.Usage.TotalTokens != 3 {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+}
+
+func TestStrategiesHonourTokenBudget(t *testing.T) {
+	complete := func(ctx context.Context, system, user string) (string, llm.Usage, error) {
+		return "p", llm.Usage{}, nil
+	}
+	input := strings.Repeat("0123456789\n", 8) // 3 estimated tokens per line
+	for _, tc := range []struct {
+		name   string
+		run    func(context.Context, Completer, string, string, string, StrategyOptions) (StrategyResult, error)
+		opts   StrategyOptions
+		chunks int
+	}{
+		{"map-reduce tokens", MapReduce, StrategyOptions{MaxChars: 1000, MaxTokens: 6}, 4},
+		{"map-reduce chars cap", MapReduce, StrategyOptions{MaxChars: 22, MaxTokens: 100}, 4},
+		{"refine reply room", Refine, StrategyOptions{MaxTokens: 12, ReplyTokens: 6}, 4},
+		{"refine default room", Refine, StrategyOptions{MaxTokens: 12}, 3},
+	} {
+		res, err := tc.run(context.Background(), complete, "sys", "summarize", input, tc.opts)
+		if err != nil || res.Chunks != tc.chunks {
+			t.Fatalf("%s: chunks = %d, want %d (%v)", tc.name, res.Chunks, tc.chunks, err)
+		}
+	}
+}
+
+func TestGroupPartialsStaysWithinLimit(t *testing.T) {
+	sizes := func(groups [][]string) []int {
+		var out []int
+		for _, g := range groups {
+			out = append(out, len(strings.Join(g, "")))
+		}
+		return out
+	}
+	// A leftover partial stays alone instead of overflowing the last group.
+	groups := groupPartials([]string{"aaaa", "bbbb", "cccc"}, 8, 0)
+	if got := sizes(groups); len(got) != 2 || got[0] != 8 || got[1] != 4 {
+		t.Fatalf("tail groups = %v", got)
+	}
+	// No two partials fit: they are re-chunked on lines and packed again.
+	groups = groupPartials([]string{"1234\n567\n", "1234\n567\n", "1234\n567\n"}, 10, 0)
+	for _, size := range sizes(groups) {
+		if size > 10 {
+			t.Fatalf("group over the limit: %v", sizes(groups))
+		}
+	}
+	if len(groups) >= 6 {
+		t.Fatalf("no group combines anything: %v", sizes(groups))
+	}
+	// Single lines cannot shrink, so they are paired as a last resort.
+	groups = groupPartials([]string{"123456789", "123456789", "123456789"}, 10, 0)
+	if len(groups) != 2 || len(groups[0]) != 2 || len(groups[1]) != 1 {
+		t.Fatalf("forced pairs = %v", groups)
+	}
+}
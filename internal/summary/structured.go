@@ -0,0 +1,78 @@
+package summary
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/yjhatfdu/aip/internal/llm"
+	"github.com/yjhatfdu/aip/internal/schema"
+)
+
+var schemaNameRe = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// SchemaName derives the response_format name from the schema file name.
+func SchemaName(path string) string {
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	name = strings.Trim(schemaNameRe.ReplaceAllString(name, "_"), "_")
+	if name == "" {
+		return "output"
+	}
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	return name
+}
+
+// CompleteStructured requests a reply conforming to s, validates it locally and
+// retries once with the validation errors when it does not conform. The
+// returned usage covers both attempts.
+func CompleteStructured(ctx context.Context, p llm.Provider, req llm.ChatRequest, name string, s *schema.Schema) (llm.ChatResponse, json.RawMessage, error) {
+	req.Stream = false
+	req.ResponseFormat = llm.SchemaFormat(name, s.Raw)
+
+	var usage llm.Usage
+	for attempt := 0; ; attempt++ {
+		resp, err := p.Complete(ctx, req)
+		if err != nil {
+			return llm.ChatResponse{}, nil, err
+		}
+		usage.Add(resp.Usage)
+		if len(resp.Choices) == 0 {
+			return llm.ChatResponse{}, nil, errors.New("empty response")
+		}
+		reply := resp.Choices[0].Message.Content
+		var problems []string
+		data, err := schema.Extract(reply)
+		if err != nil {
+			problems = []string{err.Error()}
+		} else {
+			problems = s.Validate(data)
+		}
+		if len(problems) == 0 {
+			resp.Usage = usage
+			return resp, json.RawMessage(data), nil
+		}
+		if attempt > 0 {
+			return llm.ChatResponse{}, nil, fmt.Errorf("reply does not match schema: %s", strings.Join(problems, "; "))
+		}
+		req.Messages = append(req.Messages,
+			llm.ChatMessage{Role: "assistant", Content: reply},
+			llm.ChatMessage{Role: "user", Content: BuildSchemaRetryPrompt(problems)},
+		)
+	}
+}
+
+func BuildSchemaRetryPrompt(problems []string) string {
+	var b strings.Builder
+	b.WriteString("Your reply does not conform to the required JSON schema:\n")
+	for _, p := range problems {
+		fmt.Fprintf(&b, "- %s\n", p)
+	}
+	b.WriteString("Respond again with only the corrected JSON value.")
+	return b.String()
+}
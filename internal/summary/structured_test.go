@@ -0,0 +1,70 @@
+package summary
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/yjhatfdu/aip/internal/llm"
+	"github.com/yjhatfdu/aip/internal/schema"
+)
+
+type scriptedProvider struct {
+	replies  []string
+	requests []llm.ChatRequest
+}
+
+func (p *scriptedProvider) Complete(ctx context.Context, req llm.ChatRequest) (llm.ChatResponse, error) {
+	p.requests = append(p.requests, req)
+	reply := p.replies[0]
+	p.replies = p.replies[1:]
+	return llm.Fake{Reply: reply}.Complete(ctx, req)
+}
+
+func (p *scriptedProvider) StreamComplete(ctx context.Context, req llm.ChatRequest, onDelta func(string) error) (llm.ChatResponse, error) {
+	resp, err := p.Complete(ctx, req)
+	if err != nil {
+		return llm.ChatResponse{}, err
+	}
+	return resp, onDelta(resp.Choices[0].Message.Content)
+}
+
+func TestCompleteStructuredRetriesOnce(t *testing.T) {
+	s, err := schema.Parse([]byte(`{"type":"object","required":["summary"],"properties":{"summary":{"type":"string"}}}`))
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	p := &scriptedProvider{replies: []string{`{"text":"wrong"}`, "```json\n{\"summary\":\"fixed\"}\n```"}}
+	req := llm.ChatRequest{Messages: []llm.ChatMessage{{Role: "user", Content: "go"}}}
+	resp, data, err := CompleteStructured(context.Background(), p, req, "report", s)
+	if err != nil {
+		t.Fatalf("CompleteStructured error: %v", err)
+	}
+	if string(data) != `{"summary":"fixed"}` {
+		t.Fatalf("data = %s", data)
+	}
+	if len(p.requests) != 2 || p.requests[0].ResponseFormat == nil || p.requests[0].ResponseFormat.JSONSchema.Name != "report" {
+		t.Fatalf("unexpected requests: %+v", p.requests)
+	}
+	retry := p.requests[1].Messages
+	if len(retry) != 3 || retry[1].Role != "assistant" || !strings.Contains(retry[2].Content, `missing required property "summary"`) {
+		t.Fatalf("retry did not carry validation errors: %+v", retry)
+	}
+	if resp.Usage.TotalTokens <= 0 {
+		t.Fatalf("usage not accumulated: %+v", resp.Usage)
+	}
+
+	p = &scriptedProvider{replies: []string{"nope", "still nope"}}
+	if _, _, err := CompleteStructured(context.Background(), p, req, "report", s); err == nil || !strings.Contains(err.Error(), "does not match schema") {
+		t.Fatalf("expected schema error, got %v", err)
+	}
+}
+
+func TestSchemaName(t *testing.T) {
+	if got := SchemaName("/tmp/incident report.schema.json"); got != "incident_report_schema" {
+		t.Fatalf("SchemaName = %q", got)
+	}
+	if got := SchemaName("..json"); got != "output" {
+		t.Fatalf("SchemaName = %q", got)
+	}
+}
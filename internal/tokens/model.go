@@ -0,0 +1,208 @@
+package tokens
+
+import (
+	"math"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Encoding approximates a BPE tokenizer family without its vocabulary. Text is
+// split the way the BPE pre-tokenizers do (letter runs with their leading
+// space, digit groups, punctuation, whitespace) and each piece is costed with
+// per-family averages.
+type Encoding struct {
+	Name string
+	// LettersPerToken is the average length of a letter run covered by one
+	// token.
+	LettersPerToken float64
+	// DigitsPerToken is the digit group size of the pre-tokenizer.
+	DigitsPerToken int
+	// TokensPerCJK is the average cost of one Han, kana or Hangul rune.
+	TokensPerCJK float64
+}
+
+var (
+	O200k   = Encoding{Name: "o200k", LettersPerToken: 5, DigitsPerToken: 3, TokensPerCJK: 0.8}
+	CL100k  = Encoding{Name: "cl100k", LettersPerToken: 4.5, DigitsPerToken: 3, TokensPerCJK: 1.2}
+	Claude  = Encoding{Name: "claude", LettersPerToken: 4, DigitsPerToken: 1, TokensPerCJK: 1.1}
+	Llama   = Encoding{Name: "llama", LettersPerToken: 4.5, DigitsPerToken: 3, TokensPerCJK: 1}
+	Qwen    = Encoding{Name: "qwen", LettersPerToken: 4.5, DigitsPerToken: 1, TokensPerCJK: 0.7}
+	Default = Encoding{Name: "default", LettersPerToken: 4, DigitsPerToken: 2, TokensPerCJK: 1}
+)
+
+var families = []struct {
+	prefixes []string
+	enc      Encoding
+}{
+	{[]string{"gpt-4o", "gpt-4.1", "gpt-4.5", "gpt-5", "o1", "o3", "o4", "chatgpt-4o"}, O200k},
+	{[]string{"gpt-4", "gpt-3.5", "text-embedding"}, CL100k},
+	{[]string{"claude"}, Claude},
+	{[]string{"llama", "mistral", "mixtral", "codestral", "gemma", "phi"}, Llama},
+	{[]string{"qwen", "deepseek", "glm", "yi-", "moonshot", "kimi"}, Qwen},
+}
+
+// ForModel picks the encoding family from a model name; provider prefixes
+// such as "openai/" or "library/" and tags such as ":8b" are ignored.
+func ForModel(model string) Encoding {
+	name := normalizeModel(model)
+	for _, f := range families {
+		for _, p := range f.prefixes {
+			if strings.HasPrefix(name, p) {
+				return f.enc
+			}
+		}
+	}
+	return Default
+}
+
+func normalizeModel(model string) string {
+	name := strings.ToLower(strings.TrimSpace(model))
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
+
+func (e Encoding) Count(text string) int {
+	total := 0.0
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		switch {
+		case r == ' ' && i+1 < len(text) && isLetter(rune(text[i+1])):
+			// A single space is merged into the following word.
+			i++
+		case isLetter(r):
+			n := 0
+			for i < len(text) {
+				r, size = utf8.DecodeRuneInString(text[i:])
+				if !isLetter(r) {
+					break
+				}
+				n += min(size, 2)
+				i += size
+			}
+			total += math.Ceil(float64(n) / e.LettersPerToken)
+		case r >= '0' && r <= '9':
+			n := 0
+			for i < len(text) && text[i] >= '0' && text[i] <= '9' {
+				n++
+				i++
+			}
+			total += math.Ceil(float64(n) / float64(e.DigitsPerToken))
+		case unicode.IsSpace(r):
+			for i < len(text) {
+				r, size = utf8.DecodeRuneInString(text[i:])
+				if !unicode.IsSpace(r) {
+					break
+				}
+				i += size
+			}
+			total++
+		case isCJK(r):
+			total += e.TokensPerCJK
+			i += size
+		default:
+			// Punctuation, symbols and invalid bytes cost about a token each;
+			// multi-byte symbols often split into byte tokens.
+			total += float64(min(size, 2))
+			i += size
+		}
+	}
+	return int(math.Ceil(total))
+}
+
+func isLetter(r rune) bool {
+	return unicode.IsLetter(r) && !isCJK(r)
+}
+
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r)
+}
+
+var contextSizes = []struct {
+	prefix string
+	size   int
+}{
+	{"gpt-4o", 128000},
+	{"gpt-4.1", 1047576},
+	{"gpt-4-turbo", 128000},
+	{"gpt-4-32k", 32768},
+	{"gpt-4", 8192},
+	{"gpt-3.5-turbo", 16385},
+	{"gpt-5", 400000},
+	{"o1", 200000},
+	{"o3", 200000},
+	{"o4", 200000},
+	{"claude", 200000},
+	{"llama3", 8192},
+	{"llama-3", 8192},
+	{"llama3.1", 131072},
+	{"llama-3.1", 131072},
+	{"qwen2.5", 32768},
+	{"deepseek", 65536},
+	{"mistral", 32768},
+}
+
+// ContextSize returns the context window of well-known models, or 0. The
+// longest matching prefix wins, so gpt-4-turbo is not taken for gpt-4.
+func ContextSize(model string) int {
+	name := normalizeModel(model)
+	best, size := 0, 0
+	for _, c := range contextSizes {
+		if strings.HasPrefix(name, c.prefix) && len(c.prefix) > best {
+			best, size = len(c.prefix), c.size
+		}
+	}
+	return size
+}
+
+// Truncate keeps the longest prefix of text that fits in budget tokens. It
+// cuts at a line boundary when at least one line fits, and otherwise at a
+// rune boundary inside the first line. It returns the kept text and its
+// token count.
+func (e Encoding) Truncate(text string, budget int) (string, int) {
+	if budget <= 0 {
+		return "", 0
+	}
+	used := 0
+	end := 0
+	for end < len(text) {
+		next := strings.IndexByte(text[end:], '\n')
+		lineEnd := len(text)
+		if next >= 0 {
+			lineEnd = end + next + 1
+		}
+		n := e.Count(text[end:lineEnd])
+		if used+n > budget {
+			break
+		}
+		used += n
+		end = lineEnd
+	}
+	if end > 0 || len(text) == 0 {
+		return text[:end], used
+	}
+	// Not even the first line fits: binary search the longest rune-aligned
+	// prefix of it.
+	line := text
+	if nl := strings.IndexByte(text, '\n'); nl >= 0 {
+		line = text[:nl]
+	}
+	var cuts []int
+	for i := range line {
+		cuts = append(cuts, i)
+	}
+	cuts = append(cuts, len(line))
+	lo, hi := 0, len(cuts)-1
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		if e.Count(line[:cuts[mid]]) <= budget {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	kept := line[:cuts[lo]]
+	return kept, e.Count(kept)
+}
@@ -0,0 +1,20 @@
+package tokens
+
+import "unicode"
+
+// Estimate is a rough count: ~4 ASCII bytes per token, one token per CJK rune.
+func Estimate(text string) int {
+	ascii := 0
+	wide := 0
+	for _, r := range text {
+		switch {
+		case r < 0x80:
+			ascii++
+		case unicode.Is(unicode.Han, r), unicode.Is(unicode.Hiragana, r), unicode.Is(unicode.Katakana, r), unicode.Is(unicode.Hangul, r):
+			wide++
+		default:
+			ascii += 2
+		}
+	}
+	return (ascii+3)/4 + wide
+}
@@ -0,0 +1,80 @@
+package tokens
+
+import (
+	"testing"
+	"unicode/utf8"
+)
+
+func TestEstimate(t *testing.T) {
+	cases := []struct {
+		in   string
+		want int
+	}{
+		{"", 0},
+		{"abcd", 1},
+		{"abcde", 2},
+		{"数据库", 3},
+	}
+	for _, tc := range cases {
+		if got := Estimate(tc.in); got != tc.want {
+			t.Fatalf("Estimate(%q) = %d, want %d", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestForModel(t *testing.T) {
+	cases := map[string]string{
+		"gpt-4o-mini":             "o200k",
+		"gpt-4-turbo":             "cl100k",
+		"claude-3-5-sonnet":       "claude",
+		"meta-llama/llama3.1:8b":  "llama",
+		"Qwen2.5-72B-Instruct":    "qwen",
+		"some-unknown-local-mode": "default",
+	}
+	for model, want := range cases {
+		if got := ForModel(model).Name; got != want {
+			t.Errorf("ForModel(%q) = %s, want %s", model, got, want)
+		}
+	}
+}
+
+func TestCount(t *testing.T) {
+	if got := O200k.Count(""); got != 0 {
+		t.Fatalf("empty count = %d", got)
+	}
+	line := "2024-01-01 10:00:00 ERROR connection refused to db-01:5432"
+	if got := CL100k.Count(line); got < 15 || got > 35 {
+		t.Fatalf("CL100k.Count(%q) = %d, outside the expected range", line, got)
+	}
+	cjk := "数据库连接失败，请检查网络配置"
+	if Qwen.Count(cjk) >= CL100k.Count(cjk) {
+		t.Fatalf("qwen should encode Chinese more densely than cl100k: %d vs %d", Qwen.Count(cjk), CL100k.Count(cjk))
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	text := "first line here\nsecond line here\nthird line here\n"
+	kept, n := Default.Truncate(text, Default.Count("first line here\nsecond line here\n")+1)
+	if kept != "first line here\nsecond line here\n" || n == 0 {
+		t.Fatalf("Truncate kept %q (%d tokens)", kept, n)
+	}
+	kept, _ = CL100k.Truncate("数据库连接失败请检查网络配置", 5)
+	if !utf8.ValidString(kept) || kept == "" || CL100k.Count(kept) > 5 {
+		t.Fatalf("Truncate split a rune or exceeded budget: %q", kept)
+	}
+	if kept, _ := Default.Truncate("abc", 0); kept != "" {
+		t.Fatalf("zero budget kept %q", kept)
+	}
+}
+
+func TestContextSize(t *testing.T) {
+	if got := ContextSize("gpt-4-turbo-2024-04-09"); got != 128000 {
+		t.Fatalf("gpt-4-turbo context = %d", got)
+	}
+	if got := ContextSize("gpt-4"); got != 8192 {
+		t.Fatalf("gpt-4 context = %d", got)
+	}
+	if got := ContextSize("my-model"); got != 0 {
+		t.Fatalf("unknown model context = %d", got)
+	}
+}